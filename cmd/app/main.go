@@ -1,12 +1,13 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"frappuccino-alem/internal/api"
 	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/store"
 	"frappuccino-alem/pkg/lib/prettyslog"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -15,22 +16,19 @@ import (
 
 func main() {
 	// setup config
-	cfg := config.Load()
-	// setup logger
-	logger := prettyslog.SetupPrettySlog(os.Stdout) // add level based logging
-
-	//create database object
-	connStr := cfg.DB.MakeConnectionString()
-	fmt.Println(connStr)
-	db, err := sql.Open("postgres", connStr)
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("could not open database:%s", err)
+		log.Fatalf("could not load config: %s", err)
 	}
+	// setup logger
+	level, _ := cfg.Log.SlogLevel()
+	logger := prettyslog.SetupPrettySlog(os.Stdout, level)
+	logger.Info("loaded config", slog.Any("config", cfg.Redacted()))
 
-	//ping database
-	err = db.Ping()
+	//connect to database, waiting for it to come up
+	db, err := store.Connect(context.Background(), cfg.DB, logger)
 	if err != nil {
-		log.Fatalf("could not ping database:%s", err)
+		log.Fatalf("could not connect to database:%s", err)
 	}
 
 	// create serve mux
@@ -39,7 +37,9 @@ func main() {
 	//define api server and start it
 	server := api.NewAPIServer(mux, cfg, db, logger)
 	logger.Info("running server")
-	if err := server.Run(); err != nil {
+	err = server.Run()
+	db.Close()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
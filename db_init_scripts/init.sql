@@ -1,6 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TYPE ORDER_STATUS AS ENUM ('pending', 'processing', 'completed', 'cancelled');
 CREATE TYPE PAYMENT_METHOD AS ENUM ('cash', 'card', 'online');
 CREATE TYPE STAFF_ROLE AS ENUM ('barista', 'cashier', 'manager');
+CREATE TYPE PURCHASE_ORDER_STATUS AS ENUM ('draft', 'sent', 'partially_received', 'received');
+CREATE TYPE WASTE_REASON AS ENUM ('spoilage', 'spill', 'expired', 'comped');
 
 CREATE TABLE inventory (
     id SERIAL PRIMARY KEY,
@@ -8,6 +12,7 @@ CREATE TABLE inventory (
     quantity DECIMAL(10,2) NOT NULL CHECK (quantity >= 0),
     unit TEXT NOT NULL,
     price DECIMAL(10,2) NOT NULL CHECK (price >= 0),  -- NEW COLUMN
+    reorder_level DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (reorder_level >= 0),
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
@@ -31,9 +36,18 @@ CREATE TABLE menu_item_ingredients (
     PRIMARY KEY (menu_item_id, ingredient_id)
 );
 
+CREATE TABLE staff (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    role STAFF_ROLE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 CREATE TABLE orders (
     id SERIAL PRIMARY KEY,
     customer_name TEXT NOT NULL,
+    staff_id INT REFERENCES staff(id) ON DELETE SET NULL,
     status ORDER_STATUS NOT NULL DEFAULT 'pending',
     total_amount DECIMAL(15,2) NOT NULL CHECK (total_amount >= 0),
     payment_method PAYMENT_METHOD NOT NULL,
@@ -67,23 +81,183 @@ CREATE TABLE price_history (
     changed_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+CREATE TABLE suppliers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    contact_name TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    phone TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- What a supplier sells, at what price and how many days delivery takes.
+CREATE TABLE supplier_items (
+    supplier_id INT REFERENCES suppliers(id) ON DELETE CASCADE NOT NULL,
+    inventory_id INT REFERENCES inventory(id) ON DELETE CASCADE NOT NULL,
+    unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0),
+    lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    PRIMARY KEY (supplier_id, inventory_id)
+);
+
+CREATE TABLE purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT REFERENCES suppliers(id) NOT NULL,
+    status PURCHASE_ORDER_STATUS NOT NULL DEFAULT 'draft',
+    notes TEXT NOT NULL DEFAULT '',
+    expected_at TIMESTAMPTZ,
+    sent_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_purchase_orders_supplier ON purchase_orders(supplier_id);
+
+CREATE TABLE purchase_order_lines (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT REFERENCES purchase_orders(id) ON DELETE CASCADE NOT NULL,
+    inventory_id INT REFERENCES inventory(id) NOT NULL,
+    quantity_ordered DECIMAL(10,2) NOT NULL CHECK (quantity_ordered > 0),
+    quantity_received DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (quantity_received >= 0),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    UNIQUE (purchase_order_id, inventory_id)
+);
 
+-- unit_cost and purchase_order_id are set on restocks received from a
+-- purchase order, waste_reason and staff_id on waste.
 CREATE TABLE inventory_transactions (
     id SERIAL PRIMARY KEY,
     inventory_id INT REFERENCES inventory(id) ON DELETE CASCADE  NOT NULL,
     quantity_change DECIMAL(10,2) NOT NULL,
     reason TEXT NOT NULL,
+    unit_cost DECIMAL(10,2),
+    purchase_order_id INT REFERENCES purchase_orders(id) ON DELETE SET NULL,
+    waste_reason WASTE_REASON,
+    staff_id INT REFERENCES staff(id) ON DELETE SET NULL,
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
-CREATE TABLE staff (
+CREATE INDEX idx_inventory_transactions_waste ON inventory_transactions(created_at) WHERE waste_reason IS NOT NULL;
+
+-- Stock received at one time. remaining_quantity is drawn down
+-- first-expiring-first-out, batches without an expiry date last.
+CREATE TABLE inventory_batches (
     id SERIAL PRIMARY KEY,
-    name VARCHAR(50) NOT NULL,
-    role STAFF_ROLE NOT NULL,
+    inventory_id INT REFERENCES inventory(id) ON DELETE CASCADE NOT NULL,
+    lot_code TEXT NOT NULL DEFAULT '',
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    remaining_quantity DECIMAL(10,2) NOT NULL CHECK (remaining_quantity >= 0),
+    unit_cost DECIMAL(10,2) CHECK (unit_cost >= 0),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    purchase_order_id INT REFERENCES purchase_orders(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_inventory_batches_open ON inventory_batches (inventory_id, expires_at) WHERE remaining_quantity > 0;
+CREATE INDEX idx_inventory_batches_expiry ON inventory_batches (expires_at) WHERE remaining_quantity > 0;
+
+-- Responses to requests sent with an Idempotency-Key header.
+-- status_code is NULL while the first request is still in flight.
+CREATE TABLE idempotency_keys (
+    key TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INT,
+    content_type TEXT,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (key, method, path)
+);
+
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys (expires_at);
+
+-- Token buckets of the Postgres rate limiter, shared by every instance.
+-- full_at is when the bucket has refilled; from then on the row carries no
+-- information and is pruned.
+CREATE TABLE rate_limit_buckets (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_full ON rate_limit_buckets (full_at);
+
+-- Background jobs, see internal/jobs. Dead-lettered jobs stay with status
+-- 'dead' for inspection; unique_key deduplicates scheduled runs.
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL CHECK (max_attempts > 0),
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    unique_key TEXT UNIQUE,
+    locked_at TIMESTAMPTZ,
+    locked_by TEXT,
+    last_error TEXT,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_jobs_due ON jobs (run_at, id) WHERE status = 'queued';
+CREATE INDEX idx_jobs_running ON jobs (locked_at) WHERE status = 'running';
+
+CREATE TABLE webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Transactional outbox: rows are written in the same transaction as the
+-- change they describe and fanned out to webhook_deliveries by the dispatcher.
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INT REFERENCES webhook_subscriptions(id) ON DELETE CASCADE NOT NULL,
+    event_id BIGINT REFERENCES outbox_events(id) ON DELETE CASCADE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT REFERENCES webhook_deliveries(id) ON DELETE CASCADE NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT,
+    error TEXT,
+    duration_ms INT NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 
 UPDATE orders 
 SET search_vector = 
@@ -98,23 +272,27 @@ SET search_vector =
 ALTER TABLE menu_items 
 ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
     setweight(to_tsvector('english', name), 'A') ||
-    setweight(to_tsvector('english', description), 'B')
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B')
 ) STORED;
 
--- Order search vector function
+-- Order search document: the customer name, then the names and
+-- descriptions of the items ordered. Every trigger below and the reindex
+-- endpoint (POST /search/reindex) compute it here.
+CREATE OR REPLACE FUNCTION order_search_vector(p_order_id INT, p_customer_name TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('english', COALESCE(p_customer_name, '')), 'A') ||
+        setweight(to_tsvector('english',
+            COALESCE(string_agg(mi.name || ' ' || COALESCE(mi.description, ''), ' '), '')
+        ), 'B')
+    FROM order_items oi
+    JOIN menu_items mi ON oi.menu_item_id = mi.id
+    WHERE oi.order_id = p_order_id;
+$$ LANGUAGE sql STABLE;
+
 CREATE OR REPLACE FUNCTION update_order_search_vector()
 RETURNS TRIGGER AS $$
 BEGIN
-    NEW.search_vector := 
-        setweight(to_tsvector('english', COALESCE(NEW.customer_name, '')), 'A') ||
-        setweight((
-            SELECT to_tsvector('english', 
-                COALESCE(string_agg(mi.name || ' ' || COALESCE(mi.description, ''), ' '), '')
-            )
-            FROM order_items oi
-            JOIN menu_items mi ON oi.menu_item_id = mi.id
-            WHERE oi.order_id = NEW.id
-        ), 'B');
+    NEW.search_vector := order_search_vector(NEW.id, NEW.customer_name);
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
@@ -122,35 +300,79 @@ $$ LANGUAGE plpgsql;
 
 -- Triggers
 CREATE TRIGGER order_search_update
-BEFORE INSERT OR UPDATE ON orders
+BEFORE INSERT OR UPDATE OF customer_name ON orders
 FOR EACH ROW EXECUTE FUNCTION update_order_search_vector();
 
+-- Orders are inserted before their items, so the items reindex the order
+-- themselves rather than relying on a later update of the order.
 CREATE OR REPLACE FUNCTION refresh_order_search_vector()
 RETURNS TRIGGER AS $$
 BEGIN
-    UPDATE orders SET updated_at = NOW()
-    WHERE id = COALESCE(NEW.order_id, OLD.order_id);
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE orders SET search_vector = order_search_vector(id, customer_name)
+        WHERE id = OLD.order_id;
+    END IF;
+    IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND NEW.order_id IS DISTINCT FROM OLD.order_id) THEN
+        UPDATE orders SET search_vector = order_search_vector(id, customer_name)
+        WHERE id = NEW.order_id;
+    END IF;
     RETURN NULL;
 END;
 $$ LANGUAGE plpgsql;
 
 CREATE TRIGGER order_items_search_update
-AFTER INSERT OR UPDATE OR DELETE ON order_items
+AFTER INSERT OR UPDATE OF order_id, menu_item_id OR DELETE ON order_items
 FOR EACH ROW EXECUTE FUNCTION refresh_order_search_vector();
 
+-- Renaming or redescribing a menu item reindexes every order with it.
+CREATE OR REPLACE FUNCTION refresh_menu_item_order_search_vectors()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE orders SET search_vector = order_search_vector(id, customer_name)
+    WHERE id IN (SELECT order_id FROM order_items WHERE menu_item_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER menu_items_order_search_update
+AFTER UPDATE OF name, description ON menu_items
+FOR EACH ROW
+WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.description IS DISTINCT FROM NEW.description)
+EXECUTE FUNCTION refresh_menu_item_order_search_vectors();
+
 -- Indexes
 CREATE INDEX idx_orders_search ON orders USING GIN(search_vector);
 CREATE INDEX idx_menu_items_search ON menu_items USING GIN(search_vector);
+-- Fuzzy matching and autocomplete of names
+CREATE INDEX idx_menu_items_name_trgm ON menu_items USING GIN(name gin_trgm_ops);
+CREATE INDEX idx_orders_customer_trgm ON orders USING GIN(customer_name gin_trgm_ops);
 
 -- Initialize existing data
-UPDATE orders 
-SET search_vector = 
-    setweight(to_tsvector('english', COALESCE(customer_name, '')), 'A') ||
-    setweight((
-        SELECT to_tsvector('english', 
-            COALESCE(string_agg(mi.name || ' ' || COALESCE(mi.description, ''), ' '), '')
-        )
-        FROM order_items oi
-        JOIN menu_items mi ON oi.menu_item_id = mi.id
-        WHERE oi.order_id = orders.id
-    ), 'B');
\ No newline at end of file
+UPDATE orders SET search_vector = order_search_vector(id, customer_name);
+-- Order events for live listeners (GET /orders/stream). The payload stays
+-- small on purpose: NOTIFY is capped at 8000 bytes, listeners load the order.
+CREATE OR REPLACE FUNCTION notify_order_event()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('order_events', json_build_object(
+        'type', CASE WHEN TG_OP = 'INSERT' THEN 'order.created' ELSE 'order.status_changed' END,
+        'order_id', NEW.id,
+        'status', NEW.status,
+        'previous_status', CASE WHEN TG_OP = 'UPDATE' THEN OLD.status END,
+        'occurred_at', NOW()
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_created_notify
+AFTER INSERT ON orders
+FOR EACH ROW EXECUTE FUNCTION notify_order_event();
+
+CREATE TRIGGER order_status_notify
+AFTER UPDATE OF status ON orders
+FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE FUNCTION notify_order_event();
+
+CREATE INDEX idx_orders_created_at ON orders (created_at);
+CREATE INDEX idx_orders_open ON orders (created_at) WHERE status IN ('pending', 'processing');
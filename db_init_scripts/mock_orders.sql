@@ -1,3 +1,9 @@
+INSERT INTO staff (name, role) VALUES
+    ('Aigerim Nurlanova', 'barista'),
+    ('Daniyar Serikov', 'barista'),
+    ('Madina Akhmetova', 'cashier'),
+    ('Timur Bekov', 'manager');
+
 -- Insert 30 orders with different statuses
 INSERT INTO orders (customer_name, status, total_amount, payment_method, created_at) VALUES
     ('John Smith', 'completed', 8.74, 'card', '2023-01-20 08:30'),
@@ -31,6 +37,9 @@ INSERT INTO orders (customer_name, status, total_amount, payment_method, created
     ('Logan Wright', 'cancelled', 5.50, 'cash', '2023-05-15 12:05'),
     ('Abigail Lopez', 'pending', 13.99, 'online', '2023-06-25 13:20');
 
+-- Spread the orders over the three front-of-house staff members
+UPDATE orders SET staff_id = 1 + (id % 3);
+
 -- Insert order items
 INSERT INTO order_items (order_id, menu_item_id, quantity, price_at_order) VALUES
     -- Order 1 (Completed - Jan 20)
@@ -2,4 +2,4 @@ module frappuccino-alem
 
 go 1.22
 
-require github.com/lib/pq v1.10.9 // indirect
+require github.com/lib/pq v1.10.9
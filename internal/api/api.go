@@ -1,18 +1,31 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"frappuccino-alem/internal/config"
 	"frappuccino-alem/internal/handlers"
 	"frappuccino-alem/internal/handlers/middleware"
+	"frappuccino-alem/internal/jobs"
 	"frappuccino-alem/internal/service"
 	"frappuccino-alem/internal/store"
 )
 
+// Job kinds run by the background job runner.
+const (
+	jobCleanupIdempotencyKeys = "idempotency.cleanup"
+	jobWasteExpiredBatches    = "inventory.waste_expired"
+	jobCleanupRateLimits      = "rate_limit.cleanup"
+)
+
 type APIServer struct {
 	mux    *http.ServeMux
 	cfg    config.Config
@@ -24,7 +37,12 @@ func NewAPIServer(mux *http.ServeMux, config config.Config, db *sql.DB, logger *
 	return &APIServer{mux, config, db, logger}
 }
 
+// Run serves HTTP and runs the background workers until SIGINT or SIGTERM,
+// then shuts everything down gracefully.
 func (s *APIServer) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// setup three layers for each of the entities
 	inventoryStore := store.NewInventoryStore(s.db)
 	inventoryService := service.NewInventoryService(inventoryStore)
@@ -38,22 +56,150 @@ func (s *APIServer) Run() error {
 
 	orderStore := store.NewOrderStore(s.db)
 	orderService := service.NewOrderService(inventoryStore, menuStore, orderStore)
-	orderHandler := handlers.NewOrderHandler(orderService, s.logger)
+	orderStream := service.NewOrderStream(store.NewOrderEventListener(s.cfg.DB, s.logger), orderStore, s.logger)
+	orderHandler := handlers.NewOrderHandler(orderService, orderStream, s.logger)
 	orderHandler.RegisterEndpoints(s.mux)
 
 	reportStore := store.NewReportStore(s.db)
-	reportService := service.NewReportService(reportStore)
+	reportService := service.NewReportService(reportStore, s.cfg.Inventory.Valuation)
 	reportHandler := handlers.NewReportHandler(reportService, s.logger)
 	reportHandler.RegisterEndpoints(s.mux)
 
+	webhookStore := store.NewWebhookStore(s.db)
+	webhookService := service.NewWebhookService(webhookStore)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, s.logger)
+	webhookHandler.RegisterEndpoints(s.mux)
+
+	supplierStore := store.NewSupplierStore(s.db)
+	supplierService := service.NewSupplierService(supplierStore)
+	supplierHandler := handlers.NewSupplierHandler(supplierService, s.logger)
+	supplierHandler.RegisterEndpoints(s.mux)
+
+	purchaseOrderStore := store.NewPurchaseOrderStore(s.db)
+	purchaseOrderService := service.NewPurchaseOrderService(purchaseOrderStore, supplierStore)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService, s.logger)
+	purchaseOrderHandler.RegisterEndpoints(s.mux)
+
+	openAPIHandler, err := handlers.NewOpenAPIHandler()
+	if err != nil {
+		return err
+	}
+	openAPIHandler.RegisterEndpoints(s.mux)
+
+	idempotencyStore := store.NewIdempotencyStore(s.db)
+
+	// background work
+	runner := jobs.NewRunner(s.db, s.cfg.Jobs, s.logger)
+	runner.Register(jobCleanupIdempotencyKeys, func(ctx context.Context, job jobs.Job) error {
+		n, err := idempotencyStore.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+		s.logger.Debug("deleted expired idempotency keys", slog.Int64("count", n))
+		return nil
+	})
+	if err := runner.Schedule("clean up idempotency keys", "@every 15m", jobCleanupIdempotencyKeys, nil); err != nil {
+		return err
+	}
+	runner.Register(jobWasteExpiredBatches, func(ctx context.Context, job jobs.Job) error {
+		entries, err := inventoryService.WasteExpiredBatches(ctx)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			s.logger.Info("logged expired stock as waste", slog.Int64("inventory_id", e.InventoryID),
+				slog.String("name", e.ItemName), slog.Float64("quantity", e.Quantity))
+		}
+		return nil
+	})
+	if err := runner.Schedule("waste expired batches", "@every 1h", jobWasteExpiredBatches, nil); err != nil {
+		return err
+	}
+	var rateLimitStore middleware.RateLimitStore
+	switch s.cfg.RateLimit.Backend {
+	case "memory":
+		rateLimitStore = store.NewMemoryRateLimitStore()
+	case "postgres":
+		pgRateLimitStore := store.NewRateLimitStore(s.db)
+		runner.Register(jobCleanupRateLimits, func(ctx context.Context, job jobs.Job) error {
+			n, err := pgRateLimitStore.DeleteFull(ctx)
+			if err != nil {
+				return err
+			}
+			s.logger.Debug("deleted refilled rate limit buckets", slog.Int64("count", n))
+			return nil
+		})
+		if err := runner.Schedule("clean up rate limit buckets", "@every 15m", jobCleanupRateLimits, nil); err != nil {
+			return err
+		}
+		rateLimitStore = pgRateLimitStore
+	}
+	webhookDispatcher := service.NewWebhookDispatcher(webhookStore, s.cfg.Webhooks, s.logger)
+	runner.Supervise("webhook dispatcher", func(ctx context.Context) error {
+		webhookDispatcher.Run(ctx)
+		return nil
+	})
+	runner.Supervise("order event stream", orderStream.Run)
+	runner.Start(ctx)
+
 	// add middleware if needed
-	timeoutMW := middleware.NewTimoutContextMW(15)
+	timeoutMW := middleware.NewTimoutContextMW(s.cfg.Server.RequestTimeout, "/orders/stream")
+	bodyLimitMW := middleware.NewBodyLimitMW(int64(s.cfg.Server.MaxBodyBytes))
+	idempotencyMW := middleware.NewIdempotencyMW(idempotencyStore, s.cfg.Server.IdempotencyTTL, s.logger, handlers.IdempotentPaths...)
+	mws := []middleware.Middleware{middleware.RecoveryMW, middleware.NewSecurityHeadersMW(s.cfg.Security)}
+	if len(s.cfg.CORS.AllowedOrigins) > 0 {
+		// ahead of the rate limiter so browsers can read its 429s too
+		mws = append(mws, middleware.NewCORSMW(s.cfg.CORS))
+	}
+	mws = append(mws, timeoutMW)
+	if rateLimitStore != nil {
+		// before anything reads the body or touches the database
+		mws = append(mws, middleware.NewRateLimitMW(rateLimitStore, rateLimitGroups(s.cfg.RateLimit), rateLimitKey(s.cfg.RateLimit), s.logger))
+	}
+	if s.cfg.Server.Compression {
+		mws = append(mws, middleware.CompressionMW)
+	}
+	mws = append(mws, middleware.NewCachingMW(cachePolicies(s.cfg.Cache)), bodyLimitMW, idempotencyMW)
 	// WholeMwChain
-	MWChain := middleware.NewMiddlewareChain(middleware.RecoveryMW, timeoutMW)
+	MWChain := middleware.NewMiddlewareChain(mws...)
 
 	// start server
 	serverAddress := fmt.Sprintf("%s:%s", s.cfg.Server.Address, s.cfg.Server.Port)
 
-	s.logger.Info("starting server", slog.String("host", serverAddress))
-	return http.ListenAndServe(serverAddress, MWChain(s.mux))
+	server := &http.Server{
+		Addr:         serverAddress,
+		Handler:      MWChain(s.mux),
+		ReadTimeout:  s.cfg.Server.ReadTimeout,
+		WriteTimeout: s.cfg.Server.WriteTimeout,
+		IdleTimeout:  s.cfg.Server.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("starting server", slog.String("host", serverAddress))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var runErr error
+	select {
+	case err := <-serverErr:
+		runErr = err
+		stop()
+	case <-ctx.Done():
+		s.logger.Info("shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		runErr = errors.Join(runErr, fmt.Errorf("shutdown server: %w", err))
+	}
+	if err := runner.Stop(shutdownCtx); err != nil {
+		runErr = errors.Join(runErr, err)
+	}
+
+	if errors.Is(runErr, http.ErrServerClosed) {
+		return nil
+	}
+	return runErr
 }
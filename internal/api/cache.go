@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/handlers/middleware"
+)
+
+// cachePolicies sets the Cache-Control of GET responses per route: the menu
+// changes rarely and may be reused briefly, orders change all the time and
+// are never stored, and everything else is revalidated on every use.
+func cachePolicies(cfg config.Cache) []middleware.CachePolicy {
+	return []middleware.CachePolicy{
+		{Match: hasPathPrefix("/orders", "/kitchen"), CacheControl: "no-store"},
+		{Match: hasPathPrefix("/menu"), CacheControl: maxAge("public", cfg.MenuMaxAge)},
+		{Match: hasPathPrefix("/reports", "/search"), CacheControl: maxAge("private", cfg.ReportsMaxAge)},
+		{Match: func(*http.Request) bool { return true }, CacheControl: "no-cache"},
+	}
+}
+
+func maxAge(scope string, age time.Duration) string {
+	if age <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, int64(age.Seconds()))
+}
+
+// hasPathPrefix matches the given paths and everything below them.
+func hasPathPrefix(prefixes ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
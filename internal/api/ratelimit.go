@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/middleware"
+)
+
+// rateLimitGroups turns the configured limits into middleware groups, most
+// specific first. Groups with no limit are left out.
+func rateLimitGroups(cfg config.RateLimit) []middleware.RateLimitGroup {
+	all := []middleware.RateLimitGroup{
+		{Name: "search", Match: isSearchRequest, Limit: entity.RateLimit{PerMinute: cfg.SearchPerMinute, Burst: cfg.SearchBurst}},
+		{Name: "write", Match: isWriteRequest, Limit: entity.RateLimit{PerMinute: cfg.WritePerMinute, Burst: cfg.WriteBurst}},
+		{Name: "read", Match: func(*http.Request) bool { return true }, Limit: entity.RateLimit{PerMinute: cfg.ReadPerMinute, Burst: cfg.ReadBurst}},
+	}
+	groups := make([]middleware.RateLimitGroup, 0, len(all))
+	for _, g := range all {
+		if g.Limit.PerMinute > 0 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// isSearchRequest selects the full-text search endpoints, which are the
+// most expensive to serve.
+func isSearchRequest(r *http.Request) bool {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	return path == "/reports/search" || strings.HasPrefix(path, "/search/")
+}
+
+func isWriteRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func rateLimitKey(cfg config.RateLimit) middleware.RateLimitKey {
+	// Validate has checked the proxies
+	proxies, _ := cfg.TrustedProxyPrefixes()
+	if cfg.Key == "staff" {
+		return middleware.StaffKey(cfg.ClientIPHeader, proxies)
+	}
+	return middleware.ClientIPKey(cfg.ClientIPHeader, proxies)
+}
@@ -0,0 +1,257 @@
+//go:build integration
+
+// Integration tests against a real Postgres initialised with
+// db_init_scripts, run with make test-integration. The database is
+// configured like the app, through DB_* or DATABASE_URL.
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/handlers"
+	"frappuccino-alem/internal/service"
+	"frappuccino-alem/internal/store"
+
+	_ "github.com/lib/pq"
+)
+
+// newIntegrationServer serves the inventory, menu, order and search
+// endpoints wired as in Run.
+func newIntegrationServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := store.Connect(context.Background(), cfg.DB, logger)
+	if err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mux := http.NewServeMux()
+	inventoryStore := store.NewInventoryStore(db)
+	handlers.NewInventoryHandler(service.NewInventoryService(inventoryStore), logger).RegisterEndpoints(mux)
+	menuStore := store.NewMenuStore(db)
+	handlers.NewMenuHandler(service.NewMenuService(menuStore, inventoryStore), logger).RegisterEndpoints(mux)
+	orderStore := store.NewOrderStore(db)
+	orderStream := service.NewOrderStream(store.NewOrderEventListener(cfg.DB, logger), orderStore, logger)
+	handlers.NewOrderHandler(service.NewOrderService(inventoryStore, menuStore, orderStore), orderStream, logger).RegisterEndpoints(mux)
+	handlers.NewReportHandler(service.NewReportService(store.NewReportStore(db), cfg.Inventory.Valuation), logger).RegisterEndpoints(mux)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call sends body as JSON, checks the status and decodes the response into
+// out unless it is nil.
+func call(t *testing.T, srv *httptest.Server, method, path string, body any, status int, out any) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("%s %s: encode body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	} else if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, status, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s %s: decode response: %v: %s", method, path, err, raw)
+		}
+	}
+}
+
+// remove deletes what the test created, reporting only server errors: the
+// endpoints disagree about the status a successful delete returns.
+func remove(t *testing.T, srv *httptest.Server, path string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+path, nil)
+	if err != nil {
+		t.Errorf("DELETE %s: %v", path, err)
+		return
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Errorf("DELETE %s: %v", path, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		t.Errorf("DELETE %s: status %d", path, resp.StatusCode)
+	}
+}
+
+// searchOrderIDs returns the ids of the orders a search for text finds.
+func searchOrderIDs(t *testing.T, srv *httptest.Server, text string) []int {
+	t.Helper()
+
+	var result struct {
+		Orders []struct {
+			ID int `json:"id"`
+		} `json:"orders"`
+	}
+	call(t, srv, http.MethodGet, "/reports/search?filter=orders&pageSize=100&q="+url.QueryEscape(text), nil, http.StatusOK, &result)
+	ids := make([]int, len(result.Orders))
+	for i, o := range result.Orders {
+		ids[i] = o.ID
+	}
+	return ids
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearchFindsOrderByItemName(t *testing.T) {
+	srv := newIntegrationServer(t)
+
+	// words nothing else in the database contains
+	suffix := time.Now().UnixNano()
+	name := fmt.Sprintf("Zanzibarian Quokkaccino %d", suffix)
+	renamed := fmt.Sprintf("Tasmanian Wombatte %d", suffix)
+
+	var ingredient struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/inventory", map[string]any{
+		"name": "Search test beans", "quantity": 1000, "unit": "g", "price": 0.05,
+	}, http.StatusCreated, &ingredient)
+	t.Cleanup(func() { remove(t, srv, "/inventory/"+ingredient.ID) })
+
+	var ingredientID int64
+	fmt.Sscan(ingredient.ID, &ingredientID)
+	var menuItem struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/menu", map[string]any{
+		"name": name, "description": "integration test drink", "price": 4.5,
+		"ingredients": []map[string]any{{"item_id": ingredientID, "quantity": 10}},
+	}, http.StatusCreated, &menuItem)
+	t.Cleanup(func() { remove(t, srv, "/menu/"+menuItem.ID) })
+
+	var menuItemID int64
+	fmt.Sscan(menuItem.ID, &menuItemID)
+	var order struct {
+		ID int `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/orders", map[string]any{
+		"customer_name": "Search Test", "payment_method": "cash",
+		"menu_items": []map[string]any{{"id": menuItemID, "quantity": 1}},
+	}, http.StatusCreated, &order)
+	t.Cleanup(func() { remove(t, srv, fmt.Sprintf("/orders/%d", order.ID)) })
+
+	if ids := searchOrderIDs(t, srv, "Quokkaccino"); !containsID(ids, order.ID) {
+		t.Fatalf("search for the item name right after creating order %d found %v", order.ID, ids)
+	}
+
+	call(t, srv, http.MethodPatch, "/menu/"+menuItem.ID, map[string]any{"name": renamed}, http.StatusOK, nil)
+	if ids := searchOrderIDs(t, srv, "Wombatte"); !containsID(ids, order.ID) {
+		t.Fatalf("search for the new item name after renaming found %v, want order %d", ids, order.ID)
+	}
+	if ids := searchOrderIDs(t, srv, "Quokkaccino"); containsID(ids, order.ID) {
+		t.Fatalf("search for the old item name after renaming still finds order %d", order.ID)
+	}
+}
+
+func TestReindexSearch(t *testing.T) {
+	srv := newIntegrationServer(t)
+
+	var result struct {
+		Reindexed *int64 `json:"reindexed_orders"`
+	}
+	call(t, srv, http.MethodPost, "/search/reindex", nil, http.StatusOK, &result)
+	if result.Reindexed == nil {
+		t.Fatal("response has no reindexed_orders count")
+	}
+}
+
+func TestSearchSnippetsAndPaging(t *testing.T) {
+	srv := newIntegrationServer(t)
+
+	word := fmt.Sprintf("Quokkaccino%d", time.Now().UnixNano())
+	var ingredient struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/inventory", map[string]any{
+		"name": "Search test cream", "quantity": 1000, "unit": "ml", "price": 0.01,
+	}, http.StatusCreated, &ingredient)
+	t.Cleanup(func() { remove(t, srv, "/inventory/"+ingredient.ID) })
+
+	var ingredientID int64
+	fmt.Sscan(ingredient.ID, &ingredientID)
+	var menuItem struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/menu", map[string]any{
+		"name": word + ` <script>alert("x")</script>`, "description": "cream & sugar", "price": 4.5,
+		"ingredients": []map[string]any{{"item_id": ingredientID, "quantity": 10}},
+	}, http.StatusCreated, &menuItem)
+	t.Cleanup(func() { remove(t, srv, "/menu/"+menuItem.ID) })
+
+	var result struct {
+		MenuItems []struct {
+			Snippet string `json:"snippet"`
+		} `json:"menu_items"`
+		Total int `json:"menu_items_total"`
+	}
+	path := "/reports/search?filter=menu&q=" + url.QueryEscape(word)
+	call(t, srv, http.MethodGet, path, nil, http.StatusOK, &result)
+	if len(result.MenuItems) != 1 {
+		t.Fatalf("search for %s found %d menu items, want 1", word, len(result.MenuItems))
+	}
+	snippet := result.MenuItems[0].Snippet
+	if !strings.Contains(snippet, "<mark>"+word+"</mark>") {
+		t.Errorf("snippet %q does not mark %s", snippet, word)
+	}
+	if strings.Contains(snippet, "<script") || !strings.Contains(snippet, "&lt;script&gt;") {
+		t.Errorf("snippet %q does not escape the name", snippet)
+	}
+
+	call(t, srv, http.MethodGet, path+"&page=5", nil, http.StatusOK, &result)
+	if len(result.MenuItems) != 0 || result.Total != 1 {
+		t.Errorf("page past the end has %d menu items of %d, want none of 1", len(result.MenuItems), result.Total)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build integration
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOrdersNeverUseExpiredStock(t *testing.T) {
+	srv := newIntegrationServer(t)
+
+	var ingredient struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/inventory", map[string]any{
+		"name": fmt.Sprintf("Expiry test milk %d", time.Now().UnixNano()), "quantity": 0, "unit": "ml", "price": 0.01,
+	}, http.StatusCreated, &ingredient)
+	t.Cleanup(func() { remove(t, srv, "/inventory/"+ingredient.ID) })
+
+	now := time.Now().UTC()
+	call(t, srv, http.MethodPost, "/inventory/"+ingredient.ID+"/batches", map[string]any{
+		"quantity": 100, "received_at": now.Add(-48 * time.Hour), "expires_at": now.Add(-24 * time.Hour),
+	}, http.StatusCreated, nil)
+
+	var ingredientID int64
+	fmt.Sscan(ingredient.ID, &ingredientID)
+	var menuItem struct {
+		ID string `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/menu", map[string]any{
+		"name": "Expiry test latte", "description": "integration test drink", "price": 3,
+		"ingredients": []map[string]any{{"item_id": ingredientID, "quantity": 10}},
+	}, http.StatusCreated, &menuItem)
+	t.Cleanup(func() { remove(t, srv, "/menu/"+menuItem.ID) })
+
+	var menuItemID int64
+	fmt.Sscan(menuItem.ID, &menuItemID)
+	var order struct {
+		ID int `json:"id"`
+	}
+	call(t, srv, http.MethodPost, "/orders", map[string]any{
+		"customer_name": "Expiry Test", "payment_method": "cash",
+		"menu_items": []map[string]any{{"id": menuItemID, "quantity": 1}},
+	}, http.StatusCreated, &order)
+	t.Cleanup(func() { remove(t, srv, fmt.Sprintf("/orders/%d", order.ID)) })
+
+	// only expired stock is left
+	closePath := fmt.Sprintf("/orders/%d/close", order.ID)
+	call(t, srv, http.MethodPost, closePath, nil, http.StatusConflict, nil)
+
+	call(t, srv, http.MethodPost, "/inventory/"+ingredient.ID+"/batches", map[string]any{
+		"quantity": 50, "expires_at": now.Add(24 * time.Hour),
+	}, http.StatusCreated, nil)
+	call(t, srv, http.MethodPost, closePath, nil, http.StatusOK, nil)
+
+	var batches []struct {
+		Expired           bool    `json:"expired"`
+		RemainingQuantity float64 `json:"remaining_quantity"`
+	}
+	call(t, srv, http.MethodGet, "/inventory/"+ingredient.ID+"/batches", nil, http.StatusOK, &batches)
+	for _, b := range batches {
+		want := 40.0
+		if b.Expired {
+			want = 0
+		}
+		if b.RemainingQuantity != want {
+			t.Errorf("batch (expired %v) has %v left, want %v", b.Expired, b.RemainingQuantity, want)
+		}
+	}
+	var item struct {
+		Quantity float64 `json:"quantity"`
+	}
+	call(t, srv, http.MethodGet, "/inventory/"+ingredient.ID, nil, http.StatusOK, &item)
+	if item.Quantity != 40 {
+		t.Errorf("quantity after the order = %v, want 40 with the expired stock written off", item.Quantity)
+	}
+}
@@ -1,54 +1,574 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Server Server
-	DB     DataBase
+	Server    Server
+	DB        DataBase
+	Log       Log
+	Webhooks  Webhooks
+	Jobs      Jobs
+	Inventory Inventory
+	RateLimit RateLimit
+	CORS      CORS
+	Security  Security
+	Cache     Cache
 }
 
 type Server struct {
-	Address string
-	Port    string
+	Address        string
+	Port           string
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	RequestTimeout time.Duration
+	IdempotencyTTL time.Duration
+	// MaxBodyBytes caps the size of a request body; larger requests are
+	// answered with 413.
+	MaxBodyBytes int
+	// Compression gzips or deflates text and JSON responses for clients
+	// that accept it.
+	Compression bool
+	// ShutdownTimeout bounds how long in-flight requests and jobs may take
+	// to finish once a shutdown signal arrives.
+	ShutdownTimeout time.Duration
 }
 
 type DataBase struct {
+	// URL is a full postgres:// DSN. When set it takes the place of the
+	// individual connection fields below, except SSLMode and SSLRootCert,
+	// which are added to it when it does not carry them itself.
+	URL        string
 	DBUser     string
 	DBPassword string
 	DBHost     string
 	DBPort     string
 	DBName     string
+	// SSLMode left empty means disable, or whatever URL says.
+	SSLMode         string
+	SSLRootCert     string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectRetries  int
+	ConnectBackoff  time.Duration
+	MaxBackoff      time.Duration
 }
 
-func Load() Config {
+type Log struct {
+	Level string
+}
+
+// Webhooks tunes the background dispatcher that sends outbox events to
+// webhook subscribers.
+type Webhooks struct {
+	PollInterval   time.Duration
+	Timeout        time.Duration
+	BatchSize      int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Jobs tunes the background job runner.
+type Jobs struct {
+	Workers      int
+	PollInterval time.Duration
+	// JobTimeout is the deadline of a single run. Jobs still marked running
+	// well past it are assumed lost and put back in the queue.
+	JobTimeout  time.Duration
+	MaxAttempts int
+	// Retention is how long finished jobs are kept before being pruned.
+	Retention time.Duration
+}
+
+// RateLimit throttles clients with token buckets. Requests fall into one of
+// three groups, each with buckets of its own: search (full-text search and
+// suggestions), write (any request that is not a GET) and read
+// (everything else). A group refills at PerMinute requests a minute and
+// allows bursts of Burst requests; a PerMinute of 0 leaves it unlimited.
+type RateLimit struct {
+	// Backend keeps the buckets: memory for a single instance, postgres
+	// to share them between instances, or off.
+	Backend string
+	// Key counts requests per client address (ip) or per X-Staff-ID,
+	// falling back to the address (staff).
+	Key string
+	// ClientIPHeader is the header a reverse proxy puts the client address
+	// in, such as X-Forwarded-For. Empty uses the connection's address.
+	ClientIPHeader string
+	// TrustedProxies are the addresses or CIDR ranges of the gateways
+	// allowed to set ClientIPHeader and X-Staff-ID. Both headers are
+	// ignored on requests from anywhere else, so either needs at least one.
+	TrustedProxies  []string
+	SearchPerMinute int
+	SearchBurst     int
+	WritePerMinute  int
+	WriteBurst      int
+	ReadPerMinute   int
+	ReadBurst       int
+}
+
+// CORS lets browser clients on other origins, such as the web POS, call the
+// API. It is off while AllowedOrigins is empty.
+type CORS struct {
+	// AllowedOrigins are matched exactly, "*" allows any origin.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long a browser may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// Security sets the security headers sent with every response.
+type Security struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security; 0 leaves the
+	// header out, for deployments not served over HTTPS.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	FrameOptions          string
+}
+
+// Cache sets how long clients may reuse GET responses without asking
+// again. Zero makes them revalidate every time, which the ETags keep cheap.
+// Orders are never cached, whatever is set here.
+type Cache struct {
+	MenuMaxAge    time.Duration
+	ReportsMaxAge time.Duration
+}
+
+// Inventory configures how stock is costed.
+type Inventory struct {
+	// Valuation is the default costing method of valuation and COGS
+	// reports: fifo or weighted_average.
+	Valuation string
+}
+
+// Default returns the configuration used when no file, env var or flag
+// overrides a value. There is deliberately no default database password.
+func Default() Config {
 	return Config{
-		Server{
-			Address: getEnv("ADDRESS", ""),
-			Port:    getEnv("PORT", "8080"),
+		Server: Server{
+			Address:         "",
+			Port:            "8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    20 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			RequestTimeout:  15 * time.Second,
+			IdempotencyTTL:  24 * time.Hour,
+			MaxBodyBytes:    1 << 20,
+			Compression:     true,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		DB: DataBase{
+			DBUser:          "postgres",
+			DBHost:          "db",
+			DBPort:          "5432",
+			DBName:          "frappuccino",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectRetries:  10,
+			ConnectBackoff:  500 * time.Millisecond,
+			MaxBackoff:      10 * time.Second,
+		},
+		Log: Log{
+			Level: "debug",
+		},
+		Webhooks: Webhooks{
+			PollInterval:   2 * time.Second,
+			Timeout:        10 * time.Second,
+			BatchSize:      50,
+			MaxAttempts:    10,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     time.Hour,
+		},
+		Jobs: Jobs{
+			Workers:      4,
+			PollInterval: time.Second,
+			JobTimeout:   5 * time.Minute,
+			MaxAttempts:  5,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Inventory: Inventory{
+			Valuation: "weighted_average",
 		},
-		DataBase{
-			DBUser:     getEnv("DB_USER", "postgres"),
-			DBPassword: getEnv("DB_PASSWORD", "latte"),
-			DBHost:     getEnv("DB_HOST", "db"),
-			DBPort:     getEnv("DB_PORT", "5432"),
-			DBName:     getEnv("DB_NAME", "frappuccino"),
+		RateLimit: RateLimit{
+			Backend:         "memory",
+			Key:             "ip",
+			TrustedProxies:  []string{},
+			SearchPerMinute: 60,
+			SearchBurst:     10,
+			WritePerMinute:  120,
+			WriteBurst:      30,
+			ReadPerMinute:   600,
+			ReadBurst:       100,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "If-Match", "If-None-Match", "Idempotency-Key", "X-Staff-ID"},
+			ExposedHeaders: []string{"ETag", "Content-Disposition", "Idempotent-Replayed", "Retry-After",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+			MaxAge: 10 * time.Minute,
+		},
+		Security: Security{
+			HSTSMaxAge:   365 * 24 * time.Hour,
+			FrameOptions: "DENY",
+		},
+		Cache: Cache{
+			MenuMaxAge: 30 * time.Second,
 		},
 	}
 }
 
+// Load builds the configuration from, in increasing order of precedence:
+// built-in defaults, a TOML file (-config flag or CONFIG_FILE env var),
+// environment variables and command-line flags. The result is validated.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	flagValues, configPath, err := parseFlags(args)
+	if err != nil {
+		return cfg, err
+	}
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_FILE")
+	}
+
+	if configPath != "" {
+		fileValues, err := readFile(configPath)
+		if err != nil {
+			return cfg, err
+		}
+		if err := apply(&cfg, fileValues, "config file"); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := apply(&cfg, envValues(), "environment"); err != nil {
+		return cfg, err
+	}
+	if err := apply(&cfg, flagValues, "flag"); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once instead of stopping at the first.
+func (c Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %q is not a valid port", c.Server.Port))
+	}
+	if c.Server.ReadTimeout < 0 {
+		errs = append(errs, errors.New("server.read_timeout: must not be negative"))
+	}
+	if c.Server.WriteTimeout < 0 {
+		errs = append(errs, errors.New("server.write_timeout: must not be negative"))
+	}
+	if c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server.idle_timeout: must not be negative"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("server.request_timeout: must be greater than zero"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("server.idempotency_ttl: must be greater than zero"))
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("server.max_body_bytes: must be greater than zero"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout: must be greater than zero"))
+	}
+
+	if c.DB.URL != "" {
+		if u, err := url.Parse(c.DB.URL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			errs = append(errs, errors.New("db.url: must be a postgres:// or postgresql:// URL"))
+		} else {
+			// the same setting in two places is ambiguous, refuse to guess
+			query := u.Query()
+			if v := query.Get("sslmode"); v != "" && c.DB.SSLMode != "" && v != c.DB.SSLMode {
+				errs = append(errs, fmt.Errorf("db.sslmode: %q conflicts with sslmode=%s in db.url, set it in one place", c.DB.SSLMode, v))
+			}
+			if v := query.Get("sslrootcert"); v != "" && c.DB.SSLRootCert != "" && v != c.DB.SSLRootCert {
+				errs = append(errs, fmt.Errorf("db.sslrootcert: %q conflicts with sslrootcert=%s in db.url, set it in one place", c.DB.SSLRootCert, v))
+			}
+		}
+	} else {
+		if c.DB.DBHost == "" {
+			errs = append(errs, errors.New("db.host: is required"))
+		}
+		if port, err := strconv.Atoi(c.DB.DBPort); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("db.port: %q is not a valid port", c.DB.DBPort))
+		}
+		if c.DB.DBUser == "" {
+			errs = append(errs, errors.New("db.user: is required"))
+		}
+		if c.DB.DBPassword == "" {
+			errs = append(errs, errors.New("db.password: is required"))
+		}
+		if c.DB.DBName == "" {
+			errs = append(errs, errors.New("db.name: is required"))
+		}
+	}
+	switch c.DB.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("db.sslmode: unsupported value %q", c.DB.SSLMode))
+	}
+	if c.DB.SSLRootCert != "" {
+		if _, err := os.Stat(c.DB.SSLRootCert); err != nil {
+			errs = append(errs, fmt.Errorf("db.sslrootcert: %w", err))
+		}
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("db.max_open_conns: must not be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db.max_idle_conns: must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("db.max_idle_conns: must not exceed db.max_open_conns"))
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("db.conn_max_lifetime: must not be negative"))
+	}
+	if c.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("db.conn_max_idle_time: must not be negative"))
+	}
+	if c.DB.ConnectRetries < 0 {
+		errs = append(errs, errors.New("db.connect_retries: must not be negative"))
+	}
+	if c.DB.ConnectBackoff <= 0 {
+		errs = append(errs, errors.New("db.connect_backoff: must be greater than zero"))
+	}
+	if c.DB.MaxBackoff < c.DB.ConnectBackoff {
+		errs = append(errs, errors.New("db.max_backoff: must not be less than db.connect_backoff"))
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+
+	if c.Webhooks.PollInterval <= 0 {
+		errs = append(errs, errors.New("webhooks.poll_interval: must be greater than zero"))
+	}
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout: must be greater than zero"))
+	}
+	if c.Webhooks.BatchSize < 1 {
+		errs = append(errs, errors.New("webhooks.batch_size: must be at least 1"))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.max_attempts: must be at least 1"))
+	}
+	if c.Webhooks.InitialBackoff <= 0 {
+		errs = append(errs, errors.New("webhooks.initial_backoff: must be greater than zero"))
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		errs = append(errs, errors.New("webhooks.max_backoff: must not be less than webhooks.initial_backoff"))
+	}
+
+	if c.Jobs.Workers < 1 {
+		errs = append(errs, errors.New("jobs.workers: must be at least 1"))
+	}
+	if c.Jobs.PollInterval <= 0 {
+		errs = append(errs, errors.New("jobs.poll_interval: must be greater than zero"))
+	}
+	if c.Jobs.JobTimeout <= 0 {
+		errs = append(errs, errors.New("jobs.job_timeout: must be greater than zero"))
+	}
+	if c.Jobs.MaxAttempts < 1 {
+		errs = append(errs, errors.New("jobs.max_attempts: must be at least 1"))
+	}
+	if c.Jobs.Retention <= 0 {
+		errs = append(errs, errors.New("jobs.retention: must be greater than zero"))
+	}
+
+	switch c.Inventory.Valuation {
+	case "fifo", "weighted_average":
+	default:
+		errs = append(errs, fmt.Errorf("inventory.valuation: unsupported value %q, must be fifo or weighted_average", c.Inventory.Valuation))
+	}
+
+	switch c.RateLimit.Backend {
+	case "memory", "postgres", "off":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.backend: unsupported value %q, must be memory, postgres or off", c.RateLimit.Backend))
+	}
+	switch c.RateLimit.Key {
+	case "ip", "staff":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.key: unsupported value %q, must be ip or staff", c.RateLimit.Key))
+	}
+	if proxies, err := c.RateLimit.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %w", err))
+	} else if len(proxies) == 0 {
+		if c.RateLimit.Key == "staff" {
+			errs = append(errs, errors.New("rate_limit.trusted_proxies: the staff key needs the gateways that set X-Staff-ID"))
+		}
+		if c.RateLimit.ClientIPHeader != "" {
+			errs = append(errs, errors.New("rate_limit.trusted_proxies: rate_limit.client_ip_header needs the proxies that set it"))
+		}
+	}
+	for _, g := range []struct {
+		name             string
+		perMinute, burst int
+	}{
+		{"search", c.RateLimit.SearchPerMinute, c.RateLimit.SearchBurst},
+		{"write", c.RateLimit.WritePerMinute, c.RateLimit.WriteBurst},
+		{"read", c.RateLimit.ReadPerMinute, c.RateLimit.ReadBurst},
+	} {
+		if g.perMinute < 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.%s_per_minute: must not be negative", g.name))
+		}
+		if g.perMinute > 0 && g.burst < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.%s_burst: must be at least 1", g.name))
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowed_origins: * cannot be combined with cors.allow_credentials, list the origins"))
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins: %q is not an origin such as https://pos.example.com", origin))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age: must not be negative"))
+	}
+	if c.Security.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("security.hsts_max_age: must not be negative"))
+	}
+	switch c.Security.FrameOptions {
+	case "DENY", "SAMEORIGIN":
+	default:
+		errs = append(errs, fmt.Errorf("security.frame_options: unsupported value %q, must be DENY or SAMEORIGIN", c.Security.FrameOptions))
+	}
+
+	if c.Cache.MenuMaxAge < 0 {
+		errs = append(errs, errors.New("cache.menu_max_age: must not be negative"))
+	}
+	if c.Cache.ReportsMaxAge < 0 {
+		errs = append(errs, errors.New("cache.reports_max_age: must not be negative"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted returns every setting keyed by its file key with secrets masked,
+// suitable for logging at startup.
+func (c Config) Redacted() map[string]string {
+	dump := make(map[string]string, len(fields))
+	for _, f := range fields {
+		value := f.get(&c)
+		if f.redact != nil && value != "" {
+			value = f.redact(value)
+		}
+		dump[f.key] = value
+	}
+	return dump
+}
+
+func (l Log) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(l.Level))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown level %q", l.Level)
+	}
+	return level, nil
+}
+
+// TrustedProxyPrefixes parses TrustedProxies; a bare address is a range of
+// its own.
+func (r RateLimit) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(r.TrustedProxies))
+	for _, proxy := range r.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR range", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// MakeConnectionString returns the DSN handed to the postgres driver: the
+// configured URL with SSLMode and SSLRootCert added unless it has its own,
+// or a key/value string built from the separate fields.
 func (d *DataBase) MakeConnectionString() string {
-	return fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		d.DBHost, d.DBPort, d.DBUser, d.DBPassword, d.DBName,
-	)
+	if d.URL != "" {
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			return d.URL
+		}
+		query := u.Query()
+		if d.SSLMode != "" && query.Get("sslmode") == "" {
+			query.Set("sslmode", d.SSLMode)
+		}
+		if d.SSLRootCert != "" && query.Get("sslrootcert") == "" {
+			query.Set("sslrootcert", d.SSLRootCert)
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	sslMode := d.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := []string{
+		"host=" + quoteDSNValue(d.DBHost),
+		"port=" + quoteDSNValue(d.DBPort),
+		"user=" + quoteDSNValue(d.DBUser),
+		"password=" + quoteDSNValue(d.DBPassword),
+		"dbname=" + quoteDSNValue(d.DBName),
+		"sslmode=" + quoteDSNValue(sslMode),
+	}
+	if d.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteDSNValue(d.SSLRootCert))
+	}
+	return strings.Join(params, " ")
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// quoteDSNValue quotes a key/value DSN value when it is empty or contains
+// characters libpq would otherwise treat as separators.
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
 	}
-	return fallback
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
 }
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "sections, bare and quoted values",
+			doc:  "[server]\nport = 9090\naddress = \"127.0.0.1\"\n\n[db]\nmax_open_conns = 5\n",
+			want: map[string]string{"server.port": "9090", "server.address": "127.0.0.1", "db.max_open_conns": "5"},
+		},
+		{
+			name: "escapes in strings",
+			doc:  "[db]\npassword = \"say \\\"hi\\\" \\\\ \\t\"\n",
+			want: map[string]string{"db.password": "say \"hi\" \\ \t"},
+		},
+		{
+			name: "comments, # inside strings",
+			doc:  "# leading\n[db] # trailing\npassword = \"p#ss\" # the # in the value stays\nuser = \"a\\\\\" # after an escaped backslash\n",
+			want: map[string]string{"db.password": "p#ss", "db.user": `a\`},
+		},
+		{
+			name: "arrays",
+			doc:  "[cors]\nallowed_origins = [\"https://a.example\", \"https://b.example\"]\nallowed_methods = []\n",
+			want: map[string]string{"cors.allowed_origins": "https://a.example,https://b.example", "cors.allowed_methods": ""},
+		},
+		{
+			name: "array over several lines",
+			doc:  "[cors]\nexposed_headers = [\n  \"ETag\", # entity tags\n  \"Retry-After\",\n]\nmax_age = \"5m\"\n",
+			want: map[string]string{"cors.exposed_headers": "ETag,Retry-After", "cors.max_age": "5m"},
+		},
+		{
+			name: "a list may still be a string",
+			doc:  "[rate_limit]\ntrusted_proxies = \"10.0.0.0/8, 192.0.2.1\"\n",
+			want: map[string]string{"rate_limit.trusted_proxies": "10.0.0.0/8, 192.0.2.1"},
+		},
+		{
+			name:    "unknown key",
+			doc:     "[server]\nport = 1\nprot = 2\n",
+			wantErr: `line 3: unknown key "server.prot"`,
+		},
+		{
+			name:    "key outside its section",
+			doc:     "port = 1\n",
+			wantErr: `line 1: unknown key "port"`,
+		},
+		{
+			name:    "array for a single value",
+			doc:     "[server]\nport = [1, 2]\n",
+			wantErr: "line 2: server.port takes a single value, not an array",
+		},
+		{
+			name:    "comma inside an array item",
+			doc:     "[cors]\nallowed_origins = [\"a,b\"]\n",
+			wantErr: `line 2: array item "a,b" cannot contain a comma`,
+		},
+		{
+			name:    "unterminated array",
+			doc:     "[cors]\nallowed_origins = [\"a\",\n",
+			wantErr: "line 2: unterminated array",
+		},
+		{
+			name:    "unterminated string",
+			doc:     "[db]\npassword = \"secret\n",
+			wantErr: "line 2: invalid string value",
+		},
+		{
+			name:    "missing value",
+			doc:     "[db]\npassword\n",
+			wantErr: "line 2: expected key = value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(strings.NewReader(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseTOML() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTOML() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// clearEnv unsets every variable the config reads for the duration of the
+// test, so the environment the tests run in cannot leak into them.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range append([]string{"CONFIG_FILE"}, envNames()...) {
+		if v, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			t.Cleanup(func() { os.Setenv(env, v) })
+		}
+	}
+}
+
+func envNames() []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.env
+	}
+	return names
+}
+
+func writeConfig(t *testing.T, doc string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+[server]
+port = "1001"
+address = "file"
+request_timeout = "1s"
+
+[db]
+password = "from file"
+`)
+	t.Setenv("PORT", "2002")
+	t.Setenv("SERVER_REQUEST_TIMEOUT", "2s")
+
+	cfg, err := Load([]string{"-config", path, "-request-timeout", "3s"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Address != "file" {
+		t.Errorf("address = %q, want the file's", cfg.Server.Address)
+	}
+	if cfg.Server.Port != "2002" {
+		t.Errorf("port = %q, want the environment's over the file's", cfg.Server.Port)
+	}
+	if got := cfg.Server.RequestTimeout.String(); got != "3s" {
+		t.Errorf("request_timeout = %s, want the flag's over the environment's", got)
+	}
+	if cfg.DB.DBPassword != "from file" {
+		t.Errorf("db.password = %q, want the file's", cfg.DB.DBPassword)
+	}
+	if cfg.Server.WriteTimeout != Default().Server.WriteTimeout {
+		t.Errorf("write_timeout = %s, want the default", cfg.Server.WriteTimeout)
+	}
+}
+
+func TestLoadConfigFileFromEnvironment(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeConfig(t, "[db]\npassword = \"x\"\n[cors]\nallowed_origins = [\"https://pos.example\"]\n"))
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := []string{"https://pos.example"}; !reflect.DeepEqual(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("allowed_origins = %q, want %q", cfg.CORS.AllowedOrigins, want)
+	}
+}
+
+func TestLoadExampleConfig(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_PASSWORD", "x")
+
+	cfg, err := Load([]string{"-config", filepath.Join("..", "..", "config.example.toml")})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(cfg.CORS, Default().CORS) || !reflect.DeepEqual(cfg.RateLimit, Default().RateLimit) {
+		t.Errorf("the example config disagrees with the defaults:\n%+v\n%+v", cfg.CORS, cfg.RateLimit)
+	}
+}
+
+func TestLoadRejectsBadValues(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SERVER_READ_TIMEOUT", "soon")
+
+	_, err := Load([]string{"-db-password", "x"})
+	if want := `environment: server.read_timeout: expected a duration such as "15s", got "soon"`; err == nil || err.Error() != want {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = "0"
+	cfg.DB.URL = "postgres://latte@localhost/frappuccino?sslmode=require"
+	cfg.DB.SSLMode = "disable"
+	cfg.CORS.AllowedOrigins = []string{"*"}
+	cfg.CORS.AllowCredentials = true
+	cfg.RateLimit.Key = "staff"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{
+		`server.port: "0" is not a valid port`,
+		`db.sslmode: "disable" conflicts with sslmode=require in db.url, set it in one place`,
+		"cors.allowed_origins: * cannot be combined with cors.allow_credentials, list the origins",
+		"rate_limit.trusted_proxies: the staff key needs the gateways that set X-Staff-ID",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v\nwant it to report %q", err, want)
+		}
+	}
+
+	cfg = Default()
+	cfg.DB.DBPassword = "x"
+	cfg.DB.URL = "postgres://latte@localhost/frappuccino?sslmode=require"
+	cfg.DB.SSLMode = "require"
+	cfg.CORS.AllowedOrigins = []string{"*"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want agreeing sslmode and * without credentials accepted", err)
+	}
+}
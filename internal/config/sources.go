@@ -0,0 +1,467 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field describes a single setting and the names it goes by in each source.
+type field struct {
+	key    string // key in the config file, "section.name"
+	env    string
+	flag   string
+	usage  string
+	redact func(v string) string // masks the value in Config.Redacted, nil for plain values
+	list   bool                  // takes a TOML array as well as a comma-separated string
+	set    func(c *Config, v string) error
+	get    func(c *Config) string
+}
+
+var fields = []field{
+	stringField("server.address", "ADDRESS", "address", "address to listen on",
+		func(c *Config) *string { return &c.Server.Address }),
+	stringField("server.port", "PORT", "port", "port to listen on",
+		func(c *Config) *string { return &c.Server.Port }),
+	durationField("server.read_timeout", "SERVER_READ_TIMEOUT", "read-timeout", "maximum duration for reading a request",
+		func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationField("server.write_timeout", "SERVER_WRITE_TIMEOUT", "write-timeout", "maximum duration for writing a response",
+		func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationField("server.idle_timeout", "SERVER_IDLE_TIMEOUT", "idle-timeout", "keep-alive idle timeout",
+		func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	durationField("server.request_timeout", "SERVER_REQUEST_TIMEOUT", "request-timeout", "deadline applied to each request context",
+		func(c *Config) *time.Duration { return &c.Server.RequestTimeout }),
+	durationField("server.idempotency_ttl", "IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses to Idempotency-Key requests are kept",
+		func(c *Config) *time.Duration { return &c.Server.IdempotencyTTL }),
+	intField("server.max_body_bytes", "SERVER_MAX_BODY_BYTES", "max-body-bytes", "largest request body accepted, in bytes",
+		func(c *Config) *int { return &c.Server.MaxBodyBytes }),
+	boolField("server.compression", "SERVER_COMPRESSION", "compression", "gzip or deflate text and JSON responses",
+		func(c *Config) *bool { return &c.Server.Compression }),
+	durationField("server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "grace period for in-flight requests and jobs on shutdown",
+		func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+
+	urlField("db.url", "DATABASE_URL", "database-url", "full postgres:// DSN, overrides the other db.* connection settings",
+		func(c *Config) *string { return &c.DB.URL }),
+	stringField("db.user", "DB_USER", "db-user", "database user",
+		func(c *Config) *string { return &c.DB.DBUser }),
+	secretField("db.password", "DB_PASSWORD", "db-password", "database password",
+		func(c *Config) *string { return &c.DB.DBPassword }),
+	stringField("db.host", "DB_HOST", "db-host", "database host",
+		func(c *Config) *string { return &c.DB.DBHost }),
+	stringField("db.port", "DB_PORT", "db-port", "database port",
+		func(c *Config) *string { return &c.DB.DBPort }),
+	stringField("db.name", "DB_NAME", "db-name", "database name",
+		func(c *Config) *string { return &c.DB.DBName }),
+	stringField("db.sslmode", "DB_SSLMODE", "db-sslmode", "postgres sslmode, also applied to db.url when it has none",
+		func(c *Config) *string { return &c.DB.SSLMode }),
+	stringField("db.sslrootcert", "DB_SSLROOTCERT", "db-sslrootcert", "path to the CA certificate used for verify-ca/verify-full",
+		func(c *Config) *string { return &c.DB.SSLRootCert }),
+	intField("db.max_open_conns", "DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open connections (0 = unlimited)",
+		func(c *Config) *int { return &c.DB.MaxOpenConns }),
+	intField("db.max_idle_conns", "DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle connections",
+		func(c *Config) *int { return &c.DB.MaxIdleConns }),
+	durationField("db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum time a connection may be reused (0 = forever)",
+		func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime }),
+	durationField("db.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum time a connection may sit idle (0 = forever)",
+		func(c *Config) *time.Duration { return &c.DB.ConnMaxIdleTime }),
+	intField("db.connect_retries", "DB_CONNECT_RETRIES", "db-connect-retries", "extra attempts to reach the database on startup",
+		func(c *Config) *int { return &c.DB.ConnectRetries }),
+	durationField("db.connect_backoff", "DB_CONNECT_BACKOFF", "db-connect-backoff", "initial delay between startup connection attempts",
+		func(c *Config) *time.Duration { return &c.DB.ConnectBackoff }),
+	durationField("db.max_backoff", "DB_MAX_BACKOFF", "db-max-backoff", "upper bound for the startup retry delay",
+		func(c *Config) *time.Duration { return &c.DB.MaxBackoff }),
+
+	stringField("log.level", "LOG_LEVEL", "log-level", "debug, info, warn or error",
+		func(c *Config) *string { return &c.Log.Level }),
+
+	durationField("webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", "webhook-poll-interval", "how often the dispatcher looks for new events and due deliveries",
+		func(c *Config) *time.Duration { return &c.Webhooks.PollInterval }),
+	durationField("webhooks.timeout", "WEBHOOK_TIMEOUT", "webhook-timeout", "timeout for a single delivery request",
+		func(c *Config) *time.Duration { return &c.Webhooks.Timeout }),
+	intField("webhooks.batch_size", "WEBHOOK_BATCH_SIZE", "webhook-batch-size", "events and deliveries handled per poll",
+		func(c *Config) *int { return &c.Webhooks.BatchSize }),
+	intField("webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts before a delivery is marked failed",
+		func(c *Config) *int { return &c.Webhooks.MaxAttempts }),
+	durationField("webhooks.initial_backoff", "WEBHOOK_INITIAL_BACKOFF", "webhook-initial-backoff", "delay before the first retry, doubled on every further one",
+		func(c *Config) *time.Duration { return &c.Webhooks.InitialBackoff }),
+	durationField("webhooks.max_backoff", "WEBHOOK_MAX_BACKOFF", "webhook-max-backoff", "upper bound for the retry delay",
+		func(c *Config) *time.Duration { return &c.Webhooks.MaxBackoff }),
+
+	intField("jobs.workers", "JOBS_WORKERS", "jobs-workers", "number of concurrent job workers",
+		func(c *Config) *int { return &c.Jobs.Workers }),
+	durationField("jobs.poll_interval", "JOBS_POLL_INTERVAL", "jobs-poll-interval", "how often idle workers look for due jobs",
+		func(c *Config) *time.Duration { return &c.Jobs.PollInterval }),
+	durationField("jobs.job_timeout", "JOBS_JOB_TIMEOUT", "jobs-job-timeout", "deadline for a single job run",
+		func(c *Config) *time.Duration { return &c.Jobs.JobTimeout }),
+	intField("jobs.max_attempts", "JOBS_MAX_ATTEMPTS", "jobs-max-attempts", "default attempts before a job is dead-lettered",
+		func(c *Config) *int { return &c.Jobs.MaxAttempts }),
+	durationField("jobs.retention", "JOBS_RETENTION", "jobs-retention", "how long succeeded jobs are kept",
+		func(c *Config) *time.Duration { return &c.Jobs.Retention }),
+	stringField("inventory.valuation", "INVENTORY_VALUATION", "inventory-valuation", "default stock costing method: fifo or weighted_average",
+		func(c *Config) *string { return &c.Inventory.Valuation }),
+
+	stringField("rate_limit.backend", "RATE_LIMIT_BACKEND", "rate-limit-backend", "where token buckets are kept: memory, postgres or off",
+		func(c *Config) *string { return &c.RateLimit.Backend }),
+	stringField("rate_limit.key", "RATE_LIMIT_KEY", "rate-limit-key", "count requests per client address (ip) or per X-Staff-ID (staff)",
+		func(c *Config) *string { return &c.RateLimit.Key }),
+	stringField("rate_limit.client_ip_header", "RATE_LIMIT_CLIENT_IP_HEADER", "rate-limit-client-ip-header", "header a reverse proxy puts the client address in, such as X-Forwarded-For",
+		func(c *Config) *string { return &c.RateLimit.ClientIPHeader }),
+	listField("rate_limit.trusted_proxies", "RATE_LIMIT_TRUSTED_PROXIES", "rate-limit-trusted-proxies", "addresses or CIDR ranges of the gateways allowed to set the client IP header and X-Staff-ID, comma-separated",
+		func(c *Config) *[]string { return &c.RateLimit.TrustedProxies }),
+	intField("rate_limit.search_per_minute", "RATE_LIMIT_SEARCH_PER_MINUTE", "rate-limit-search-per-minute", "search requests a client may make per minute (0 = unlimited)",
+		func(c *Config) *int { return &c.RateLimit.SearchPerMinute }),
+	intField("rate_limit.search_burst", "RATE_LIMIT_SEARCH_BURST", "rate-limit-search-burst", "search requests a client may make at once",
+		func(c *Config) *int { return &c.RateLimit.SearchBurst }),
+	intField("rate_limit.write_per_minute", "RATE_LIMIT_WRITE_PER_MINUTE", "rate-limit-write-per-minute", "write requests a client may make per minute (0 = unlimited)",
+		func(c *Config) *int { return &c.RateLimit.WritePerMinute }),
+	intField("rate_limit.write_burst", "RATE_LIMIT_WRITE_BURST", "rate-limit-write-burst", "write requests a client may make at once",
+		func(c *Config) *int { return &c.RateLimit.WriteBurst }),
+	intField("rate_limit.read_per_minute", "RATE_LIMIT_READ_PER_MINUTE", "rate-limit-read-per-minute", "other requests a client may make per minute (0 = unlimited)",
+		func(c *Config) *int { return &c.RateLimit.ReadPerMinute }),
+	intField("rate_limit.read_burst", "RATE_LIMIT_READ_BURST", "rate-limit-read-burst", "other requests a client may make at once",
+		func(c *Config) *int { return &c.RateLimit.ReadBurst }),
+
+	listField("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "origins browsers may call the API from, comma-separated, * for any (empty = CORS off)",
+		func(c *Config) *[]string { return &c.CORS.AllowedOrigins }),
+	listField("cors.allowed_methods", "CORS_ALLOWED_METHODS", "cors-allowed-methods", "methods cross-origin requests may use, comma-separated",
+		func(c *Config) *[]string { return &c.CORS.AllowedMethods }),
+	listField("cors.allowed_headers", "CORS_ALLOWED_HEADERS", "cors-allowed-headers", "request headers cross-origin requests may send, comma-separated",
+		func(c *Config) *[]string { return &c.CORS.AllowedHeaders }),
+	listField("cors.exposed_headers", "CORS_EXPOSED_HEADERS", "cors-exposed-headers", "response headers browsers let cross-origin callers read, comma-separated",
+		func(c *Config) *[]string { return &c.CORS.ExposedHeaders }),
+	boolField("cors.allow_credentials", "CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "let cross-origin requests carry cookies and authorization",
+		func(c *Config) *bool { return &c.CORS.AllowCredentials }),
+	durationField("cors.max_age", "CORS_MAX_AGE", "cors-max-age", "how long browsers may cache a preflight response",
+		func(c *Config) *time.Duration { return &c.CORS.MaxAge }),
+
+	durationField("security.hsts_max_age", "SECURITY_HSTS_MAX_AGE", "security-hsts-max-age", "max-age of the Strict-Transport-Security header (0 = no header)",
+		func(c *Config) *time.Duration { return &c.Security.HSTSMaxAge }),
+	boolField("security.hsts_include_subdomains", "SECURITY_HSTS_INCLUDE_SUBDOMAINS", "security-hsts-include-subdomains", "extend Strict-Transport-Security to subdomains",
+		func(c *Config) *bool { return &c.Security.HSTSIncludeSubdomains }),
+	stringField("security.frame_options", "SECURITY_FRAME_OPTIONS", "security-frame-options", "X-Frame-Options value: DENY or SAMEORIGIN",
+		func(c *Config) *string { return &c.Security.FrameOptions }),
+
+	durationField("cache.menu_max_age", "CACHE_MENU_MAX_AGE", "cache-menu-max-age", "how long clients may reuse menu responses (0 = always revalidate)",
+		func(c *Config) *time.Duration { return &c.Cache.MenuMaxAge }),
+	durationField("cache.reports_max_age", "CACHE_REPORTS_MAX_AGE", "cache-reports-max-age", "how long clients may reuse report and search responses (0 = always revalidate)",
+		func(c *Config) *time.Duration { return &c.Cache.ReportsMaxAge }),
+}
+
+func stringField(key, env, flagName, usage string, ptr func(c *Config) *string) field {
+	return field{
+		key: key, env: env, flag: flagName, usage: usage,
+		set: func(c *Config, v string) error { *ptr(c) = v; return nil },
+		get: func(c *Config) string { return *ptr(c) },
+	}
+}
+
+func secretField(key, env, flagName, usage string, ptr func(c *Config) *string) field {
+	f := stringField(key, env, flagName, usage, ptr)
+	f.redact = func(string) string { return "******" }
+	return f
+}
+
+// urlField keeps the URL readable in dumps and masks only its password.
+func urlField(key, env, flagName, usage string, ptr func(c *Config) *string) field {
+	f := stringField(key, env, flagName, usage, ptr)
+	f.redact = func(v string) string {
+		u, err := url.Parse(v)
+		if err != nil {
+			return "******"
+		}
+		return u.Redacted()
+	}
+	return f
+}
+
+func intField(key, env, flagName, usage string, ptr func(c *Config) *int) field {
+	return field{
+		key: key, env: env, flag: flagName, usage: usage,
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("expected an integer, got %q", v)
+			}
+			*ptr(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*ptr(c)) },
+	}
+}
+
+func boolField(key, env, flagName, usage string, ptr func(c *Config) *bool) field {
+	return field{
+		key: key, env: env, flag: flagName, usage: usage,
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			*ptr(c) = b
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(*ptr(c)) },
+	}
+}
+
+// listField reads a comma-separated list; an empty value is an empty list.
+// The config file may give it as an array instead.
+func listField(key, env, flagName, usage string, ptr func(c *Config) *[]string) field {
+	return field{
+		key: key, env: env, flag: flagName, usage: usage, list: true,
+		set: func(c *Config, v string) error {
+			list := []string{}
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			*ptr(c) = list
+			return nil
+		},
+		get: func(c *Config) string { return strings.Join(*ptr(c), ",") },
+	}
+}
+
+func durationField(key, env, flagName, usage string, ptr func(c *Config) *time.Duration) field {
+	return field{
+		key: key, env: env, flag: flagName, usage: usage,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("expected a duration such as \"15s\", got %q", v)
+			}
+			*ptr(c) = d
+			return nil
+		},
+		get: func(c *Config) string { return ptr(c).String() },
+	}
+}
+
+// apply sets every value found in a source, keyed by file key.
+func apply(cfg *Config, values map[string]string, source string) error {
+	for _, f := range fields {
+		v, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(cfg, v); err != nil {
+			return fmt.Errorf("%s: %s: %w", source, f.key, err)
+		}
+	}
+	return nil
+}
+
+func envValues() map[string]string {
+	values := make(map[string]string)
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok {
+			values[f.key] = v
+		}
+	}
+	return values
+}
+
+// parseFlags returns only the flags that were passed explicitly, so unset
+// flags never override values coming from the file or the environment.
+func parseFlags(args []string) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("frappuccino", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a TOML config file")
+
+	raw := make(map[string]*string, len(fields))
+	byFlag := make(map[string]string, len(fields))
+	for _, f := range fields {
+		raw[f.key] = fs.String(f.flag, "", f.usage)
+		byFlag[f.flag] = f.key
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	values := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		if key, ok := byFlag[fl.Name]; ok {
+			values[key] = *raw[key]
+		}
+	})
+	return values, *configPath, nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseTOML(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// parseTOML understands the subset of TOML the config needs: [section]
+// headers, key = value pairs, quoted or bare scalar values, arrays of them
+// for list settings and # comments. Arrays may span lines and come back
+// joined with commas, the way lists are given everywhere else.
+func parseTOML(r io.Reader) (map[string]string, error) {
+	known := make(map[string]field, len(fields))
+	for _, f := range fields {
+		known[f.key] = f
+	}
+
+	values := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.TrimSpace(name)
+		if section != "" {
+			key = section + "." + key
+		}
+		f, ok := known[key]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "["):
+			if !f.list {
+				return nil, fmt.Errorf("line %d: %s takes a single value, not an array", lineNo, key)
+			}
+			start := lineNo
+			for !arrayClosed(value) && scanner.Scan() {
+				lineNo++
+				value += " " + strings.TrimSpace(stripComment(scanner.Text()))
+			}
+			items, err := parseArray(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", start, err)
+			}
+			value = strings.Join(items, ",")
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string value", lineNo)
+			}
+			value = unquoted
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseArray reads a one-dimensional array of quoted or bare values. Items
+// cannot contain commas, which separate them once joined.
+func parseArray(value string) ([]string, error) {
+	if !strings.HasSuffix(value, "]") {
+		return nil, errors.New("unterminated array")
+	}
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	items := []string{}
+	for rest != "" {
+		var item string
+		if strings.HasPrefix(rest, `"`) {
+			end := stringEnd(rest)
+			if end < 0 {
+				return nil, errors.New("unterminated string in array")
+			}
+			unquoted, err := strconv.Unquote(rest[:end])
+			if err != nil {
+				return nil, errors.New("invalid string in array")
+			}
+			item, rest = unquoted, strings.TrimSpace(rest[end:])
+			if rest != "" && !strings.HasPrefix(rest, ",") {
+				return nil, errors.New("expected , between array items")
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			item, rest, _ = strings.Cut(rest, ",")
+			if item = strings.TrimSpace(item); item == "" {
+				return nil, errors.New("empty array item")
+			}
+		}
+		if strings.Contains(item, ",") {
+			return nil, fmt.Errorf("array item %q cannot contain a comma", item)
+		}
+		items = append(items, item)
+		rest = strings.TrimSpace(rest)
+	}
+	return items, nil
+}
+
+// arrayClosed reports whether value holds the bracket closing the array it
+// starts.
+func arrayClosed(value string) bool {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			end := stringEnd(value[i:])
+			if end < 0 {
+				return false
+			}
+			i += end - 1
+		case ']':
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the length of the quoted string s starts with, quotes
+// included, or -1 when it is not terminated.
+func stringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case c == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
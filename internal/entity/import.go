@@ -0,0 +1,13 @@
+package entity
+
+// ImportRowError describes why a single row of a bulk import was rejected.
+// Row is 1-based and does not count the CSV header.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
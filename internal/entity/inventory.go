@@ -5,13 +5,20 @@ import (
 )
 
 type InventoryItem struct {
-	ID        int64
-	ItemName  string
-	Quantity  float64
-	Unit      string
-	Price     float64
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           int64
+	ItemName     string
+	Quantity     float64
+	Unit         string
+	Price        float64
+	ReorderLevel float64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsLow reports whether the item is at or below its reorder level. Items
+// without a reorder level are never low.
+func (i InventoryItem) IsLow() bool {
+	return i.ReorderLevel > 0 && i.Quantity <= i.ReorderLevel
 }
 
 type ChangeType int
@@ -42,3 +49,56 @@ func (c ChangeType) IsValid() bool {
 	}
 	return false
 }
+
+// Waste reasons say why stock left without being sold.
+const (
+	WasteSpoilage = "spoilage"
+	WasteSpill    = "spill"
+	WasteExpired  = "expired"
+	WasteComped   = "comped"
+)
+
+var WasteReasons = []string{WasteSpoilage, WasteSpill, WasteExpired, WasteComped}
+
+func IsValidWasteReason(reason string) bool {
+	for _, r := range WasteReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// WasteEntry is stock thrown or given away, booked as a TypeWaste ledger
+// entry. ItemName and Unit are read from the inventory.
+type WasteEntry struct {
+	ID          int64
+	InventoryID int64
+	ItemName    string
+	Unit        string
+	Quantity    float64
+	Reason      string
+	StaffID     *int64
+	CreatedAt   time.Time
+}
+
+// InventoryBatch is stock of an item received at one time. Batches are
+// drawn down first-expiring-first-out; stock that predates batch tracking
+// is not in any batch and is used last.
+type InventoryBatch struct {
+	ID                int64
+	InventoryID       int64
+	ItemName          string
+	Unit              string
+	LotCode           string
+	Quantity          float64
+	RemainingQuantity float64
+	UnitCost          *float64
+	ReceivedAt        time.Time
+	ExpiresAt         *time.Time
+	PurchaseOrderID   *int64
+}
+
+func (b InventoryBatch) IsExpired(now time.Time) bool {
+	return b.ExpiresAt != nil && !b.ExpiresAt.After(now)
+}
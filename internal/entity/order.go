@@ -7,6 +7,7 @@ import (
 type Order struct {
 	ID                  int64
 	CustomerName        string
+	StaffID             *int64
 	TotalAmount         float64
 	Status              OrderStatus
 	PaymentMethod       PaymentMethod
@@ -113,3 +114,21 @@ func (p PaymentMethod) IsValid() bool {
 	}
 	return false
 }
+
+// -------------------------------------------------------------------------
+
+const (
+	OrderEventCreated       = "order.created"
+	OrderEventStatusChanged = "order.status_changed"
+)
+
+// OrderEvent is raised by the database whenever an order is created or its
+// status changes. Order is filled in by the service before fan-out.
+type OrderEvent struct {
+	Type           string
+	OrderID        int64
+	Status         OrderStatus
+	PreviousStatus OrderStatus
+	OccurredAt     time.Time
+	Order          *Order
+}
@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+type Supplier struct {
+	ID          int64
+	Name        string
+	ContactName string
+	Email       string
+	Phone       string
+	Address     string
+	Items       []SupplierItem
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// SupplierItem is an inventory item a supplier sells. ItemName and Unit are
+// read from the inventory and ignored on writes.
+type SupplierItem struct {
+	InventoryID  int64
+	ItemName     string
+	Unit         string
+	UnitPrice    float64
+	LeadTimeDays int
+}
+
+// Item returns the catalog entry for an inventory item.
+func (s Supplier) Item(inventoryID int64) (SupplierItem, bool) {
+	for _, item := range s.Items {
+		if item.InventoryID == inventoryID {
+			return item, true
+		}
+	}
+	return SupplierItem{}, false
+}
+
+// A purchase order is edited as a draft, sent to the supplier and then
+// received in one or more deliveries.
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+)
+
+var PurchaseOrderStatuses = []string{
+	PurchaseOrderDraft,
+	PurchaseOrderSent,
+	PurchaseOrderPartiallyReceived,
+	PurchaseOrderReceived,
+}
+
+func IsValidPurchaseOrderStatus(status string) bool {
+	for _, s := range PurchaseOrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+type PurchaseOrder struct {
+	ID           int64
+	SupplierID   int64
+	SupplierName string
+	Status       string
+	Notes        string
+	ExpectedAt   *time.Time
+	SentAt       *time.Time
+	ReceivedAt   *time.Time
+	Lines        []PurchaseOrderLine
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Total is what the order costs at the ordered unit costs.
+func (po PurchaseOrder) Total() float64 {
+	var total float64
+	for _, line := range po.Lines {
+		total += line.QuantityOrdered * line.UnitCost
+	}
+	return math.Round(total*100) / 100
+}
+
+// PurchaseOrderLine is one inventory item on a purchase order. A UnitCost
+// of zero on a new line is filled in from the supplier's price list.
+type PurchaseOrderLine struct {
+	ID               int64
+	InventoryID      int64
+	ItemName         string
+	Unit             string
+	QuantityOrdered  float64
+	QuantityReceived float64
+	UnitCost         float64
+}
+
+// Remaining is the quantity still to be delivered, rounded to the precision
+// quantities are stored with.
+func (l PurchaseOrderLine) Remaining() float64 {
+	return max(math.Round((l.QuantityOrdered-l.QuantityReceived)*100)/100, 0)
+}
+
+// PurchaseOrderReceipt is a delivered quantity of one line. UnitCost
+// overrides the ordered cost when the invoice differs. Every receipt
+// becomes an inventory batch with the given lot code and expiry date.
+type PurchaseOrderReceipt struct {
+	InventoryID int64
+	Quantity    float64
+	UnitCost    *float64
+	LotCode     string
+	ExpiresAt   *time.Time
+}
@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket: a client may send up to Burst requests at
+// once, and the bucket refills at PerMinute requests a minute.
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l RateLimit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Window is how long an empty bucket takes to fill up again.
+func (l RateLimit) Window() time.Duration {
+	return seconds(float64(l.Burst) / l.perSecond())
+}
+
+// RateBucket is the state of one client's bucket. A zero UpdatedAt is a
+// bucket that has never been used, which starts full.
+type RateBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// RateLimitDecision is the outcome of taking a token for a request.
+// RetryAfter is only set when the request is refused; Reset is how long
+// until the bucket is full again.
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Take refills b for the time elapsed until now and spends a token on the
+// request if one is left.
+func (l RateLimit) Take(b RateBucket, now time.Time) (RateBucket, RateLimitDecision) {
+	rate := l.perSecond()
+	tokens := float64(l.Burst)
+	if !b.UpdatedAt.IsZero() {
+		// clocks of different instances may disagree slightly
+		elapsed := math.Max(now.Sub(b.UpdatedAt).Seconds(), 0)
+		tokens = math.Min(float64(l.Burst), b.Tokens+elapsed*rate)
+	}
+
+	decision := RateLimitDecision{Limit: l.Burst}
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = seconds((float64(l.Burst) - tokens) / rate)
+	return RateBucket{Tokens: tokens, UpdatedAt: now}, decision
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
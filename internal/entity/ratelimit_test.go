@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitTake(t *testing.T) {
+	// a token every two seconds, five at once
+	limit := RateLimit{PerMinute: 30, Burst: 5}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		bucket RateBucket
+		now    time.Time
+		want   RateLimitDecision
+		tokens float64
+	}{
+		{
+			name:   "new bucket starts full",
+			now:    now,
+			want:   RateLimitDecision{Allowed: true, Limit: 5, Remaining: 4, Reset: 2 * time.Second},
+			tokens: 4,
+		},
+		{
+			name:   "last token",
+			bucket: RateBucket{Tokens: 1, UpdatedAt: now},
+			now:    now,
+			want:   RateLimitDecision{Allowed: true, Limit: 5, Remaining: 0, Reset: 10 * time.Second},
+			tokens: 0,
+		},
+		{
+			name:   "empty bucket refuses",
+			bucket: RateBucket{Tokens: 0, UpdatedAt: now},
+			now:    now,
+			want:   RateLimitDecision{Limit: 5, RetryAfter: 2 * time.Second, Reset: 10 * time.Second},
+			tokens: 0,
+		},
+		{
+			name:   "partial token waits for the rest",
+			bucket: RateBucket{Tokens: 0.25, UpdatedAt: now},
+			now:    now.Add(time.Second),
+			want:   RateLimitDecision{Limit: 5, RetryAfter: 500 * time.Millisecond, Reset: 8500 * time.Millisecond},
+			tokens: 0.75,
+		},
+		{
+			name:   "refills with elapsed time",
+			bucket: RateBucket{Tokens: 0, UpdatedAt: now},
+			now:    now.Add(5 * time.Second),
+			want:   RateLimitDecision{Allowed: true, Limit: 5, Remaining: 1, Reset: 7 * time.Second},
+			tokens: 1.5,
+		},
+		{
+			name:   "refill stops at the burst",
+			bucket: RateBucket{Tokens: 2, UpdatedAt: now},
+			now:    now.Add(time.Hour),
+			want:   RateLimitDecision{Allowed: true, Limit: 5, Remaining: 4, Reset: 2 * time.Second},
+			tokens: 4,
+		},
+		{
+			name:   "clock behind the bucket adds nothing",
+			bucket: RateBucket{Tokens: 0.5, UpdatedAt: now},
+			now:    now.Add(-time.Minute),
+			want:   RateLimitDecision{Limit: 5, RetryAfter: time.Second, Reset: 9 * time.Second},
+			tokens: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, got := limit.Take(tt.bucket, tt.now)
+			if got != tt.want {
+				t.Errorf("Take() decision = %+v, want %+v", got, tt.want)
+			}
+			if bucket.Tokens != tt.tokens || !bucket.UpdatedAt.Equal(tt.now) {
+				t.Errorf("Take() bucket = %+v, want %v tokens at %v", bucket, tt.tokens, tt.now)
+			}
+		})
+	}
+}
+
+func TestRateLimitWindow(t *testing.T) {
+	if got, want := (RateLimit{PerMinute: 30, Burst: 5}).Window(), 10*time.Second; got != want {
+		t.Errorf("Window() = %v, want %v", got, want)
+	}
+}
@@ -1,40 +1,489 @@
 package entity
 
+import "time"
+
 type PopularItem struct {
-	ProductId   int    `json:"product_id"`
-	ProductName string `json:"product_name"`
-	Sold        int    `json:"total_quantity"`
+	ProductId   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Sold        int     `json:"total_quantity"`
+	Revenue     float64 `json:"revenue"`
+}
+
+// Report grouping periods and breakdown dimensions.
+const (
+	GroupByHour  = "hour"
+	GroupByDay   = "day"
+	GroupByWeek  = "week"
+	GroupByMonth = "month"
+
+	BreakdownPaymentMethod = "payment_method"
+	BreakdownCategory      = "category"
+	BreakdownStaff         = "staff"
+	BreakdownMenuItem      = "menu_item"
+)
+
+// ReportQuery is what a caller asks of a sales report. Reports cover
+// completed orders created in [From, To); a zero From means all time.
+// Periods are calendar hours, days, ISO weeks or months in UTC.
+type ReportQuery struct {
+	From       time.Time
+	To         time.Time
+	GroupBy    string
+	Breakdowns []string
+	Limit      int
+}
+
+// ReportFilter is a single aggregation handed to the store: at most one
+// grouping period and one breakdown dimension. Rows come in period then key
+// order, or with KeyFirst in key then period order, which lets exports fill
+// each key's gaps as its rows stream past.
+type ReportFilter struct {
+	From      time.Time
+	To        time.Time
+	GroupBy   string
+	Breakdown string
+	Limit     int
+	KeyFirst  bool
+}
+
+// SalesRow is one aggregate from the store. Bucket is zero unless grouped,
+// Key and Label are empty unless broken down.
+type SalesRow struct {
+	Bucket     time.Time
+	Key        string
+	Label      string
+	Revenue    float64
+	OrderCount int
+}
+
+// PopularItemRow is one ranked item within its bucket and breakdown key.
+type PopularItemRow struct {
+	Bucket time.Time
+	Key    string
+	Label  string
+	Item   PopularItem
+}
+
+type SalesReport struct {
+	From       *time.Time              `json:"from,omitempty"`
+	To         time.Time               `json:"to"`
+	GroupBy    string                  `json:"group_by,omitempty"`
+	TotalSales float64                 `json:"total_sales"`
+	OrderCount int                     `json:"order_count"`
+	Buckets    []SalesBucket           `json:"buckets,omitempty"`
+	Breakdowns map[string][]SalesGroup `json:"breakdowns,omitempty"`
+}
+
+type SalesBucket struct {
+	PeriodStart time.Time `json:"period_start"`
+	TotalSales  float64   `json:"total_sales"`
+	OrderCount  int       `json:"order_count"`
+}
+
+// SalesGroup is one value of a breakdown. With the category breakdown an
+// order that spans several categories counts towards each of them.
+type SalesGroup struct {
+	Key        string        `json:"key"`
+	Label      string        `json:"label"`
+	TotalSales float64       `json:"total_sales"`
+	OrderCount int           `json:"order_count"`
+	Buckets    []SalesBucket `json:"buckets,omitempty"`
+}
+
+type PopularItemsReport struct {
+	From       *time.Time                     `json:"from,omitempty"`
+	To         time.Time                      `json:"to"`
+	GroupBy    string                         `json:"group_by,omitempty"`
+	Items      []PopularItem                  `json:"items"`
+	Buckets    []PopularItemsBucket           `json:"buckets,omitempty"`
+	Breakdowns map[string][]PopularItemsGroup `json:"breakdowns,omitempty"`
+}
+
+type PopularItemsBucket struct {
+	PeriodStart time.Time     `json:"period_start"`
+	Items       []PopularItem `json:"items"`
 }
 
+type PopularItemsGroup struct {
+	Key     string               `json:"key"`
+	Label   string               `json:"label"`
+	Items   []PopularItem        `json:"items"`
+	Buckets []PopularItemsBucket `json:"buckets,omitempty"`
+}
+
+// ItemsRow is the number of items sold in one period, per menu item when
+// broken down.
+type ItemsRow struct {
+	Bucket    time.Time
+	Key       string
+	Label     string
+	ItemCount int
+	Revenue   float64
+}
+
+// TotalItemsByPeriod covers every day of a month or every month of a year,
+// in order, including periods without sales.
 type TotalItemsByPeriod struct {
-	Period       string           `json:"period"`
-	Month        string           `json:"month,omitempty"`
-	Year         int              `json:"year,omitempty"`
-	OrderedItems []map[string]int `json:"ordered_items"`
+	Period    string            `json:"period"`
+	Month     string            `json:"month,omitempty"`
+	Year      int               `json:"year"`
+	ItemCount int               `json:"item_count"`
+	Revenue   float64           `json:"revenue"`
+	Buckets   []PeriodBucket    `json:"buckets"`
+	MenuItems []MenuItemPeriods `json:"menu_items,omitempty"`
+}
+
+type PeriodBucket struct {
+	PeriodStart time.Time `json:"period_start"`
+	Label       string    `json:"label"`
+	ItemCount   int       `json:"item_count"`
+	Revenue     float64   `json:"revenue"`
+}
+
+// MenuItemPeriods is the per-period breakdown of a single menu item.
+type MenuItemPeriods struct {
+	MenuItemID int64          `json:"menu_item_id"`
+	Name       string         `json:"name"`
+	ItemCount  int            `json:"item_count"`
+	Revenue    float64        `json:"revenue"`
+	Buckets    []PeriodBucket `json:"buckets"`
+}
+
+// What a search can look through. Customers are the distinct customer
+// names of orders.
+const (
+	SearchMenu      = "menu"
+	SearchOrders    = "orders"
+	SearchInventory = "inventory"
+	SearchCustomers = "customers"
+)
+
+var SearchTargets = []string{SearchMenu, SearchOrders, SearchInventory, SearchCustomers}
+
+func IsValidSearchTarget(target string) bool {
+	for _, t := range SearchTargets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery is a search over Targets, all of them when empty. A nil
+// MinPrice or MaxPrice leaves that end of the range open; prices bound the
+// menu price, the inventory unit price and the order total. The price range,
+// From, To and Statuses narrow orders, and the orders customers are found
+// through; a zero time or no statuses leave them open. Every kind of result
+// is paged on its own with the same Limit and Offset.
+type SearchQuery struct {
+	Text     string
+	Targets  []string
+	MinPrice *float64
+	MaxPrice *float64
+	From     time.Time
+	To       time.Time
+	Statuses []string
+	Limit    int
+	Offset   int
+}
+
+// Searches reports whether the query looks through target.
+func (q SearchQuery) Searches(target string) bool {
+	if len(q.Targets) == 0 {
+		return true
+	}
+	for _, t := range q.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
 }
 
+// SearchResult holds one page of each kind of result, and the same results
+// merged into Results, most relevant first. Matches counts every match, not
+// only those on the page.
 type SearchResult struct {
-	MenuItems []SearchMenuItem `json:"menu_items,omitempty"`
-	Orders    []SearchOrder    `json:"orders,omitempty"`
-	Matches   int              `json:"total_matches"`
+	Results        []SearchHit           `json:"results"`
+	MenuItems      []SearchMenuItem      `json:"menu_items,omitempty"`
+	Orders         []SearchOrder         `json:"orders,omitempty"`
+	InventoryItems []SearchInventoryItem `json:"inventory_items,omitempty"`
+	Customers      []SearchCustomer      `json:"customers,omitempty"`
+	Matches        int                   `json:"total_matches"`
+	MenuItemsTotal int                   `json:"menu_items_total"`
+	OrdersTotal    int                   `json:"orders_total"`
+	InventoryTotal int                   `json:"inventory_items_total"`
+	CustomersTotal int                   `json:"customers_total"`
+	Page           int                   `json:"page"`
+	PageSize       int                   `json:"page_size"`
 }
 
+// Kinds of search hit.
+const (
+	HitMenuItem      = "menu_item"
+	HitOrder         = "order"
+	HitInventoryItem = "inventory_item"
+	HitCustomer      = "customer"
+)
+
+// SearchHit is a result of any kind in the merged list. Amount is the menu
+// price, order total, inventory unit price or what the customer spent.
+// Customers have no ID.
+type SearchHit struct {
+	Type      string  `json:"type"`
+	ID        *int64  `json:"id,omitempty"`
+	Name      string  `json:"name"`
+	Amount    float64 `json:"amount"`
+	Relevance float64 `json:"relevance"`
+	Snippet   string  `json:"snippet"`
+}
+
+// Relevance blends full-text rank with trigram similarity, so misspelt and
+// partial words still match. Snippet is the matching text, escaped as HTML,
+// with the matched words wrapped in <mark> tags.
 type SearchMenuItem struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	Relevance   float64 `json:"relevance"`
+	Snippet     string  `json:"snippet"`
 }
 
 type SearchOrder struct {
-	ID           int      `json:"id"`
-	CustomerName string   `json:"customer_name"`
-	Items        []string `json:"items"`
-	Total        float64  `json:"total"`
-	Relevance    float64  `json:"relevance"`
+	ID           int       `json:"id"`
+	CustomerName string    `json:"customer_name"`
+	Items        []string  `json:"items"`
+	Total        float64   `json:"total"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	Relevance    float64   `json:"relevance"`
+	Snippet      string    `json:"snippet"`
+}
+
+type SearchInventoryItem struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Unit      string  `json:"unit"`
+	Quantity  float64 `json:"quantity"`
+	Price     float64 `json:"price"`
+	Relevance float64 `json:"relevance"`
+	Snippet   string  `json:"snippet"`
+}
+
+// SearchCustomer is a customer name with the orders placed under it.
+type SearchCustomer struct {
+	Name        string    `json:"name"`
+	OrderCount  int       `json:"order_count"`
+	TotalSpent  float64   `json:"total_spent"`
+	LastOrderAt time.Time `json:"last_order_at"`
+	Relevance   float64   `json:"relevance"`
+	Snippet     string    `json:"snippet"`
+}
+
+// Kinds of search suggestion.
+const (
+	SuggestionMenuItem = "menu_item"
+	SuggestionCustomer = "customer"
+)
+
+// SearchSuggestion completes a search box. ID is the menu item's; customers
+// have none.
+type SearchSuggestion struct {
+	Type  string  `json:"type"`
+	ID    *int64  `json:"id,omitempty"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
 }
 
 type NumberOfOrderedItemsByPeriod struct {
 	OrderedItems map[string]int `json:"items"`
 }
+
+// Where a forecast takes inventory usage from. Auto uses the ledger for items
+// that have outgoing ledger entries in the window and completed orders times
+// recipes for the rest.
+const (
+	ForecastSourceAuto   = "auto"
+	ForecastSourceLedger = "ledger"
+	ForecastSourceOrders = "orders"
+)
+
+// InventoryUsage is what an inventory item used up during a window, both
+// according to the ledger and to the recipes of completed orders.
+type InventoryUsage struct {
+	InventoryID   int64
+	Name          string
+	Unit          string
+	Quantity      float64
+	Price         float64
+	ReorderLevel  float64
+	LedgerUsage   float64
+	LedgerEntries int
+	OrderUsage    float64
+}
+
+type InventoryForecast struct {
+	AsOf       time.Time               `json:"as_of"`
+	WindowDays int                     `json:"window_days"`
+	CoverDays  int                     `json:"cover_days"`
+	Source     string                  `json:"source"`
+	Items      []InventoryForecastItem `json:"items"`
+	// EstimatedCost prices every suggested reorder at today's unit prices.
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// InventoryForecastItem projects one item. DaysUntilStockout and
+// StockoutDate are nil when the item was not used in the window.
+type InventoryForecastItem struct {
+	InventoryID       int64      `json:"inventory_id"`
+	Name              string     `json:"name"`
+	Unit              string     `json:"unit"`
+	Quantity          float64    `json:"quantity"`
+	ReorderLevel      float64    `json:"reorder_level"`
+	UsageSource       string     `json:"usage_source"`
+	Usage             float64    `json:"usage"`
+	AvgDailyUsage     float64    `json:"avg_daily_usage"`
+	DaysUntilStockout *float64   `json:"days_until_stockout"`
+	StockoutDate      *time.Time `json:"stockout_date"`
+	SuggestedReorder  float64    `json:"suggested_reorder_quantity"`
+	EstimatedCost     float64    `json:"estimated_cost"`
+}
+
+// Stock is valued, and what is used up costed, either first in first out or
+// at the weighted average cost of everything on hand.
+const (
+	ValuationFIFO            = "fifo"
+	ValuationWeightedAverage = "weighted_average"
+)
+
+func IsValidValuationMethod(method string) bool {
+	return method == ValuationFIFO || method == ValuationWeightedAverage
+}
+
+// CostedItem is an inventory item with the sum of all its ledger entries.
+// Stock the ledger does not account for is treated as opening stock bought
+// at the current Price.
+type CostedItem struct {
+	InventoryID   int64
+	Name          string
+	Unit          string
+	Quantity      float64
+	Price         float64
+	LedgerBalance float64
+	CreatedAt     time.Time
+}
+
+// LedgerEntry is one inventory transaction. UnitCost is only known for
+// restocks and for stock added by an adjustment, WasteReason and the staff
+// member only for waste.
+type LedgerEntry struct {
+	InventoryID    int64
+	QuantityChange float64
+	Reason         string
+	UnitCost       *float64
+	WasteReason    string
+	StaffID        *int64
+	StaffName      string
+	CreatedAt      time.Time
+}
+
+type InventoryValuation struct {
+	AsOf       time.Time                `json:"as_of"`
+	Method     string                   `json:"method"`
+	TotalValue float64                  `json:"total_value"`
+	Items      []InventoryValuationItem `json:"items"`
+}
+
+// InventoryValuationItem is the stock of one item at the valuation date.
+// UnitCost is the average cost of that stock. MissingQuantity is stock the
+// ledger still counts although the item no longer has it, because it left
+// without a ledger entry; Quantity and Value include it.
+type InventoryValuationItem struct {
+	InventoryID     int64   `json:"inventory_id"`
+	Name            string  `json:"name"`
+	Unit            string  `json:"unit"`
+	Quantity        float64 `json:"quantity"`
+	UnitCost        float64 `json:"unit_cost"`
+	Value           float64 `json:"value"`
+	MissingQuantity float64 `json:"missing_quantity,omitempty"`
+}
+
+// COGSReport sets the cost of the stock used up in a range against the sales
+// of the same range. GrossMargin is a percentage of revenue, nil without
+// sales.
+type COGSReport struct {
+	From        *time.Time   `json:"from,omitempty"`
+	To          time.Time    `json:"to"`
+	GroupBy     string       `json:"group_by,omitempty"`
+	Method      string       `json:"method"`
+	Revenue     float64      `json:"revenue"`
+	OrderCount  int          `json:"order_count"`
+	COGS        float64      `json:"cogs"`
+	GrossProfit float64      `json:"gross_profit"`
+	GrossMargin *float64     `json:"gross_margin"`
+	Buckets     []COGSBucket `json:"buckets,omitempty"`
+	Items       []COGSItem   `json:"items"`
+}
+
+type COGSBucket struct {
+	PeriodStart time.Time `json:"period_start"`
+	Revenue     float64   `json:"revenue"`
+	COGS        float64   `json:"cogs"`
+	GrossProfit float64   `json:"gross_profit"`
+}
+
+type COGSItem struct {
+	InventoryID  int64   `json:"inventory_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	QuantityUsed float64 `json:"quantity_used"`
+	Cost         float64 `json:"cost"`
+}
+
+// WasteReport sums the waste logged in a range, costed like the inventory,
+// per item, per reason and per staff member. Every list is ordered by cost,
+// highest first.
+type WasteReport struct {
+	From       *time.Time         `json:"from,omitempty"`
+	To         time.Time          `json:"to"`
+	Method     string             `json:"method"`
+	TotalCost  float64            `json:"total_cost"`
+	EntryCount int                `json:"entry_count"`
+	Items      []WasteItem        `json:"items"`
+	Reasons    []WasteReasonTotal `json:"reasons"`
+	Staff      []WasteStaffTotal  `json:"staff"`
+}
+
+type WasteItem struct {
+	InventoryID int64             `json:"inventory_id"`
+	Name        string            `json:"name"`
+	Unit        string            `json:"unit"`
+	Quantity    float64           `json:"quantity"`
+	Cost        float64           `json:"cost"`
+	EntryCount  int               `json:"entry_count"`
+	Reasons     []WasteItemReason `json:"reasons"`
+}
+
+type WasteItemReason struct {
+	Reason     string  `json:"reason"`
+	Quantity   float64 `json:"quantity"`
+	Cost       float64 `json:"cost"`
+	EntryCount int     `json:"entry_count"`
+}
+
+// WasteReasonTotal has no quantity, as items are counted in different units.
+type WasteReasonTotal struct {
+	Reason     string  `json:"reason"`
+	Cost       float64 `json:"cost"`
+	EntryCount int     `json:"entry_count"`
+}
+
+// WasteStaffTotal is the waste logged by one staff member. Waste logged
+// without one is grouped under a nil StaffID.
+type WasteStaffTotal struct {
+	StaffID    *int64  `json:"staff_id"`
+	Name       string  `json:"name"`
+	Cost       float64 `json:"cost"`
+	EntryCount int     `json:"entry_count"`
+}
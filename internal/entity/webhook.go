@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types that can be subscribed to.
+const (
+	EventOrderCompleted    = "order.completed"
+	EventInventoryLowStock = "inventory.low_stock"
+)
+
+var WebhookEventTypes = []string{EventOrderCompleted, EventInventoryLowStock}
+
+func IsValidWebhookEvent(eventType string) bool {
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookSubscription struct {
+	ID         int64
+	URL        string
+	EventTypes []string
+	Secret     string
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        int64
+	EventType      string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode *int
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	Log            []WebhookAttempt
+}
+
+// WebhookAttempt is one entry of the delivery log.
+type WebhookAttempt struct {
+	DeliveryID  int64
+	Attempt     int
+	StatusCode  int
+	Error       string
+	Duration    time.Duration
+	AttemptedAt time.Time
+}
+
+// WebhookDispatch is a delivery claimed by the dispatcher, with everything
+// needed to send it.
+type WebhookDispatch struct {
+	DeliveryID     int64
+	Attempt        int
+	URL            string
+	Secret         string
+	EventID        int64
+	EventType      string
+	Payload        json.RawMessage
+	EventCreatedAt time.Time
+}
@@ -0,0 +1,272 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"frappuccino-alem/internal/entity"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var (
+	InventoryCSVHeader = []string{"name", "quantity", "unit", "price"}
+	MenuCSVHeader      = []string{"name", "description", "price", "categories", "allergens", "metadata", "ingredients"}
+)
+
+// MenuImportRow is one menu item in a bulk import. Ingredients reference
+// inventory items by name so a file can be prepared without knowing ids.
+type MenuImportRow struct {
+	Name        *string                   `json:"name"`
+	Description *string                   `json:"description"`
+	Price       *float64                  `json:"price"`
+	Categories  []string                  `json:"categories"`
+	Allergens   []string                  `json:"allergens"`
+	Metadata    map[string]interface{}    `json:"metadata"`
+	Ingredients []ImportIngredientRequest `json:"ingredients"`
+}
+
+type ImportIngredientRequest struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+}
+
+func (r MenuImportRow) Validate() error {
+	var v validation
+	v.check(r.Name != nil && *r.Name != "", "name", "is required")
+	if r.Price == nil {
+		v.add("price", "is required")
+	} else if *r.Price < 0 {
+		v.add("price", "cannot be negative")
+	}
+	v.check(len(r.Ingredients) > 0, "ingredients", "at least one ingredient is required")
+	seen := make(map[string]int, len(r.Ingredients))
+	for i, ing := range r.Ingredients {
+		if ing.Name == "" {
+			v.add(elem("ingredients", i, "name"), "is required")
+		} else if first, ok := seen[strings.ToLower(ing.Name)]; ok {
+			v.add(elem("ingredients", i, "name"), "ingredient %q is already listed in ingredients[%d]", ing.Name, first)
+		} else {
+			seen[strings.ToLower(ing.Name)] = i
+		}
+		v.check(ing.Quantity > 0, elem("ingredients", i, "quantity"), "must be greater than 0")
+	}
+	return v.err()
+}
+
+func (r MenuImportRow) MapToEntity() entity.MenuItem {
+	item := entity.MenuItem{
+		Name:       *r.Name,
+		Price:      *r.Price,
+		Categories: r.Categories,
+		Allergens:  r.Allergens,
+		Metadata:   r.Metadata,
+	}
+	if r.Description != nil {
+		item.Description = *r.Description
+	}
+	if item.Categories == nil {
+		item.Categories = []string{}
+	}
+	if item.Allergens == nil {
+		item.Allergens = []string{}
+	}
+	if item.Metadata == nil {
+		item.Metadata = entity.JSONB{}
+	}
+	for _, ing := range r.Ingredients {
+		item.Ingredients = append(item.Ingredients, entity.MenuIngredient{
+			Name:     ing.Name,
+			Quantity: ing.Quantity,
+		})
+	}
+	return item
+}
+
+// MenuItemToImportRow produces the JSON export shape, which can be imported back as is.
+func MenuItemToImportRow(m entity.MenuItem) MenuImportRow {
+	name, description, price := m.Name, m.Description, m.Price
+	row := MenuImportRow{
+		Name:        &name,
+		Description: &description,
+		Price:       &price,
+		Categories:  m.Categories,
+		Allergens:   m.Allergens,
+		Metadata:    m.Metadata,
+		Ingredients: make([]ImportIngredientRequest, 0, len(m.Ingredients)),
+	}
+	for _, ing := range m.Ingredients {
+		row.Ingredients = append(row.Ingredients, ImportIngredientRequest{Name: ing.Name, Quantity: ing.Quantity})
+	}
+	return row
+}
+
+// ParseInventoryImport reads inventory rows from a CSV document with a header
+// row or from a JSON array. Rows that cannot be decoded are reported in the
+// returned row errors; only a malformed document as a whole is an error.
+func ParseInventoryImport(r io.Reader, format string) ([]InventoryItemRequest, []entity.ImportRowError, error) {
+	if format == FormatJSON {
+		var rows []InventoryItemRequest
+		if err := decodeImportJSON(r, &rows); err != nil {
+			return nil, nil, fmt.Errorf("expected a JSON array of inventory items: %w", err)
+		}
+		return rows, nil, nil
+	}
+
+	records, err := readCSV(r, InventoryCSVHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]InventoryItemRequest, len(records))
+	var rowErrs []entity.ImportRowError
+	for i, rec := range records {
+		quantity, qErr := strconv.ParseFloat(rec["quantity"], 64)
+		price, pErr := strconv.ParseFloat(rec["price"], 64)
+		if qErr != nil || pErr != nil {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: "quantity and price must be numbers"})
+			continue
+		}
+		name, unit := rec["name"], rec["unit"]
+		rows[i] = InventoryItemRequest{Name: &name, Quantity: &quantity, UnitType: &unit, Price: &price}
+	}
+	return rows, rowErrs, nil
+}
+
+// ParseMenuImport reads menu rows from CSV or JSON. In CSV, categories and
+// allergens are separated by ";", metadata is a JSON object and ingredients
+// are written as "Inventory item name:quantity" pairs separated by ";".
+func ParseMenuImport(r io.Reader, format string) ([]MenuImportRow, []entity.ImportRowError, error) {
+	if format == FormatJSON {
+		var rows []MenuImportRow
+		if err := decodeImportJSON(r, &rows); err != nil {
+			return nil, nil, fmt.Errorf("expected a JSON array of menu items: %w", err)
+		}
+		return rows, nil, nil
+	}
+
+	records, err := readCSV(r, MenuCSVHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]MenuImportRow, len(records))
+	var rowErrs []entity.ImportRowError
+	for i, rec := range records {
+		row, err := menuRowFromCSV(rec)
+		if err != nil {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		rows[i] = row
+	}
+	return rows, rowErrs, nil
+}
+
+func menuRowFromCSV(rec map[string]string) (MenuImportRow, error) {
+	name, description := rec["name"], rec["description"]
+	row := MenuImportRow{
+		Name:        &name,
+		Description: &description,
+		Categories:  splitList(rec["categories"]),
+		Allergens:   splitList(rec["allergens"]),
+	}
+
+	price, err := strconv.ParseFloat(rec["price"], 64)
+	if err != nil {
+		return row, errors.New("price must be a number")
+	}
+	row.Price = &price
+
+	if rec["metadata"] != "" {
+		if err := json.Unmarshal([]byte(rec["metadata"]), &row.Metadata); err != nil {
+			return row, errors.New("metadata must be a JSON object")
+		}
+	}
+
+	for _, pair := range splitList(rec["ingredients"]) {
+		ingName, qty, ok := strings.Cut(pair, ":")
+		if !ok {
+			return row, fmt.Errorf("ingredient %q must be written as name:quantity", pair)
+		}
+		quantity, err := strconv.ParseFloat(strings.TrimSpace(qty), 64)
+		if err != nil {
+			return row, fmt.Errorf("ingredient %q: quantity must be a number", ingName)
+		}
+		row.Ingredients = append(row.Ingredients, ImportIngredientRequest{
+			Name:     strings.TrimSpace(ingName),
+			Quantity: quantity,
+		})
+	}
+	return row, nil
+}
+
+// decodeImportJSON decodes a JSON import as strictly as a request body:
+// properties the rows do not have are rejected instead of ignored.
+func decodeImportJSON(r io.Reader, rows any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(rows)
+}
+
+// readCSV returns every data row keyed by column name. The header must
+// contain all expected columns, in any order.
+func readCSV(r io.Reader, expected []string) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV document must start with a header row")
+	}
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		index[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	for _, col := range expected {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", col)
+		}
+	}
+
+	var records []map[string]string
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed CSV: %w", err)
+		}
+		rec := make(map[string]string, len(expected))
+		for _, col := range expected {
+			rec[col] = strings.TrimSpace(fields[index[col]])
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, part := range strings.Split(s, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// JoinList is the inverse of the ";" list encoding used in CSV files.
+func JoinList(list []string) string {
+	return strings.Join(list, ";")
+}
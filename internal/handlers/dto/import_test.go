@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInventoryImportValidatesRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:   "csv out of stock",
+			format: FormatCSV,
+			doc:    "name,quantity,unit,price\nMilk,0,l,1.5\n",
+		},
+		{
+			name:   "json out of stock",
+			format: FormatJSON,
+			doc:    `[{"name":"Milk","quantity":0,"unit":"l","price":1.5}]`,
+		},
+		{
+			name:    "csv negative quantity",
+			format:  FormatCSV,
+			doc:     "name,quantity,unit,price\nMilk,-1,l,1.5\n",
+			wantErr: "quantity: cannot be negative",
+		},
+		{
+			name:    "json missing quantity",
+			format:  FormatJSON,
+			doc:     `[{"name":"Milk","unit":"l","price":1.5}]`,
+			wantErr: "quantity: is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrs, err := ParseInventoryImport(strings.NewReader(tt.doc), tt.format)
+			if err != nil || len(rowErrs) != 0 {
+				t.Fatalf("ParseInventoryImport() = %v, %v", rowErrs, err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+
+			err = rows[0].Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
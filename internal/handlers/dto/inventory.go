@@ -2,6 +2,7 @@ package dto
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"frappuccino-alem/internal/entity"
@@ -12,15 +13,69 @@ type InventoryItemRequest struct {
 	Quantity *float64 `json:"quantity"`
 	UnitType *string  `json:"unit"`
 	Price    *float64 `json:"price"`
+	// ReorderLevel is optional; at or below it the item counts as low stock.
+	ReorderLevel *float64 `json:"reorder_level"`
+}
+
+func (r InventoryItemRequest) Validate() error {
+	var v validation
+	v.check(r.Name != nil && *r.Name != "", "name", "is required")
+	if r.Quantity == nil {
+		v.add("quantity", "is required")
+	} else if *r.Quantity < 0 {
+		// an item may be out of stock, PATCH accepts 0 as well
+		v.add("quantity", "cannot be negative")
+	}
+	v.check(r.UnitType != nil && *r.UnitType != "", "unit", "is required")
+	if r.Price == nil {
+		v.add("price", "is required")
+	} else if *r.Price <= 0 {
+		v.add("price", "must be greater than 0")
+	}
+	v.check(r.ReorderLevel == nil || *r.ReorderLevel >= 0, "reorder_level", "cannot be negative")
+	return v.err()
 }
 
 func (r InventoryItemRequest) MapToEntity() entity.InventoryItem {
-	return entity.InventoryItem{
+	item := entity.InventoryItem{
 		ItemName: *r.Name,
 		Quantity: *r.Quantity,
 		Unit:     *r.UnitType,
 		Price:    *r.Price,
 	}
+	if r.ReorderLevel != nil {
+		item.ReorderLevel = *r.ReorderLevel
+	}
+	return item
+}
+
+// InventoryItemPatch is a merge patch for an inventory item. Every property
+// is required on the item, so none of them may be set to null.
+type InventoryItemPatch struct {
+	Name     PatchField[string]  `json:"name"`
+	Quantity PatchField[float64] `json:"quantity"`
+	UnitType PatchField[string]  `json:"unit"`
+	Price    PatchField[float64] `json:"price"`
+	// ReorderLevel set to null clears the reorder level.
+	ReorderLevel PatchField[float64] `json:"reorder_level"`
+}
+
+func (p InventoryItemPatch) Validate() error {
+	var v validation
+	v.check(!p.Name.Set || (!p.Name.Null && p.Name.Value != ""), "name", "cannot be cleared")
+	if p.Quantity.Set && p.Quantity.Null {
+		v.add("quantity", "cannot be cleared")
+	} else if p.Quantity.Set && p.Quantity.Value < 0 {
+		v.add("quantity", "cannot be negative")
+	}
+	v.check(!p.UnitType.Set || (!p.UnitType.Null && p.UnitType.Value != ""), "unit", "cannot be cleared")
+	if p.Price.Set && p.Price.Null {
+		v.add("price", "cannot be cleared")
+	} else if p.Price.Set && p.Price.Value <= 0 {
+		v.add("price", "must be greater than 0")
+	}
+	v.check(!p.ReorderLevel.Set || p.ReorderLevel.Null || p.ReorderLevel.Value >= 0, "reorder_level", "cannot be negative")
+	return v.err()
 }
 
 type InventoryItemResponse struct {
@@ -60,3 +115,116 @@ func InventoryItemToLeftOver(e entity.InventoryItem) LeftOverItem {
 		Price:    e.Price,
 	}
 }
+
+// WasteRequest logs stock of one inventory item as wasted.
+type WasteRequest struct {
+	Quantity *float64 `json:"quantity"`
+	Reason   string   `json:"reason"`
+	StaffID  *int64   `json:"staff_id"`
+}
+
+func (r WasteRequest) Validate() error {
+	var v validation
+	v.check(r.Quantity != nil && *r.Quantity > 0, "quantity", "must be greater than 0")
+	v.check(entity.IsValidWasteReason(r.Reason), "reason", "must be one of: "+strings.Join(entity.WasteReasons, ", "))
+	v.check(r.StaffID == nil || *r.StaffID > 0, "staff_id", "must be greater than 0")
+	return v.err()
+}
+
+func (r WasteRequest) MapToEntity(inventoryID int64) entity.WasteEntry {
+	return entity.WasteEntry{
+		InventoryID: inventoryID,
+		Quantity:    *r.Quantity,
+		Reason:      r.Reason,
+		StaffID:     r.StaffID,
+	}
+}
+
+type WasteResponse struct {
+	ID          int64     `json:"id"`
+	InventoryID int64     `json:"inventory_id"`
+	Name        string    `json:"name"`
+	Unit        string    `json:"unit"`
+	Quantity    float64   `json:"quantity"`
+	Reason      string    `json:"reason"`
+	StaffID     *int64    `json:"staff_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func WasteToResponse(e entity.WasteEntry) WasteResponse {
+	return WasteResponse{
+		ID:          e.ID,
+		InventoryID: e.InventoryID,
+		Name:        e.ItemName,
+		Unit:        e.Unit,
+		Quantity:    e.Quantity,
+		Reason:      e.Reason,
+		StaffID:     e.StaffID,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+// BatchRequest receives a batch of an inventory item outside of a purchase
+// order. received_at defaults to now; unit_cost, when given, moves the
+// item's price to the weighted average.
+type BatchRequest struct {
+	Quantity   *float64   `json:"quantity"`
+	LotCode    string     `json:"lot_code"`
+	UnitCost   *float64   `json:"unit_cost"`
+	ReceivedAt *time.Time `json:"received_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func (r BatchRequest) Validate() error {
+	var v validation
+	v.check(r.Quantity != nil && *r.Quantity > 0, "quantity", "must be greater than 0")
+	v.check(r.UnitCost == nil || *r.UnitCost >= 0, "unit_cost", "cannot be negative")
+	v.check(r.ReceivedAt == nil || r.ExpiresAt == nil || r.ExpiresAt.After(*r.ReceivedAt), "expires_at", "must be after received_at")
+	return v.err()
+}
+
+func (r BatchRequest) MapToEntity(inventoryID int64) entity.InventoryBatch {
+	batch := entity.InventoryBatch{
+		InventoryID: inventoryID,
+		LotCode:     strings.TrimSpace(r.LotCode),
+		Quantity:    *r.Quantity,
+		UnitCost:    r.UnitCost,
+		ExpiresAt:   r.ExpiresAt,
+	}
+	if r.ReceivedAt != nil {
+		batch.ReceivedAt = *r.ReceivedAt
+	}
+	return batch
+}
+
+type BatchResponse struct {
+	ID                int64      `json:"id"`
+	InventoryID       int64      `json:"inventory_id"`
+	Name              string     `json:"name"`
+	Unit              string     `json:"unit"`
+	LotCode           string     `json:"lot_code"`
+	Quantity          float64    `json:"quantity"`
+	RemainingQuantity float64    `json:"remaining_quantity"`
+	UnitCost          *float64   `json:"unit_cost,omitempty"`
+	ReceivedAt        time.Time  `json:"received_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Expired           bool       `json:"expired"`
+	PurchaseOrderID   *int64     `json:"purchase_order_id,omitempty"`
+}
+
+func BatchToResponse(b entity.InventoryBatch, now time.Time) BatchResponse {
+	return BatchResponse{
+		ID:                b.ID,
+		InventoryID:       b.InventoryID,
+		Name:              b.ItemName,
+		Unit:              b.Unit,
+		LotCode:           b.LotCode,
+		Quantity:          b.Quantity,
+		RemainingQuantity: b.RemainingQuantity,
+		UnitCost:          b.UnitCost,
+		ReceivedAt:        b.ReceivedAt,
+		ExpiresAt:         b.ExpiresAt,
+		Expired:           b.IsExpired(now),
+		PurchaseOrderID:   b.PurchaseOrderID,
+	}
+}
@@ -1,7 +1,6 @@
 package dto
 
 import (
-	"fmt"
 	"strconv"
 	"time"
 
@@ -24,36 +23,91 @@ type IngredientRequest struct {
 }
 
 func (r MenuItemRequest) Validate() error {
-	if r.Name == nil {
-		return fmt.Errorf("invalid menu property: name is required")
-	}
-	if r.Description == nil {
-		return fmt.Errorf("invalid menu property: description is required")
-	}
+	var v validation
+	v.check(r.Name != nil && *r.Name != "", "name", "is required")
+	v.check(r.Description != nil, "description", "is required")
 	if r.Price == nil {
-		return fmt.Errorf("invalid menu property: price is required")
+		v.add("price", "is required")
+	} else if *r.Price < 0 {
+		v.add("price", "cannot be negative")
+	}
+
+	if r.Ingredients == nil || len(*r.Ingredients) == 0 {
+		v.add("ingredients", "at least one ingredient is required")
+		return v.err()
+	}
+	seen := make(map[int64]int, len(*r.Ingredients))
+	for i, ing := range *r.Ingredients {
+		switch {
+		case ing.ItemID == nil:
+			v.add(elem("ingredients", i, "item_id"), "is required")
+		case *ing.ItemID <= 0:
+			v.add(elem("ingredients", i, "item_id"), "must be greater than 0")
+		default:
+			checkDuplicateIngredient(&v, seen, *ing.ItemID, i)
+		}
+		if ing.Quantity == nil {
+			v.add(elem("ingredients", i, "quantity"), "is required")
+		} else if *ing.Quantity <= 0 {
+			v.add(elem("ingredients", i, "quantity"), "must be greater than 0")
+		}
 	}
+	return v.err()
+}
 
-	if r.Ingredients == nil {
-		return fmt.Errorf("invalid menu property: ingredients are required")
-	}
-	if len(*r.Ingredients) < 1 {
-		return fmt.Errorf("at least one ingredient is required")
+// checkDuplicateIngredient records an ingredient listed twice in one menu item.
+func checkDuplicateIngredient(v *validation, seen map[int64]int, itemID int64, i int) {
+	if first, ok := seen[itemID]; ok {
+		v.add(elem("ingredients", i, "item_id"), "inventory item %d is already listed in ingredients[%d]", itemID, first)
+		return
 	}
+	seen[itemID] = i
+}
 
-	for _, ing := range *r.Ingredients {
-		if ing.ItemID == nil {
-			return fmt.Errorf("invalid ingredient property: item_id is required")
-		}
-		if ing.Quantity == nil {
-			return fmt.Errorf("invalid ingredient property: quantity is required")
+// MenuItemPatch is a merge patch for a menu item. Ingredients are merged by
+// item_id instead of replacing the whole list: a null quantity removes the
+// ingredient, any other quantity adds or updates it.
+type MenuItemPatch struct {
+	Name        PatchField[string]            `json:"name"`
+	Description PatchField[string]            `json:"description"`
+	Price       PatchField[float64]           `json:"price"`
+	Categories  PatchField[[]string]          `json:"categories"`
+	Allergens   PatchField[[]string]          `json:"allergens"`
+	Metadata    PatchField[map[string]any]    `json:"metadata"`
+	Ingredients PatchField[[]IngredientPatch] `json:"ingredients"`
+}
+
+type IngredientPatch struct {
+	ItemID   *int64              `json:"item_id"`
+	Quantity PatchField[float64] `json:"quantity"`
+}
+
+func (p MenuItemPatch) Validate() error {
+	var v validation
+	v.check(!p.Name.Set || (!p.Name.Null && p.Name.Value != ""), "name", "cannot be cleared")
+	if p.Price.Set && p.Price.Null {
+		v.add("price", "cannot be cleared")
+	} else if p.Price.Set && p.Price.Value < 0 {
+		v.add("price", "cannot be negative")
+	}
+	v.check(!p.Ingredients.Set || !p.Ingredients.Null, "ingredients", "cannot be cleared")
+	seen := make(map[int64]int, len(p.Ingredients.Value))
+	for i, ing := range p.Ingredients.Value {
+		switch {
+		case ing.ItemID == nil:
+			v.add(elem("ingredients", i, "item_id"), "is required")
+		case *ing.ItemID <= 0:
+			v.add(elem("ingredients", i, "item_id"), "must be greater than 0")
+		default:
+			checkDuplicateIngredient(&v, seen, *ing.ItemID, i)
 		}
-		if *ing.Quantity <= 0 {
-			return fmt.Errorf("invalid quantity: must be greater than 0")
+		if !ing.Quantity.Set {
+			v.add(elem("ingredients", i, "quantity"), "is required, use null to remove the ingredient")
+		} else if !ing.Quantity.Null && ing.Quantity.Value <= 0 {
+			v.add(elem("ingredients", i, "quantity"), "must be greater than 0")
 		}
 	}
-
-	return nil
+	return v.err()
 }
 
 func (r MenuItemRequest) MapToEntity() entity.MenuItem {
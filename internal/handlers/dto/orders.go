@@ -1,13 +1,13 @@
 package dto
 
 import (
-	"fmt"
 	"frappuccino-alem/internal/entity"
 	"time"
 )
 
 type OrderRequest struct {
 	CustomerName        *string             `json:"customer_name"`
+	StaffID             *int64              `json:"staff_id"`
 	PaymentMethod       *string             `json:"payment_method"`
 	SpecialInstructions *entity.JSONB       `json:"special_instructions"`
 	Items               *[]OrderItemRequest `json:"menu_items"`
@@ -21,6 +21,7 @@ type OrderItemRequest struct {
 type OrderResponse struct {
 	ID                  int64               `json:"id"`
 	CustomerName        string              `json:"customer_name"`
+	StaffID             *int64              `json:"staff_id,omitempty"`
 	Status              string              `json:"status"`
 	TotalAmount         float64             `json:"total_amount"`
 	PaymentMethod       string              `json:"payment_method"`
@@ -37,27 +38,30 @@ type OrderItemResponse struct {
 }
 
 func (r OrderRequest) Validate() error {
-	if r.CustomerName == nil || *r.CustomerName == "" {
-		return fmt.Errorf("customer_name is required")
-	}
+	var v validation
+	v.check(r.CustomerName != nil && *r.CustomerName != "", "customer_name", "is required")
 	if r.PaymentMethod == nil || *r.PaymentMethod == "" {
-		return fmt.Errorf("payment_method is required")
+		v.add("payment_method", "is required")
+	} else if !entity.ParsePaymentMethod(*r.PaymentMethod).IsValid() {
+		v.add("payment_method", "must be one of: cash, card, online")
 	}
+	v.check(r.StaffID == nil || *r.StaffID > 0, "staff_id", "must be greater than 0")
 	if r.Items == nil || len(*r.Items) == 0 {
-		return fmt.Errorf("menu_items are required")
-	}
-	if r.PaymentMethod == nil || !entity.ParsePaymentMethod(*r.PaymentMethod).IsValid() {
-		return fmt.Errorf("invalid payment_method %v", entity.ParsePaymentMethod(*r.PaymentMethod).IsValid())
+		v.add("menu_items", "are required")
+		return v.err()
 	}
-	for _, item := range *r.Items {
+	seen := make(map[int64]int, len(*r.Items))
+	for i, item := range *r.Items {
 		if item.MenuItemID <= 0 {
-			return fmt.Errorf("menu_item id must be greater than 0")
-		}
-		if item.Quantity <= 0 {
-			return fmt.Errorf("quantity must be greater than 0")
+			v.add(elem("menu_items", i, "id"), "must be greater than 0")
+		} else if first, ok := seen[item.MenuItemID]; ok {
+			v.add(elem("menu_items", i, "id"), "menu item %d is already listed in menu_items[%d], raise its quantity instead", item.MenuItemID, first)
+		} else {
+			seen[item.MenuItemID] = i
 		}
+		v.check(item.Quantity > 0, elem("menu_items", i, "quantity"), "must be greater than 0")
 	}
-	return nil
+	return v.err()
 }
 
 func (r OrderRequest) MapToEntity() entity.Order {
@@ -75,6 +79,7 @@ func (r OrderRequest) MapToEntity() entity.Order {
 
 	order := entity.Order{
 		PaymentMethod: paymentMethod,
+		StaffID:       r.StaffID,
 	}
 
 	if r.CustomerName != nil {
@@ -111,6 +116,7 @@ func OrderToResponse(entity entity.Order) OrderResponse {
 	return OrderResponse{
 		ID:                  entity.ID,
 		CustomerName:        entity.CustomerName,
+		StaffID:             entity.StaffID,
 		Status:              entity.Status.String(),
 		TotalAmount:         entity.TotalAmount,
 		PaymentMethod:       entity.PaymentMethod.String(),
@@ -119,3 +125,64 @@ func OrderToResponse(entity entity.Order) OrderResponse {
 		CreatedAt:           entity.CreatedAt,
 	}
 }
+
+type OrderEventResponse struct {
+	Type           string         `json:"type"`
+	OrderID        int64          `json:"order_id"`
+	Status         string         `json:"status"`
+	PreviousStatus string         `json:"previous_status,omitempty"`
+	OccurredAt     time.Time      `json:"occurred_at"`
+	Order          *OrderResponse `json:"order,omitempty"`
+}
+
+func OrderEventToResponse(event entity.OrderEvent) OrderEventResponse {
+	response := OrderEventResponse{
+		Type:       event.Type,
+		OrderID:    event.OrderID,
+		Status:     event.Status.String(),
+		OccurredAt: event.OccurredAt,
+	}
+	if event.PreviousStatus.IsValid() {
+		response.PreviousStatus = event.PreviousStatus.String()
+	}
+	if event.Order != nil {
+		order := OrderToResponse(*event.Order)
+		response.Order = &order
+	}
+	return response
+}
+
+type KitchenTicketResponse struct {
+	ID                  int64                 `json:"id"`
+	CustomerName        string                `json:"customer_name"`
+	Status              string                `json:"status"`
+	SpecialInstructions entity.JSONB          `json:"special_instructions"`
+	Items               []KitchenItemResponse `json:"items"`
+	CreatedAt           time.Time             `json:"created_at"`
+	WaitingSeconds      int64                 `json:"waiting_seconds"`
+}
+
+type KitchenItemResponse struct {
+	Name     string `json:"name"`
+	Quantity int64  `json:"quantity"`
+}
+
+func OrderToKitchenTicket(order entity.Order, now time.Time) KitchenTicketResponse {
+	items := make([]KitchenItemResponse, 0, len(order.OrderItems))
+	for _, i := range order.OrderItems {
+		items = append(items, KitchenItemResponse{Name: i.Name, Quantity: i.Quantity})
+	}
+	instructions := order.SpecialInstructions
+	if instructions == nil {
+		instructions = entity.JSONB{}
+	}
+	return KitchenTicketResponse{
+		ID:                  order.ID,
+		CustomerName:        order.CustomerName,
+		Status:              order.Status.String(),
+		SpecialInstructions: instructions,
+		Items:               items,
+		CreatedAt:           order.CreatedAt,
+		WaitingSeconds:      int64(now.Sub(order.CreatedAt).Seconds()),
+	}
+}
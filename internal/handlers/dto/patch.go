@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// PatchField holds one member of an RFC 7396 merge patch document and keeps
+// apart the three states JSON can express: absent, explicit null, and a value.
+type PatchField[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (f *PatchField[T]) UnmarshalJSON(b []byte) error {
+	f.Set = true
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		f.Null = true
+		return nil
+	}
+	return json.Unmarshal(b, &f.Value)
+}
+
+// MergePatch applies an RFC 7396 merge patch object to target and returns the
+// result: null members delete keys, objects merge recursively and any other
+// value replaces what was there.
+func MergePatch(target, patch map[string]any) map[string]any {
+	result := make(map[string]any, len(target)+len(patch))
+	for k, v := range target {
+		result[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		if patchObj, ok := v.(map[string]any); ok {
+			targetObj, _ := result[k].(map[string]any)
+			result[k] = MergePatch(targetObj, patchObj)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
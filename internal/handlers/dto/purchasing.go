@@ -0,0 +1,254 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+type SupplierRequest struct {
+	Name        *string               `json:"name"`
+	ContactName string                `json:"contact_name"`
+	Email       string                `json:"email"`
+	Phone       string                `json:"phone"`
+	Address     string                `json:"address"`
+	Items       []SupplierItemRequest `json:"items"`
+}
+
+type SupplierItemRequest struct {
+	InventoryID  *int64   `json:"inventory_id"`
+	UnitPrice    *float64 `json:"unit_price"`
+	LeadTimeDays int      `json:"lead_time_days"`
+}
+
+func (r SupplierRequest) Validate() error {
+	var v validation
+	v.check(r.Name != nil && strings.TrimSpace(*r.Name) != "", "name", "is required")
+	v.check(r.Email == "" || strings.Contains(r.Email, "@"), "email", "is not an email address")
+	seen := make(map[int64]int, len(r.Items))
+	for i, item := range r.Items {
+		checkInventoryID(&v, seen, "items", i, item.InventoryID)
+		if item.UnitPrice == nil {
+			v.add(elem("items", i, "unit_price"), "is required")
+		} else if *item.UnitPrice < 0 {
+			v.add(elem("items", i, "unit_price"), "cannot be negative")
+		}
+		v.check(item.LeadTimeDays >= 0, elem("items", i, "lead_time_days"), "cannot be negative")
+	}
+	return v.err()
+}
+
+// checkInventoryID records a missing inventory_id in the i-th element of list,
+// or one that an earlier element already lists.
+func checkInventoryID(v *validation, seen map[int64]int, list string, i int, id *int64) {
+	field := elem(list, i, "inventory_id")
+	if id == nil {
+		v.add(field, "is required")
+		return
+	}
+	if first, ok := seen[*id]; ok {
+		v.add(field, "inventory item %d is already listed in %s[%d]", *id, list, first)
+		return
+	}
+	seen[*id] = i
+}
+
+func (r SupplierRequest) MapToEntity() entity.Supplier {
+	supplier := entity.Supplier{
+		Name:        strings.TrimSpace(*r.Name),
+		ContactName: r.ContactName,
+		Email:       r.Email,
+		Phone:       r.Phone,
+		Address:     r.Address,
+		Items:       make([]entity.SupplierItem, 0, len(r.Items)),
+	}
+	for _, item := range r.Items {
+		supplier.Items = append(supplier.Items, entity.SupplierItem{
+			InventoryID:  *item.InventoryID,
+			UnitPrice:    *item.UnitPrice,
+			LeadTimeDays: item.LeadTimeDays,
+		})
+	}
+	return supplier
+}
+
+type SupplierResponse struct {
+	ID          int64                  `json:"id"`
+	Name        string                 `json:"name"`
+	ContactName string                 `json:"contact_name"`
+	Email       string                 `json:"email"`
+	Phone       string                 `json:"phone"`
+	Address     string                 `json:"address"`
+	Items       []SupplierItemResponse `json:"items"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+type SupplierItemResponse struct {
+	InventoryID  int64   `json:"inventory_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	UnitPrice    float64 `json:"unit_price"`
+	LeadTimeDays int     `json:"lead_time_days"`
+}
+
+func SupplierToResponse(s entity.Supplier) SupplierResponse {
+	response := SupplierResponse{
+		ID:          s.ID,
+		Name:        s.Name,
+		ContactName: s.ContactName,
+		Email:       s.Email,
+		Phone:       s.Phone,
+		Address:     s.Address,
+		Items:       make([]SupplierItemResponse, 0, len(s.Items)),
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+	for _, item := range s.Items {
+		response.Items = append(response.Items, SupplierItemResponse{
+			InventoryID:  item.InventoryID,
+			Name:         item.ItemName,
+			Unit:         item.Unit,
+			UnitPrice:    item.UnitPrice,
+			LeadTimeDays: item.LeadTimeDays,
+		})
+	}
+	return response
+}
+
+// PurchaseOrderRequest creates or replaces a draft. Lines without a
+// unit_cost are priced from the supplier's price list; supplier_id is
+// ignored on updates.
+type PurchaseOrderRequest struct {
+	SupplierID *int64                     `json:"supplier_id"`
+	Notes      string                     `json:"notes"`
+	ExpectedAt *time.Time                 `json:"expected_at"`
+	Lines      []PurchaseOrderLineRequest `json:"lines"`
+}
+
+type PurchaseOrderLineRequest struct {
+	InventoryID *int64   `json:"inventory_id"`
+	Quantity    *float64 `json:"quantity"`
+	UnitCost    *float64 `json:"unit_cost"`
+}
+
+func (r PurchaseOrderRequest) Validate() error {
+	var v validation
+	v.check(r.SupplierID != nil, "supplier_id", "is required")
+	seen := make(map[int64]int, len(r.Lines))
+	for i, line := range r.Lines {
+		checkInventoryID(&v, seen, "lines", i, line.InventoryID)
+		v.check(line.Quantity != nil && *line.Quantity > 0, elem("lines", i, "quantity"), "must be greater than 0")
+		v.check(line.UnitCost == nil || *line.UnitCost >= 0, elem("lines", i, "unit_cost"), "cannot be negative")
+	}
+	return v.err()
+}
+
+func (r PurchaseOrderRequest) MapToEntity() entity.PurchaseOrder {
+	po := entity.PurchaseOrder{
+		SupplierID: *r.SupplierID,
+		Notes:      r.Notes,
+		ExpectedAt: r.ExpectedAt,
+		Lines:      make([]entity.PurchaseOrderLine, 0, len(r.Lines)),
+	}
+	for _, line := range r.Lines {
+		l := entity.PurchaseOrderLine{InventoryID: *line.InventoryID, QuantityOrdered: *line.Quantity}
+		if line.UnitCost != nil {
+			l.UnitCost = *line.UnitCost
+		}
+		po.Lines = append(po.Lines, l)
+	}
+	return po
+}
+
+// ReceiveRequest lists what was delivered. An empty list receives
+// everything outstanding.
+type ReceiveRequest struct {
+	Lines []ReceiptLineRequest `json:"lines"`
+}
+
+type ReceiptLineRequest struct {
+	InventoryID *int64     `json:"inventory_id"`
+	Quantity    *float64   `json:"quantity"`
+	UnitCost    *float64   `json:"unit_cost"`
+	LotCode     string     `json:"lot_code"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func (r ReceiveRequest) Validate() error {
+	var v validation
+	seen := make(map[int64]int, len(r.Lines))
+	for i, line := range r.Lines {
+		checkInventoryID(&v, seen, "lines", i, line.InventoryID)
+		v.check(line.Quantity != nil && *line.Quantity > 0, elem("lines", i, "quantity"), "must be greater than 0")
+		v.check(line.UnitCost == nil || *line.UnitCost >= 0, elem("lines", i, "unit_cost"), "cannot be negative")
+	}
+	return v.err()
+}
+
+func (r ReceiveRequest) MapToEntity() []entity.PurchaseOrderReceipt {
+	receipts := make([]entity.PurchaseOrderReceipt, 0, len(r.Lines))
+	for _, line := range r.Lines {
+		receipts = append(receipts, entity.PurchaseOrderReceipt{
+			InventoryID: *line.InventoryID,
+			Quantity:    *line.Quantity,
+			UnitCost:    line.UnitCost,
+			LotCode:     line.LotCode,
+			ExpiresAt:   line.ExpiresAt,
+		})
+	}
+	return receipts
+}
+
+type PurchaseOrderResponse struct {
+	ID           int64                       `json:"id"`
+	SupplierID   int64                       `json:"supplier_id"`
+	SupplierName string                      `json:"supplier_name"`
+	Status       string                      `json:"status"`
+	Notes        string                      `json:"notes"`
+	ExpectedAt   *time.Time                  `json:"expected_at,omitempty"`
+	SentAt       *time.Time                  `json:"sent_at,omitempty"`
+	ReceivedAt   *time.Time                  `json:"received_at,omitempty"`
+	Total        float64                     `json:"total"`
+	Lines        []PurchaseOrderLineResponse `json:"lines"`
+	CreatedAt    time.Time                   `json:"created_at"`
+	UpdatedAt    time.Time                   `json:"updated_at"`
+}
+
+type PurchaseOrderLineResponse struct {
+	InventoryID      int64   `json:"inventory_id"`
+	Name             string  `json:"name"`
+	Unit             string  `json:"unit"`
+	QuantityOrdered  float64 `json:"quantity_ordered"`
+	QuantityReceived float64 `json:"quantity_received"`
+	UnitCost         float64 `json:"unit_cost"`
+}
+
+func PurchaseOrderToResponse(po entity.PurchaseOrder) PurchaseOrderResponse {
+	response := PurchaseOrderResponse{
+		ID:           po.ID,
+		SupplierID:   po.SupplierID,
+		SupplierName: po.SupplierName,
+		Status:       po.Status,
+		Notes:        po.Notes,
+		ExpectedAt:   po.ExpectedAt,
+		SentAt:       po.SentAt,
+		ReceivedAt:   po.ReceivedAt,
+		Total:        po.Total(),
+		Lines:        make([]PurchaseOrderLineResponse, 0, len(po.Lines)),
+		CreatedAt:    po.CreatedAt,
+		UpdatedAt:    po.UpdatedAt,
+	}
+	for _, line := range po.Lines {
+		response.Lines = append(response.Lines, PurchaseOrderLineResponse{
+			InventoryID:      line.InventoryID,
+			Name:             line.ItemName,
+			Unit:             line.Unit,
+			QuantityOrdered:  line.QuantityOrdered,
+			QuantityReceived: line.QuantityReceived,
+			UnitCost:         line.UnitCost,
+		})
+	}
+	return response
+}
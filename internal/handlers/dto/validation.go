@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is one problem with one property of a request. Field is the
+// property's path in the body, such as menu_items[1].quantity.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is every problem found in a request, so a client can fix
+// them all in one go instead of one per round trip.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validation collects the problems found by a Validate method.
+type validation struct {
+	errs ValidationErrors
+}
+
+func (v *validation) add(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// check records message against field unless ok holds.
+func (v *validation) check(ok bool, field, message string) {
+	if !ok {
+		v.add(field, "%s", message)
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// elem is the path of property name of the i-th element of list.
+func elem(list string, i int, name string) string {
+	return fmt.Sprintf("%s[%d].%s", list, i, name)
+}
@@ -0,0 +1,124 @@
+package dto
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+type WebhookSubscriptionRequest struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret is optional, one is generated when it is left out.
+	Secret *string `json:"secret"`
+}
+
+func (r WebhookSubscriptionRequest) Validate() error {
+	var v validation
+	if r.URL == nil || *r.URL == "" {
+		v.add("url", "is required")
+	} else if u, err := url.Parse(*r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("url", "must be an absolute http or https URL")
+	}
+	supported := strings.Join(entity.WebhookEventTypes, ", ")
+	v.check(len(r.EventTypes) > 0, "event_types", "are required, supported: "+supported)
+	seen := make(map[string]int, len(r.EventTypes))
+	for i, t := range r.EventTypes {
+		field := fmt.Sprintf("event_types[%d]", i)
+		if !entity.IsValidWebhookEvent(t) {
+			v.add(field, "unknown event type %q, supported: %s", t, supported)
+		} else if first, ok := seen[t]; ok {
+			v.add(field, "event type %q is already listed in event_types[%d]", t, first)
+		} else {
+			seen[t] = i
+		}
+	}
+	v.check(r.Secret == nil || len(*r.Secret) >= 16, "secret", "must be at least 16 characters long")
+	return v.err()
+}
+
+func (r WebhookSubscriptionRequest) MapToEntity() entity.WebhookSubscription {
+	sub := entity.WebhookSubscription{
+		URL:        *r.URL,
+		EventTypes: r.EventTypes,
+	}
+	if r.Secret != nil {
+		sub.Secret = *r.Secret
+	}
+	return sub
+}
+
+type WebhookSubscriptionResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookSubscriptionToResponse leaves the secret out; it is only shown once,
+// in the response to the create request.
+func WebhookSubscriptionToResponse(sub entity.WebhookSubscription) WebhookSubscriptionResponse {
+	return WebhookSubscriptionResponse{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		Active:     sub.Active,
+		CreatedAt:  sub.CreatedAt,
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64                    `json:"id"`
+	EventID        int64                    `json:"event_id"`
+	EventType      string                   `json:"event_type"`
+	Status         string                   `json:"status"`
+	Attempts       int                      `json:"attempts"`
+	NextAttemptAt  *time.Time               `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int                     `json:"last_status_code,omitempty"`
+	LastError      string                   `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time               `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time                `json:"created_at"`
+	Log            []WebhookAttemptResponse `json:"log"`
+}
+
+type WebhookAttemptResponse struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+func WebhookDeliveryToResponse(d entity.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+		Log:            make([]WebhookAttemptResponse, 0, len(d.Log)),
+	}
+	if d.Status == entity.DeliveryPending {
+		next := d.NextAttemptAt
+		response.NextAttemptAt = &next
+	}
+	for _, a := range d.Log {
+		response.Log = append(response.Log, WebhookAttemptResponse{
+			Attempt:     a.Attempt,
+			StatusCode:  a.StatusCode,
+			Error:       a.Error,
+			DurationMs:  a.Duration.Milliseconds(),
+			AttemptedAt: a.AttemptedAt,
+		})
+	}
+	return response
+}
@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"testing"
+
+	"frappuccino-alem/internal/entity"
+)
+
+func TestWebhookSubscriptionRequestValidatesURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://example.com/hooks"},
+		{url: "http://localhost:8080/hooks"},
+		{url: "", wantErr: true},
+		{url: "/hooks", wantErr: true},
+		{url: "example.com/hooks", wantErr: true},
+		{url: "ftp://example.com/hooks", wantErr: true},
+		{url: "https:///hooks", wantErr: true},
+		{url: "https://exa mple.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := WebhookSubscriptionRequest{URL: &tt.url, EventTypes: []string{entity.EventOrderCompleted}}
+			err := req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
 	"frappuccino-alem/internal/service"
+	"frappuccino-alem/internal/store"
 	"frappuccino-alem/internal/utils"
 )
 
@@ -34,23 +39,40 @@ func (h *InventoryHandler) RegisterEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /inventory/{id}", h.updateInventoryItemById)
 	mux.HandleFunc("PUT /inventory/{id}/", h.updateInventoryItemById)
 
+	mux.HandleFunc("PATCH /inventory/{id}", h.patchInventoryItemById)
+	mux.HandleFunc("PATCH /inventory/{id}/", h.patchInventoryItemById)
+
 	mux.HandleFunc("DELETE /inventory/{id}", h.deleteInventoryItemById)
 	mux.HandleFunc("DELETE /inventory/{id}/", h.deleteInventoryItemById)
 
+	mux.HandleFunc("POST /inventory/{id}/waste", h.logWaste)
+	mux.HandleFunc("POST /inventory/{id}/waste/", h.logWaste)
+
+	mux.HandleFunc("POST /inventory/{id}/batches", h.createBatch)
+	mux.HandleFunc("POST /inventory/{id}/batches/", h.createBatch)
+
+	mux.HandleFunc("GET /inventory/{id}/batches", h.getBatches)
+	mux.HandleFunc("GET /inventory/{id}/batches/", h.getBatches)
+
+	mux.HandleFunc("GET /inventory/expiring", h.getExpiringBatches)
+
 	mux.HandleFunc("GET /inventory/getLeftOvers", h.GetLeftOvers)
+
+	mux.HandleFunc("POST /inventory/import", h.importInventoryItems)
+	mux.HandleFunc("GET /inventory/export", h.exportInventoryItems)
 }
 
 func (h *InventoryHandler) createInventoryItem(w http.ResponseWriter, r *http.Request) {
 	var req dto.InventoryItemRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
 		h.logger.Error("Failed to parse inventory item request", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid request payload"))
+		writeParseError(w, err)
 		return
 	}
 
-	if err := validateInventoryItem(req); err != nil {
+	if err := req.Validate(); err != nil {
 		h.logger.Error("Some of the fields are incorrect", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, err)
+		writeValidationError(w, err)
 		return
 	}
 
@@ -105,6 +127,7 @@ func (h *InventoryHandler) getInventoryItemById(w http.ResponseWriter, r *http.R
 		return
 	}
 	h.logger.Info("Succeded to get inventory item - ", slog.Int64("id", item.ID), slog.String("Name", item.ItemName))
+	w.Header().Set("ETag", utils.ETag(item.ID, item.UpdatedAt))
 	utils.WriteJSON(w, http.StatusOK, item)
 }
 
@@ -115,23 +138,76 @@ func (h *InventoryHandler) updateInventoryItemById(w http.ResponseWriter, r *htt
 		utils.WriteError(w, http.StatusBadRequest, errors.New("Cannot convert inventory id to integer value"))
 		return
 	}
+	if r.Header.Get("If-Match") == "" {
+		utils.WriteError(w, http.StatusPreconditionRequired, errIfMatchRequired)
+		return
+	}
 	var itemRequest dto.InventoryItemRequest
 	if err := utils.ParseJSON(r, &itemRequest); err != nil {
 		h.logger.Error("Failed to parse inventory item request", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, errors.New("Invalid request payload"))
+		writeParseError(w, err)
+		return
+	}
+	if err := itemRequest.Validate(); err != nil {
+		h.logger.Error("Some of the fields are incorrect", "error", err.Error())
+		writeValidationError(w, err)
 		return
 	}
 	h.logger.Debug("update request ", "itemRequest", itemRequest)
-	err = h.service.UpdateInventoryItemById(r.Context(), int64(id), itemRequest)
+	err = h.service.UpdateInventoryItemById(r.Context(), int64(id), itemRequest, r.Header.Get("If-Match"))
 	if err != nil {
 		h.logger.Error("Failed to update inventory item", slog.Int("id", id), "error", err.Error())
-		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to update inventory item"))
+		h.handleUpdateError(w, id, err)
 		return
 	}
 	h.logger.Info("Succeeded to update inventory item", slog.Int("id", id))
 	utils.WriteMessage(w, http.StatusOK, "Updated inventory item")
 }
 
+func (h *InventoryHandler) patchInventoryItemById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Cannot convert inventory id to integer value", "error", err.Error())
+		utils.WriteError(w, http.StatusBadRequest, errors.New("Cannot convert inventory id to integer value"))
+		return
+	}
+	if !isMergePatch(r) {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/merge-patch+json"))
+		return
+	}
+	var patch dto.InventoryItemPatch
+	if err := utils.ParseJSON(r, &patch); err != nil {
+		h.logger.Error("Failed to parse inventory item patch", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		h.logger.Error("Some of the fields are incorrect", "error", err.Error())
+		writeValidationError(w, err)
+		return
+	}
+
+	err = h.service.PatchInventoryItemById(r.Context(), int64(id), patch, r.Header.Get("If-Match"))
+	if err != nil {
+		h.logger.Error("Failed to patch inventory item", slog.Int("id", id), "error", err.Error())
+		h.handleUpdateError(w, id, err)
+		return
+	}
+	h.logger.Info("Succeeded to patch inventory item", slog.Int("id", id))
+	utils.WriteMessage(w, http.StatusOK, "Updated inventory item")
+}
+
+func (h *InventoryHandler) handleUpdateError(w http.ResponseWriter, id int, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("Item with id %v not found", id))
+	case errors.Is(err, store.ErrPreconditionFailed):
+		utils.WriteError(w, http.StatusPreconditionFailed, errors.New("inventory item was modified by another request"))
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to update inventory item"))
+	}
+}
+
 func (h *InventoryHandler) deleteInventoryItemById(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
@@ -140,6 +216,10 @@ func (h *InventoryHandler) deleteInventoryItemById(w http.ResponseWriter, r *htt
 		return
 	}
 	item, err := h.service.DeleteInventoryItemById(r.Context(), int64(id))
+	if errors.Is(err, store.ErrConflict) {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("Item with id %v is on a purchase order", id))
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to get inventory item", slog.Int("id", id), "error", err.Error())
 		utils.WriteError(w, http.StatusNotFound, errors.New(fmt.Sprintf("Item with id %v not found", id)))
@@ -149,6 +229,154 @@ func (h *InventoryHandler) deleteInventoryItemById(w http.ResponseWriter, r *htt
 	utils.WriteMessage(w, http.StatusNotFound, fmt.Sprintf("Deleted inventory item %v", item.ItemName))
 }
 
+// logWaste takes spoiled, spilled, expired or comped stock out of the
+// inventory.
+func (h *InventoryHandler) logWaste(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.WasteRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse waste request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	entry, err := h.service.LogWaste(r.Context(), req.MapToEntity(id))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("Item with id %v not found", id))
+		return
+	case errors.Is(err, store.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	case errors.Is(err, store.ErrInvalidInput):
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		h.logger.Error("Failed to log waste", slog.Int64("id", id), "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to log waste"))
+		return
+	}
+	h.logger.Info("Logged waste", slog.Int64("id", id), slog.String("reason", entry.Reason), slog.Float64("quantity", entry.Quantity))
+	utils.WriteJSON(w, http.StatusCreated, dto.WasteToResponse(entry))
+}
+
+func (h *InventoryHandler) createBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.BatchRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse batch request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	batch, err := h.service.CreateBatch(r.Context(), req.MapToEntity(id))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("Item with id %v not found", id))
+		return
+	case errors.Is(err, store.ErrInvalidInput):
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		h.logger.Error("Failed to create batch", slog.Int64("id", id), "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to create batch"))
+		return
+	}
+	h.logger.Info("Received inventory batch", slog.Int64("id", id), slog.Int64("batch_id", batch.ID))
+	utils.WriteJSON(w, http.StatusCreated, dto.BatchToResponse(batch, time.Now()))
+}
+
+func (h *InventoryHandler) getBatches(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	batches, err := h.service.GetBatches(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("Item with id %v not found", id))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get batches", slog.Int64("id", id), "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to get batches"))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, batchesToResponse(batches))
+}
+
+// getExpiringBatches lists batches with stock left that expire within
+// ?within= (default 48h), expired ones included. within is a duration such
+// as 36h or a number of days such as 3d.
+func (h *InventoryHandler) getExpiringBatches(w http.ResponseWriter, r *http.Request) {
+	within := 48 * time.Hour
+	if s := r.URL.Query().Get("within"); s != "" {
+		var err error
+		if within, err = parseWithin(s); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid within: %w", err))
+			return
+		}
+	}
+
+	batches, err := h.service.GetExpiringBatches(r.Context(), within)
+	if err != nil {
+		h.logger.Error("Failed to get expiring batches", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to get expiring batches"))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, batchesToResponse(batches))
+}
+
+func batchesToResponse(batches []entity.InventoryBatch) []dto.BatchResponse {
+	now := time.Now()
+	response := make([]dto.BatchResponse, 0, len(batches))
+	for _, b := range batches {
+		response = append(response, dto.BatchToResponse(b, now))
+	}
+	return response
+}
+
+// parseWithin reads a positive duration of at most a year, accepting whole
+// days with a d suffix on top of what time.ParseDuration understands.
+func parseWithin(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("expected a duration such as 48h or 3d")
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, errors.New("expected a duration such as 48h or 3d")
+		}
+	}
+	if d <= 0 || d > 365*24*time.Hour {
+		return 0, errors.New("must be greater than zero and at most 365d")
+	}
+	return d, nil
+}
+
 func (h *InventoryHandler) GetLeftOvers(w http.ResponseWriter, r *http.Request) {
 	validSortByOptions := []dto.SortOption{
 		dto.SortByQuantity,
@@ -173,32 +401,85 @@ func (h *InventoryHandler) GetLeftOvers(w http.ResponseWriter, r *http.Request)
 	utils.WriteJSON(w, http.StatusOK, response)
 }
 
-func validateInventoryItem(item dto.InventoryItemRequest) error {
-	if item.Name == nil {
-		return errors.New("item name is required")
-	}
-	if item.Quantity == nil {
-		return errors.New("item quantity is required")
+func (h *InventoryHandler) importInventoryItems(w http.ResponseWriter, r *http.Request) {
+	format, err := importFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
 	}
-	if item.UnitType == nil {
-		return errors.New("item unit is required")
+
+	rows, rowErrs, err := dto.ParseInventoryImport(r.Body, format)
+	if err != nil {
+		h.logger.Error("Failed to parse inventory import", "error", err.Error())
+		writeParseError(w, err)
+		return
 	}
 
-	if *item.Name == "" {
-		return errors.New("item name cannot be empty")
+	items := make([]entity.InventoryItem, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if row.Name == nil && hasRowError(rowErrs, i+1) {
+			continue
+		}
+		if err := row.Validate(); err != nil {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		key := strings.ToLower(*row.Name)
+		if first, ok := seen[key]; ok {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: fmt.Sprintf("duplicate name, already in row %d", first)})
+			continue
+		}
+		seen[key] = i + 1
+		items = append(items, row.MapToEntity())
 	}
-	if *item.Quantity <= 0 {
-		return errors.New("stock level must be greater than zero")
+	if len(rowErrs) > 0 {
+		sortRowErrors(rowErrs)
+		writeRowErrors(w, rowErrs)
+		return
+	}
+
+	result, err := h.service.ImportInventoryItems(r.Context(), items)
+	if err != nil {
+		h.logger.Error("Failed to import inventory items", "error", err.Error())
+		writeImportError(w, err)
+		return
 	}
-	if *item.UnitType == "" {
-		return errors.New("unit type cannot be empty")
+	h.logger.Info("Imported inventory items", slog.Int("created", result.Created), slog.Int("updated", result.Updated))
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *InventoryHandler) exportInventoryItems(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
 	}
 
-	if item.Price == nil {
-		return errors.New("item price is required")
+	startExport(w, format, "inventory")
+	if format == dto.FormatCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(dto.InventoryCSVHeader)
+		err = h.service.ExportInventoryItems(r.Context(), func(item entity.InventoryItem) error {
+			return cw.Write([]string{
+				item.ItemName,
+				strconv.FormatFloat(item.Quantity, 'f', -1, 64),
+				item.Unit,
+				strconv.FormatFloat(item.Price, 'f', -1, 64),
+			})
+		})
+		cw.Flush()
+	} else {
+		aw := &jsonArrayWriter{w: w}
+		err = h.service.ExportInventoryItems(r.Context(), func(item entity.InventoryItem) error {
+			return aw.Write(dto.InventoryItemToLeftOver(item))
+		})
+		if err == nil {
+			err = aw.Close()
+		}
 	}
-	if *item.Price <= 0 {
-		return errors.New("price must be greater than zero")
+	if err != nil {
+		// the status line is already sent, all that is left is to log it
+		h.logger.Error("Failed to export inventory items", "error", err.Error())
 	}
-	return nil
 }
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/service"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+// inventoryRepo holds a single item and applies updates to it the way the
+// postgres store does: only a successful update that changed something is
+// kept.
+type inventoryRepo struct {
+	store.InventoryRepository
+
+	item entity.InventoryItem
+}
+
+func (r *inventoryRepo) GetInventoryItemById(ctx context.Context, id int64) (entity.InventoryItem, error) {
+	if id != r.item.ID {
+		return entity.InventoryItem{}, store.ErrNotFound
+	}
+	return r.item, nil
+}
+
+func (r *inventoryRepo) UpdateByID(ctx context.Context, id int64, updateFn func(item *entity.InventoryItem) (bool, error)) error {
+	if id != r.item.ID {
+		return store.ErrNotFound
+	}
+	item := r.item
+	updated, err := updateFn(&item)
+	if err != nil {
+		return err
+	}
+	if updated {
+		r.item = item
+	}
+	return nil
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// send serves a request with a JSON body and an optional If-Match header.
+func send(mux *http.ServeMux, method, path, contentType, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUpdateInventoryItemIfMatch(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	current := utils.ETag(1, updatedAt)
+	const body = `{"name":"Oat milk","quantity":12,"unit":"l","price":2.5}`
+
+	tests := []struct {
+		name     string
+		ifMatch  string
+		status   int
+		replaced bool
+	}{
+		{"missing", "", http.StatusPreconditionRequired, false},
+		{"stale", utils.ETag(1, updatedAt.Add(-time.Minute)), http.StatusPreconditionFailed, false},
+		{"weak", "W/" + current, http.StatusPreconditionFailed, false},
+		{"matching", current, http.StatusOK, true},
+		{"one of several", `"1-old", ` + current, http.StatusOK, true},
+		{"any", "*", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &inventoryRepo{item: entity.InventoryItem{ID: 1, ItemName: "Milk", Quantity: 10, Unit: "l", Price: 2, UpdatedAt: updatedAt}}
+			mux := http.NewServeMux()
+			NewInventoryHandler(service.NewInventoryService(repo), discardLogger).RegisterEndpoints(mux)
+
+			rec := send(mux, http.MethodPut, "/inventory/1", "application/json", tt.ifMatch, body)
+			if rec.Code != tt.status {
+				t.Errorf("PUT = %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if replaced := repo.item.ItemName == "Oat milk"; replaced != tt.replaced {
+				t.Errorf("item = %+v, replaced %v, want %v", repo.item, replaced, tt.replaced)
+			}
+		})
+	}
+}
+
+func TestPatchInventoryItemWithoutIfMatch(t *testing.T) {
+	repo := &inventoryRepo{item: entity.InventoryItem{ID: 1, ItemName: "Milk", Quantity: 10, Unit: "l", Price: 2}}
+	mux := http.NewServeMux()
+	NewInventoryHandler(service.NewInventoryService(repo), discardLogger).RegisterEndpoints(mux)
+
+	rec := send(mux, http.MethodPatch, "/inventory/1", "application/merge-patch+json", "", `{"quantity":8}`)
+	if rec.Code != http.StatusOK || repo.item.Quantity != 8 || repo.item.ItemName != "Milk" {
+		t.Errorf("PATCH without If-Match = %d, item %+v, want only the quantity changed", rec.Code, repo.item)
+	}
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
 	"frappuccino-alem/internal/service"
 	"frappuccino-alem/internal/store"
@@ -35,26 +39,36 @@ func (h *MenuHandler) RegisterEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /menu/{id}", h.updateMenuItemById)
 	mux.HandleFunc("PUT /menu/{id}/", h.updateMenuItemById)
 
+	mux.HandleFunc("PATCH /menu/{id}", h.patchMenuItemById)
+	mux.HandleFunc("PATCH /menu/{id}/", h.patchMenuItemById)
+
 	mux.HandleFunc("DELETE /menu/{id}", h.deleteMenuItemById)
 	mux.HandleFunc("DELETE /menu/{id}/", h.deleteMenuItemById)
+
+	mux.HandleFunc("POST /menu/import", h.importMenuItems)
+	mux.HandleFunc("GET /menu/export", h.exportMenuItems)
 }
 
 func (h *MenuHandler) createMenuItem(w http.ResponseWriter, r *http.Request) {
 	var req dto.MenuItemRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
 		h.logError("Failed to parse request body", err)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Failed to parse request body"))
+		writeParseError(w, err)
 		return
 	}
 	if err := req.Validate(); err != nil {
 		h.logError("Invalid request", err)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Invalid request: %v", err))
+		writeValidationError(w, err)
 		return
 	}
 	entityItem := req.MapToEntity()
 	item, err := h.service.CreateMenuItem(r.Context(), entityItem)
 	if err != nil {
 		h.logError("Failed to create menu item", err)
+		if errors.Is(err, store.ErrInvalidInput) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Invalid request: %v", err))
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("Failed to create menu item: %v", err))
 		return
 	}
@@ -108,6 +122,7 @@ func (h *MenuHandler) getMenuItemById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", utils.ETag(entityItem.ID, entityItem.UpdatedAt))
 	utils.WriteJSON(w, http.StatusOK, dto.MenuItemToDetailedResponse(entityItem))
 }
 
@@ -117,34 +132,67 @@ func (h *MenuHandler) updateMenuItemById(w http.ResponseWriter, r *http.Request)
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	if r.Header.Get("If-Match") == "" {
+		utils.WriteError(w, http.StatusPreconditionRequired, errIfMatchRequired)
+		return
+	}
 	var req dto.MenuItemRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
 		h.logger.Error("Failed to parse inventory item request", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, errors.New("Invalid request payload"))
+		writeParseError(w, err)
 		return
 	}
 
-	if req.Ingredients != nil {
-		for _, ing := range *req.Ingredients {
-			if *ing.Quantity <= 0 {
-				utils.WriteError(w, http.StatusBadRequest,
-					fmt.Errorf("quantity_used must be greater than 0"))
-				return
-			}
-		}
+	if err := req.Validate(); err != nil {
+		h.logError("Invalid request", err)
+		writeValidationError(w, err)
+		return
 	}
 
 	h.logger.Debug("update request ", "menuRequest", req)
-	err = h.service.UpdateMenuItemById(r.Context(), int64(id), req)
+	err = h.service.UpdateMenuItemById(r.Context(), int64(id), req, r.Header.Get("If-Match"))
 	if err != nil {
 		h.logger.Error("Failed to update menu item", slog.Int64("id", id), "error", err.Error())
-		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to update menu item"))
+		h.handleUpdateError(w, id, err)
 		return
 	}
 	h.logger.Info("Succeeded to update menu item", slog.Int64("id", id))
 	utils.WriteMessage(w, http.StatusOK, "Updated menu item")
 }
 
+func (h *MenuHandler) patchMenuItemById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !isMergePatch(r) {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/merge-patch+json"))
+		return
+	}
+
+	var patch dto.MenuItemPatch
+	if err := utils.ParseJSON(r, &patch); err != nil {
+		h.logError("Failed to parse menu item patch", err)
+		writeParseError(w, err)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		h.logError("Invalid patch", err)
+		writeValidationError(w, err)
+		return
+	}
+
+	err = h.service.PatchMenuItemById(r.Context(), id, patch, r.Header.Get("If-Match"))
+	if err != nil {
+		h.logger.Error("Failed to patch menu item", slog.Int64("id", id), "error", err.Error())
+		h.handleUpdateError(w, id, err)
+		return
+	}
+	h.logger.Info("Succeeded to patch menu item", slog.Int64("id", id))
+	utils.WriteMessage(w, http.StatusOK, "Updated menu item")
+}
+
 func (h *MenuHandler) deleteMenuItemById(w http.ResponseWriter, r *http.Request) {
 	id, err := parsePathID(r, "id")
 	if err != nil {
@@ -160,6 +208,100 @@ func (h *MenuHandler) deleteMenuItemById(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *MenuHandler) importMenuItems(w http.ResponseWriter, r *http.Request) {
+	format, err := importFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	rows, rowErrs, err := dto.ParseMenuImport(r.Body, format)
+	if err != nil {
+		h.logError("Failed to parse menu import", err)
+		writeParseError(w, err)
+		return
+	}
+
+	items := make([]entity.MenuItem, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if row.Name == nil && hasRowError(rowErrs, i+1) {
+			continue
+		}
+		if err := row.Validate(); err != nil {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		key := strings.ToLower(*row.Name)
+		if first, ok := seen[key]; ok {
+			rowErrs = append(rowErrs, entity.ImportRowError{Row: i + 1, Error: fmt.Sprintf("duplicate name, already in row %d", first)})
+			continue
+		}
+		seen[key] = i + 1
+		items = append(items, row.MapToEntity())
+	}
+	if len(rowErrs) > 0 {
+		sortRowErrors(rowErrs)
+		writeRowErrors(w, rowErrs)
+		return
+	}
+
+	result, err := h.service.ImportMenuItems(r.Context(), items)
+	if err != nil {
+		h.logError("Failed to import menu items", err)
+		writeImportError(w, err)
+		return
+	}
+	h.logger.Info("Imported menu items", slog.Int("created", result.Created), slog.Int("updated", result.Updated))
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *MenuHandler) exportMenuItems(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	startExport(w, format, "menu")
+	if format == dto.FormatCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(dto.MenuCSVHeader)
+		err = h.service.ExportMenuItems(r.Context(), func(item entity.MenuItem) error {
+			metadata, err := json.Marshal(item.Metadata)
+			if err != nil {
+				return err
+			}
+			ingredients := make([]string, 0, len(item.Ingredients))
+			for _, ing := range item.Ingredients {
+				ingredients = append(ingredients, ing.Name+":"+strconv.FormatFloat(ing.Quantity, 'f', -1, 64))
+			}
+			return cw.Write([]string{
+				item.Name,
+				item.Description,
+				strconv.FormatFloat(item.Price, 'f', -1, 64),
+				dto.JoinList(item.Categories),
+				dto.JoinList(item.Allergens),
+				string(metadata),
+				dto.JoinList(ingredients),
+			})
+		})
+		cw.Flush()
+	} else {
+		aw := &jsonArrayWriter{w: w}
+		err = h.service.ExportMenuItems(r.Context(), func(item entity.MenuItem) error {
+			return aw.Write(dto.MenuItemToImportRow(item))
+		})
+		if err == nil {
+			err = aw.Close()
+		}
+	}
+	if err != nil {
+		// the status line is already sent, all that is left is to log it
+		h.logError("Failed to export menu items", err)
+	}
+}
+
 // Helper functions
 func (h *MenuHandler) logError(message string, err error) {
 	h.logger.Error(message, "error", err.Error())
@@ -174,6 +316,35 @@ func (h *MenuHandler) handleNotFoundOrError(w http.ResponseWriter, resourceType
 	utils.WriteError(w, http.StatusInternalServerError, err)
 }
 
+func (h *MenuHandler) handleUpdateError(w http.ResponseWriter, id int64, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("menu item with ID %d not found", id))
+	case errors.Is(err, store.ErrPreconditionFailed):
+		utils.WriteError(w, http.StatusPreconditionFailed, errors.New("menu item was modified by another request"))
+	case errors.Is(err, store.ErrInvalidInput):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("Failed to update menu item"))
+	}
+}
+
+// errIfMatchRequired answers a PUT without If-Match. A replacement
+// overwrites every field, so without the ETag it would silently undo
+// whatever changed since the client read the item. A merge patch only
+// touches the fields it names and may leave If-Match out.
+var errIfMatchRequired = errors.New("If-Match is required, send the ETag from GET")
+
+// isMergePatch accepts RFC 7396 documents and, for convenience, plain JSON.
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "application/merge-patch+json", "application/json":
+		return true
+	}
+	return false
+}
+
 func parsePathID(r *http.Request, param string) (int64, error) {
 	idStr := r.PathValue(param)
 	if idStr == "" {
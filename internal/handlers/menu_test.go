@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/service"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+// menuRepo holds a single menu item, like inventoryRepo does.
+type menuRepo struct {
+	store.MenuRepository
+
+	item entity.MenuItem
+}
+
+func (r *menuRepo) UpdateByID(ctx context.Context, id int64, updateFn func(item *entity.MenuItem) (bool, error)) error {
+	if id != r.item.ID {
+		return store.ErrNotFound
+	}
+	item := r.item
+	updated, err := updateFn(&item)
+	if err != nil {
+		return err
+	}
+	if updated {
+		r.item = item
+	}
+	return nil
+}
+
+func TestUpdateMenuItemIfMatch(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	current := utils.ETag(3, updatedAt)
+	const body = `{"name":"Flat white","description":"","price":4,"ingredients":[{"item_id":1,"quantity":0.2}]}`
+
+	tests := []struct {
+		name     string
+		path     string
+		ifMatch  string
+		status   int
+		replaced bool
+	}{
+		{"missing", "/menu/3", "", http.StatusPreconditionRequired, false},
+		{"missing, trailing slash", "/menu/3/", "", http.StatusPreconditionRequired, false},
+		{"stale", "/menu/3", utils.ETag(3, updatedAt.Add(-time.Second)), http.StatusPreconditionFailed, false},
+		{"another item's", "/menu/3", utils.ETag(4, updatedAt), http.StatusPreconditionFailed, false},
+		{"matching", "/menu/3", current, http.StatusOK, true},
+		{"matching, trailing slash", "/menu/3/", current, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &menuRepo{item: entity.MenuItem{ID: 3, Name: "Latte", Price: 3.5, UpdatedAt: updatedAt}}
+			inventory := &inventoryRepo{item: entity.InventoryItem{ID: 1, ItemName: "Milk", Unit: "l", Price: 2}}
+			mux := http.NewServeMux()
+			NewMenuHandler(service.NewMenuService(repo, inventory), discardLogger).RegisterEndpoints(mux)
+
+			rec := send(mux, http.MethodPut, tt.path, "application/json", tt.ifMatch, body)
+			if rec.Code != tt.status {
+				t.Errorf("PUT = %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if replaced := repo.item.Name == "Flat white"; replaced != tt.replaced {
+				t.Errorf("item = %+v, replaced %v, want %v", repo.item, replaced, tt.replaced)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CachePolicy is the Cache-Control of the GET responses Match selects.
+type CachePolicy struct {
+	Match        func(r *http.Request) bool
+	CacheControl string
+}
+
+// maxETagBody is the largest response the caching middleware holds back to
+// compute an ETag. Larger ones, such as big exports, are streamed without.
+const maxETagBody = 2 << 20
+
+// NewCachingMW makes GET responses cacheable. Successful responses get the
+// Cache-Control of the first matching policy and, unless the handler set
+// one, a strong ETag hashed from the body. A request whose If-None-Match
+// matches the ETag is answered with 304 and no body. Headers the handler
+// set itself are kept, and responses that are flushed early, such as event
+// streams, pass through untouched.
+func NewCachingMW(policies []CachePolicy) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cacheControl := ""
+			for _, p := range policies {
+				if p.Match(r) {
+					cacheControl = p.CacheControl
+					break
+				}
+			}
+
+			cw := &cachingWriter{ResponseWriter: w, cacheControl: cacheControl}
+			next.ServeHTTP(cw, r)
+			if cw.passThrough {
+				return
+			}
+			if !cw.wroteHeader {
+				cw.WriteHeader(http.StatusOK)
+				if cw.passThrough {
+					return
+				}
+			}
+
+			h := w.Header()
+			etag := h.Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(cw.body.Bytes())
+				etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+				h.Set("ETag", etag)
+			}
+			if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && matchesWeak(noneMatch, etag) {
+				// a 304 carries the validators but none of the body's metadata
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				h.Del("Content-Disposition")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(cw.body.Bytes())
+		})
+	}
+}
+
+// matchesWeak applies the weak comparison If-None-Match calls for: "*"
+// matches anything and W/ prefixes are ignored.
+func matchesWeak(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cachingWriter holds back a successful response so its ETag can be
+// computed before anything is sent. Any other response, a flush or a body
+// over maxETagBody switches it to passing everything through.
+type cachingWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	wroteHeader  bool
+	passThrough  bool
+	body         bytes.Buffer
+}
+
+func (w *cachingWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status != http.StatusOK {
+		w.passThrough = true
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.cacheControl != "" && w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", w.cacheControl)
+	}
+}
+
+func (w *cachingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.body.Len()+len(b) > maxETagBody {
+		if err := w.release(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// release sends what is held back and passes the rest of the response
+// through.
+func (w *cachingWriter) release() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passThrough {
+		return nil
+	}
+	w.passThrough = true
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	w.body = bytes.Buffer{}
+	return err
+}
+
+func (w *cachingWriter) Flush() {
+	if w.release() == nil {
+		http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, for instance
+// to clear the write deadline of an event stream.
+func (w *cachingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
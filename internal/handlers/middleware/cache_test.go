@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCachingMW(t *testing.T) {
+	policies := []CachePolicy{
+		{Match: func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/menu") }, CacheControl: "public, max-age=60"},
+		{Match: func(r *http.Request) bool { return true }, CacheControl: "no-cache"},
+	}
+	h := NewCachingMW(policies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		case "/tagged":
+			w.Header().Set("ETag", `"v7"`)
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"path":"`+r.URL.Path+`"}`)
+	}))
+	get := func(method, path, noneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if noneMatch != "" {
+			req.Header.Set("If-None-Match", noneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	menu := get(http.MethodGet, "/menu", "")
+	etag := menu.Header().Get("ETag")
+	if menu.Code != http.StatusOK || menu.Body.String() != `{"path":"/menu"}` || len(etag) != 34 {
+		t.Fatalf("GET /menu = %d %s with ETag %s", menu.Code, menu.Body, etag)
+	}
+	if got := menu.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q, want the first matching policy's", got)
+	}
+	if again := get(http.MethodGet, "/menu", ""); again.Header().Get("ETag") != etag {
+		t.Errorf("the same body got ETag %s, then %s", etag, again.Header().Get("ETag"))
+	}
+	if other := get(http.MethodGet, "/orders", ""); other.Header().Get("ETag") == etag || other.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("GET /orders headers = %v", other.Header())
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		noneMatch string
+		status    int
+		body      string
+	}{
+		{"matching If-None-Match", http.MethodGet, "/menu", etag, http.StatusNotModified, ""},
+		{"weak If-None-Match", http.MethodGet, "/menu", "W/" + etag, http.StatusNotModified, ""},
+		{"one of several", http.MethodGet, "/menu", `"a", ` + etag, http.StatusNotModified, ""},
+		{"any", http.MethodGet, "/menu", "*", http.StatusNotModified, ""},
+		{"stale If-None-Match", http.MethodGet, "/menu", `"a"`, http.StatusOK, `{"path":"/menu"}`},
+		{"handler's own ETag", http.MethodGet, "/tagged", `"v7"`, http.StatusNotModified, ""},
+		{"HEAD", http.MethodHead, "/menu", etag, http.StatusNotModified, ""},
+		{"error passes through", http.MethodGet, "/missing", "*", http.StatusNotFound, "not found\n"},
+		{"POST passes through", http.MethodPost, "/menu", etag, http.StatusOK, `{"path":"/menu"}`},
+	}
+	for _, tt := range tests {
+		rec := get(tt.method, tt.path, tt.noneMatch)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, rec.Code, rec.Body, tt.status, tt.body)
+		}
+		if rec.Code == http.StatusNotModified && (rec.Header().Get("Content-Type") != "" || rec.Header().Get("ETag") == "") {
+			t.Errorf("%s: 304 headers = %v, want the ETag and no Content-Type", tt.name, rec.Header())
+		}
+	}
+
+	tagged := get(http.MethodGet, "/tagged", "")
+	if tagged.Header().Get("ETag") != `"v7"` || tagged.Header().Get("Cache-Control") != "private" {
+		t.Errorf("GET /tagged headers = %v, want the handler's own", tagged.Header())
+	}
+	if missing := get(http.MethodGet, "/missing", ""); missing.Header().Get("ETag") != "" || missing.Header().Get("Cache-Control") != "" {
+		t.Errorf("GET /missing headers = %v, want no caching headers", missing.Header())
+	}
+}
+
+func TestCachingMWStreamsLargeBodies(t *testing.T) {
+	chunk := strings.Repeat("x", 1<<20)
+	h := NewCachingMW(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 3 {
+			io.WriteString(w, chunk)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/export", nil))
+	if rec.Body.Len() != 3*len(chunk) || rec.Header().Get("ETag") != "" {
+		t.Errorf("got %d bytes with ETag %q, want the whole body without one", rec.Body.Len(), rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"gzip, deflate, br":         "gzip",
+		"deflate, gzip":             "gzip",
+		"GZIP":                      "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0":                  "",
+		"gzip;q=0, deflate;q=0":     "",
+		"gzip;q=0, *":               "deflate",
+		"*":                         "gzip",
+		"*;q=0":                     "",
+		"deflate;q=0.1, *;q=0.2":    "gzip",
+		"gzip; q=0.8 , deflate;q=1": "deflate",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+var largeJSON = `{"items":[` + strings.Repeat(`{"name":"latte"},`, 100) + `{}]}`
+
+// serve runs a request with the given Accept-Encoding through h and
+// returns the response with its body decoded.
+func serve(t *testing.T, h http.Handler, req *http.Request, acceptEncoding string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body io.Reader = rec.Body
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("body is not gzip: %v", err)
+		}
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("body is not deflate: %v", err)
+		}
+		body = zr
+	}
+	decoded, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading the body: %v", err)
+	}
+	return rec, string(decoded)
+}
+
+func TestCompressionMW(t *testing.T) {
+	respond := func(contentType, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, body)
+		})
+	}
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		acceptEncoding string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{"gzip", "application/json", largeJSON, "gzip, deflate", "gzip", `"v1-gzip"`},
+		{"deflate", "application/json", largeJSON, "deflate", "deflate", `"v1-deflate"`},
+		{"text", "text/csv; charset=utf-8", largeJSON, "gzip", "gzip", `"v1-gzip"`},
+		{"gzip refused", "application/json", largeJSON, "gzip;q=0", "", `"v1"`},
+		{"no Accept-Encoding", "application/json", largeJSON, "", "", `"v1"`},
+		{"small body", "application/json", `{"id":1}`, "gzip", "", `"v1-gzip"`},
+		{"binary body", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", largeJSON, "gzip", "", `"v1-gzip"`},
+		{"event stream", "text/event-stream", largeJSON, "gzip", "", `"v1-gzip"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := CompressionMW(respond(tt.contentType, tt.body))
+			rec, body := serve(t, h, httptest.NewRequest(http.MethodGet, "/menu", nil), tt.acceptEncoding)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if body != tt.body {
+				t.Errorf("body = %.40q..., want the handler's", body)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+			if got := rec.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+		})
+	}
+}
+
+func TestCompressionMWKeepsStatus(t *testing.T) {
+	h := CompressionMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, largeJSON)
+	}))
+	rec, body := serve(t, h, httptest.NewRequest(http.MethodPost, "/orders", nil), "gzip")
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != "gzip" || body != largeJSON {
+		t.Errorf("got %d %q, want a compressed 201", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Errorf("Content-Length %s survived compression", rec.Header().Get("Content-Length"))
+	}
+}
+
+func TestCompressionWithCaching(t *testing.T) {
+	h := CompressionMW(NewCachingMW(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, largeJSON)
+	})))
+
+	identity, _ := serve(t, h, httptest.NewRequest(http.MethodGet, "/menu", nil), "")
+	compressed, body := serve(t, h, httptest.NewRequest(http.MethodGet, "/menu", nil), "gzip")
+	etag := identity.Header().Get("ETag")
+	if body != largeJSON || compressed.Header().Get("ETag") != strings.TrimSuffix(etag, `"`)+`-gzip"` {
+		t.Fatalf("compressed ETag = %s, want %s with -gzip", compressed.Header().Get("ETag"), etag)
+	}
+
+	for _, noneMatch := range []string{compressed.Header().Get("ETag"), etag, `"other", ` + compressed.Header().Get("ETag")} {
+		req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+		req.Header.Set("If-None-Match", noneMatch)
+		rec, body := serve(t, h, req, "gzip")
+		if rec.Code != http.StatusNotModified || body != "" {
+			t.Errorf("If-None-Match %s = %d with %d bytes, want an empty 304", noneMatch, rec.Code, len(body))
+		}
+		if rec.Header().Get("ETag") != compressed.Header().Get("ETag") || rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("If-None-Match %s: 304 headers = %v", noneMatch, rec.Header())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+	req.Header.Set("If-None-Match", `"other-gzip"`)
+	if rec, body := serve(t, h, req, "gzip"); rec.Code != http.StatusOK || body != largeJSON {
+		t.Errorf("stale If-None-Match = %d, want the full response", rec.Code)
+	}
+}
+
+func TestCompressionMWStripsConditionalETags(t *testing.T) {
+	const current = `"v1"`
+	h := CompressionMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the handler compares If-Match against its identity ETag, like the
+		// inventory and menu updates do
+		for _, tag := range strings.Split(r.Header.Get("If-Match"), ",") {
+			if strings.TrimSpace(tag) == current {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+
+	tests := []struct {
+		ifMatch string
+		want    int
+	}{
+		{`"v1-gzip"`, http.StatusNoContent},
+		{`"v1-deflate"`, http.StatusNoContent},
+		{`"v1"`, http.StatusNoContent},
+		{`"v0-gzip", "v1-gzip"`, http.StatusNoContent},
+		{`"v0-gzip"`, http.StatusPreconditionFailed},
+		{`"v1-br"`, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/inventory/1", strings.NewReader(`{}`))
+		req.Header.Set("If-Match", tt.ifMatch)
+		if rec, _ := serve(t, h, req, "gzip"); rec.Code != tt.want {
+			t.Errorf("PUT with If-Match %s = %d, want %d", tt.ifMatch, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/config"
+)
+
+func TestCORSMW(t *testing.T) {
+	listed := config.CORS{
+		AllowedOrigins: []string{"https://pos.example", "https://Admin.example"},
+		AllowedMethods: []string{"GET", "POST", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "If-Match"},
+		ExposedHeaders: []string{"ETag", "Retry-After"},
+		MaxAge:         10 * time.Minute,
+	}
+	withCredentials := listed
+	withCredentials.AllowCredentials = true
+	wildcard := listed
+	wildcard.AllowedOrigins = []string{"*"}
+
+	type want struct {
+		status      int
+		allowOrigin string
+		credentials string
+		vary        []string
+		// preflight answers carry the allowed methods and max age, other
+		// responses the exposed headers
+		preflightHeaders bool
+		exposeHeaders    bool
+	}
+	tests := []struct {
+		name      string
+		cfg       config.CORS
+		origin    string
+		preflight bool
+		want      want
+	}{
+		{"allowed origin", listed, "https://pos.example", false,
+			want{status: http.StatusOK, allowOrigin: "https://pos.example", vary: []string{"Origin"}, exposeHeaders: true}},
+		{"allowed origin, other case", listed, "https://admin.EXAMPLE", false,
+			want{status: http.StatusOK, allowOrigin: "https://admin.EXAMPLE", vary: []string{"Origin"}, exposeHeaders: true}},
+		{"allowed preflight", listed, "https://pos.example", true,
+			want{status: http.StatusNoContent, allowOrigin: "https://pos.example", preflightHeaders: true,
+				vary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}}},
+		{"disallowed origin", listed, "https://evil.example", false,
+			want{status: http.StatusOK, vary: []string{"Origin"}}},
+		{"disallowed preflight", listed, "https://evil.example", true,
+			want{status: http.StatusForbidden, vary: []string{"Origin"}}},
+		{"no origin", listed, "", false,
+			want{status: http.StatusOK, vary: []string{"Origin"}}},
+		{"credentials", withCredentials, "https://pos.example", false,
+			want{status: http.StatusOK, allowOrigin: "https://pos.example", credentials: "true", vary: []string{"Origin"}, exposeHeaders: true}},
+		{"credentials preflight", withCredentials, "https://pos.example", true,
+			want{status: http.StatusNoContent, allowOrigin: "https://pos.example", credentials: "true", preflightHeaders: true,
+				vary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}}},
+		{"credentials, disallowed origin", withCredentials, "https://evil.example", false,
+			want{status: http.StatusOK, vary: []string{"Origin"}}},
+		{"wildcard", wildcard, "https://anyone.example", false,
+			want{status: http.StatusOK, allowOrigin: "*", exposeHeaders: true}},
+		{"wildcard preflight", wildcard, "https://anyone.example", true,
+			want{status: http.StatusNoContent, allowOrigin: "*", preflightHeaders: true,
+				vary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"}}},
+		{"wildcard, no origin", wildcard, "", false,
+			want{status: http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewCORSMW(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			method := http.MethodGet
+			if tt.preflight {
+				method = http.MethodOptions
+			}
+			req := httptest.NewRequest(method, "/orders", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "PUT")
+				req.Header.Set("Access-Control-Request-Headers", "if-match")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want.status)
+			}
+			h := rec.Header()
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.want.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.want.allowOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tt.want.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.want.credentials)
+			}
+			if got := h.Values("Vary"); !reflect.DeepEqual(got, tt.want.vary) {
+				t.Errorf("Vary = %q, want %q", got, tt.want.vary)
+			}
+
+			methods, maxAge, headers := "", "", ""
+			if tt.want.preflightHeaders {
+				methods, maxAge, headers = "GET, POST, PUT", "600", "Content-Type, If-Match"
+			}
+			exposed := ""
+			if tt.want.exposeHeaders {
+				exposed = "ETag, Retry-After"
+			}
+			for name, value := range map[string]string{
+				"Access-Control-Allow-Methods":  methods,
+				"Access-Control-Allow-Headers":  headers,
+				"Access-Control-Max-Age":        maxAge,
+				"Access-Control-Expose-Headers": exposed,
+			} {
+				if got := h.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSMWOptionsWithoutPreflight(t *testing.T) {
+	// an OPTIONS request without Access-Control-Request-Method is not a
+	// preflight and reaches the router
+	reached := false
+	h := NewCORSMW(config.CORS{AllowedOrigins: []string{"https://pos.example"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	req := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !reached || rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d, reached the handler: %v", rec.Code, reached)
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/utils"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, rec entity.IdempotencyRecord) (entity.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, rec entity.IdempotencyRecord) error
+	Release(ctx context.Context, key, method, path string) error
+}
+
+// NewIdempotencyMW makes POST requests to the given paths safe to retry when
+// they carry an Idempotency-Key header. The first request with a key runs
+// normally and its response is stored for ttl; a retry with the same key and
+// body replays that response, while the same key with a different body is
+// rejected with 422. POSTs to any other path ignore the header.
+func NewIdempotencyMW(store IdempotencyStore, ttl time.Duration, logger *slog.Logger, paths ...string) Middleware {
+	covered := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		covered[strings.TrimSuffix(path, "/")] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" || !covered[strings.TrimSuffix(r.URL.Path, "/")] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > 255 {
+				utils.WriteError(w, http.StatusBadRequest, errors.New("Idempotency-Key must be at most 255 characters"))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body must be at most %d bytes", tooLarge.Limit))
+					return
+				}
+				utils.WriteError(w, http.StatusBadRequest, errors.New("could not read request body"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec := entity.IdempotencyRecord{
+				Key:         key,
+				Method:      r.Method,
+				Path:        strings.TrimSuffix(r.URL.Path, "/"),
+				RequestHash: hashRequest(r.Method, r.URL.Path, body),
+				ExpiresAt:   time.Now().Add(ttl),
+			}
+
+			stored, reserved, err := store.Reserve(r.Context(), rec)
+			if err != nil {
+				logger.Error("Failed to reserve idempotency key", "key", key, "error", err.Error())
+				utils.WriteError(w, http.StatusInternalServerError, errors.New("could not process Idempotency-Key"))
+				return
+			}
+
+			if !reserved {
+				switch {
+				case stored.RequestHash != rec.RequestHash:
+					utils.WriteError(w, http.StatusUnprocessableEntity,
+						errors.New("Idempotency-Key was already used with a different request"))
+				case !stored.IsComplete():
+					utils.WriteError(w, http.StatusConflict,
+						errors.New("a request with this Idempotency-Key is still being processed"))
+				default:
+					if stored.ContentType != "" {
+						w.Header().Set("Content-Type", stored.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(stored.StatusCode)
+					w.Write(stored.Body)
+				}
+				return
+			}
+
+			// the request context may already be done, the outcome must still be saved
+			ctx := context.WithoutCancel(r.Context())
+			defer func() {
+				// a panicking handler must not leave the key reserved, or
+				// every retry would be turned away with 409
+				if p := recover(); p != nil {
+					if err := store.Release(ctx, rec.Key, rec.Method, rec.Path); err != nil {
+						logger.Error("Failed to release idempotency key", "key", key, "error", err.Error())
+					}
+					panic(p)
+				}
+			}()
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			if rw.status >= http.StatusInternalServerError {
+				if err := store.Release(ctx, rec.Key, rec.Method, rec.Path); err != nil {
+					logger.Error("Failed to release idempotency key", "key", key, "error", err.Error())
+				}
+				return
+			}
+
+			rec.StatusCode = rw.status
+			rec.ContentType = rw.Header().Get("Content-Type")
+			rec.Body = rw.body.Bytes()
+			if err := store.Complete(ctx, rec); err != nil {
+				logger.Error("Failed to store idempotent response", "key", key, "error", err.Error())
+			}
+		})
+	}
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + strings.TrimSuffix(path, "/") + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes the response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// memoryIdempotencyStore keeps records in a map, like the postgres store
+// keeps them in a table keyed by key, method and path.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]entity.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]entity.IdempotencyRecord)}
+}
+
+func idempotencyID(key, method, path string) string {
+	return method + " " + path + " " + key
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, rec entity.IdempotencyRecord) (entity.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := idempotencyID(rec.Key, rec.Method, rec.Path)
+	if existing, ok := s.records[id]; ok && existing.ExpiresAt.After(time.Now()) {
+		return existing, false, nil
+	}
+	s.records[id] = rec
+	return rec, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, rec entity.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[idempotencyID(rec.Key, rec.Method, rec.Path)] = rec
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key, method, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, idempotencyID(key, method, path))
+	return nil
+}
+
+// countingHandler answers every request with respond and counts how often
+// it ran.
+type countingHandler struct {
+	mu      sync.Mutex
+	calls   int
+	respond func(w http.ResponseWriter, r *http.Request, call int)
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.calls++
+	call := h.calls
+	h.mu.Unlock()
+	h.respond(w, r, call)
+}
+
+func (h *countingHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+func newIdempotentHandler(next http.Handler) http.Handler {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewIdempotencyMW(newMemoryIdempotencyStore(), time.Hour, logger, "/orders", "/menu")(next)
+}
+
+func post(h http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func created(w http.ResponseWriter, r *http.Request, call int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, `{"id":`+strconv.Itoa(call)+`}`)
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	next := &countingHandler{respond: created}
+	h := newIdempotentHandler(next)
+
+	first := post(h, "/orders", "k1", `{"a":1}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"id":1}` {
+		t.Fatalf("first request = %d %s", first.Code, first.Body)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first response is marked as replayed")
+	}
+
+	for _, path := range []string{"/orders", "/orders/"} {
+		retry := post(h, path, "k1", `{"a":1}`)
+		if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":1}` {
+			t.Errorf("retry to %s = %d %s, want the stored response", path, retry.Code, retry.Body)
+		}
+		if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("retry to %s headers = %v", path, retry.Header())
+		}
+	}
+	if next.count() != 1 {
+		t.Errorf("handler ran %d times, want once", next.count())
+	}
+
+	// keys are scoped to their path
+	if rec := post(h, "/menu", "k1", `{"a":1}`); rec.Body.String() != `{"id":2}` {
+		t.Errorf("same key on another path = %s, want a new response", rec.Body)
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	next := &countingHandler{respond: created}
+	h := newIdempotentHandler(next)
+
+	post(h, "/orders", "k1", `{"a":1}`)
+	rec := post(h, "/orders", "k1", `{"a":2}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("same key, different body = %d, want 422", rec.Code)
+	}
+	if next.count() != 1 {
+		t.Errorf("handler ran %d times, want once", next.count())
+	}
+}
+
+func TestIdempotencyConflictWhileInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	next := &countingHandler{respond: func(w http.ResponseWriter, r *http.Request, call int) {
+		close(started)
+		<-release
+		created(w, r, call)
+	}}
+	h := newIdempotentHandler(next)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(h, "/orders", "k1", `{"a":1}`) }()
+	<-started
+
+	if rec := post(h, "/orders", "k1", `{"a":1}`); rec.Code != http.StatusConflict {
+		t.Errorf("retry while the first is running = %d, want 409", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Errorf("first request = %d, want 201", rec.Code)
+	}
+	if rec := post(h, "/orders", "k1", `{"a":1}`); rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry after the first finished was not replayed: %d", rec.Code)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter, r *http.Request, call int)
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request, call int) {
+			if call == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			created(w, r, call)
+		}},
+		{"panic", func(w http.ResponseWriter, r *http.Request, call int) {
+			if call == 1 {
+				panic("boom")
+			}
+			created(w, r, call)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingHandler{respond: tt.respond}
+			h := newIdempotentHandler(next)
+
+			func() {
+				defer func() {
+					if p := recover(); p != nil && p != "boom" {
+						panic(p)
+					}
+				}()
+				post(h, "/orders", "k1", `{"a":1}`)
+			}()
+
+			rec := post(h, "/orders", "k1", `{"a":1}`)
+			if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+				t.Errorf("retry = %d replayed %q, want the handler to run again", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+			}
+			if next.count() != 2 {
+				t.Errorf("handler ran %d times, want twice", next.count())
+			}
+		})
+	}
+}
+
+func TestIdempotencyIgnoredRequests(t *testing.T) {
+	next := &countingHandler{respond: created}
+	h := newIdempotentHandler(next)
+
+	post(h, "/orders", "", `{"a":1}`)
+	post(h, "/orders", "", `{"a":1}`)
+	post(h, "/inventory", "k1", `{"a":1}`)
+	post(h, "/inventory", "k1", `{"a":1}`)
+	req := httptest.NewRequest(http.MethodPut, "/orders", strings.NewReader(`{"a":1}`))
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if next.count() != 5 {
+		t.Errorf("handler ran %d times, want every request through", next.count())
+	}
+
+	if rec := post(h, "/orders", strings.Repeat("k", 256), `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("overlong key = %d, want 400", rec.Code)
+	}
+}
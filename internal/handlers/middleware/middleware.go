@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -18,11 +19,22 @@ func NewMiddlewareChain(xs ...Middleware) Middleware {
 	}
 }
 
-func NewTimoutContextMW(timeoutInSec int) func(next http.Handler) http.Handler {
+// NewTimoutContextMW puts a deadline on every request context. Long-lived
+// endpoints such as event streams can be listed in exempt by path.
+func NewTimoutContextMW(timeout time.Duration, exempt ...string) func(next http.Handler) http.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[strings.TrimSuffix(path, "/")] = true
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeoutInSec))
+				if skip[strings.TrimSuffix(r.URL.Path, "/")] {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
 				defer cancel()
 
 				r = r.WithContext(ctx)
@@ -32,6 +44,19 @@ func NewTimoutContextMW(timeoutInSec int) func(next http.Handler) http.Handler {
 	}
 }
 
+// NewBodyLimitMW caps every request body at limit bytes. Reading past the
+// limit fails with *http.MaxBytesError, which handlers answer with 413.
+func NewBodyLimitMW(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RecoveryMW(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
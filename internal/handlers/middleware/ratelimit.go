@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/utils"
+)
+
+// StaffIDHeader names the staff member a request is made by. The API has
+// no authentication of its own, so the header is only trusted on requests
+// a gateway in front of the app forwards.
+const StaffIDHeader = "X-Staff-ID"
+
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit entity.RateLimit) (entity.RateLimitDecision, error)
+}
+
+// RateLimitGroup limits the requests Match selects. Each group has buckets
+// of its own, so a client that exhausts one group can still use the others.
+type RateLimitGroup struct {
+	Name  string
+	Match func(r *http.Request) bool
+	Limit entity.RateLimit
+}
+
+// RateLimitKey identifies the client a request is counted against.
+type RateLimitKey func(r *http.Request) string
+
+// ClientIPKey counts requests per client address. Behind a proxy, header
+// names the header the proxy puts the client address in, such as
+// X-Forwarded-For; the last address in it is the one the proxy added. The
+// header is only read on requests from trustedProxies, anyone else could
+// claim a fresh address for every request.
+func ClientIPKey(header string, trustedProxies []netip.Prefix) RateLimitKey {
+	return func(r *http.Request) string {
+		if !fromTrustedProxy(r, trustedProxies) {
+			return "ip:" + clientIP(r, "")
+		}
+		return "ip:" + clientIP(r, header)
+	}
+}
+
+// StaffKey counts requests per staff member, falling back to the client
+// address for requests without a valid X-Staff-ID. Only trustedProxies may
+// set the header, for the same reason as with ClientIPKey.
+func StaffKey(header string, trustedProxies []netip.Prefix) RateLimitKey {
+	byIP := ClientIPKey(header, trustedProxies)
+	return func(r *http.Request) string {
+		if !fromTrustedProxy(r, trustedProxies) {
+			return byIP(r)
+		}
+		if id, err := strconv.ParseInt(r.Header.Get(StaffIDHeader), 10, 64); err == nil && id > 0 {
+			return "staff:" + strconv.FormatInt(id, 10)
+		}
+		return byIP(r)
+	}
+}
+
+// fromTrustedProxy reports whether the connection a request came in on is
+// from one of the proxies.
+func fromTrustedProxy(r *http.Request, proxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, proxy := range proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func clientIP(r *http.Request, header string) string {
+	if header != "" {
+		values := strings.Split(r.Header.Get(header), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// NewRateLimitMW limits requests with token buckets, one per group and
+// client. The first group matching a request applies; requests no group
+// matches are not limited. Refused requests are answered with 429 and a
+// Retry-After header, and every limited response carries RateLimit-*
+// headers. When the store fails the request is let through: an outage of
+// the limiter should not take the API down with it.
+func NewRateLimitMW(store RateLimitStore, groups []RateLimitGroup, key RateLimitKey, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var group *RateLimitGroup
+			for i := range groups {
+				if groups[i].Match(r) {
+					group = &groups[i]
+					break
+				}
+			}
+			if group == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := key(r)
+			decision, err := store.Take(r.Context(), group.Name+":"+client, group.Limit)
+			if err != nil {
+				logger.Error("Failed to apply rate limit", "group", group.Name, "client", client, "error", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(decision.Reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", group.Limit.Burst, ceilSeconds(group.Limit.Window())))
+			if !decision.Allowed {
+				retryAfter := ceilSeconds(decision.RetryAfter)
+				h.Set("Retry-After", retryAfter)
+				logger.Warn("Rate limit exceeded", "group", group.Name, "client", client)
+				utils.WriteError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded, retry in %s seconds", retryAfter))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds formats d as whole seconds, rounded up so a client waiting
+// that long is never too early.
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// frozenRateLimitStore keeps buckets in memory with the clock stopped, so
+// only the requests themselves drain them.
+type frozenRateLimitStore struct {
+	now     time.Time
+	buckets map[string]entity.RateBucket
+	keys    []string
+	err     error
+}
+
+func (s *frozenRateLimitStore) Take(ctx context.Context, key string, limit entity.RateLimit) (entity.RateLimitDecision, error) {
+	s.keys = append(s.keys, key)
+	if s.err != nil {
+		return entity.RateLimitDecision{}, s.err
+	}
+	bucket, decision := limit.Take(s.buckets[key], s.now)
+	s.buckets[key] = bucket
+	return decision, nil
+}
+
+func TestRateLimitMW(t *testing.T) {
+	store := &frozenRateLimitStore{now: time.Now(), buckets: map[string]entity.RateBucket{}}
+	groups := []RateLimitGroup{{
+		Name:  "search",
+		Match: func(r *http.Request) bool { return r.URL.Path == "/search" },
+		Limit: entity.RateLimit{PerMinute: 30, Burst: 2},
+	}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := NewRateLimitMW(store, groups, ClientIPKey("", nil), logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{name: "first", path: "/search", status: http.StatusNoContent, remaining: "1", reset: "2"},
+		{name: "last in the burst", path: "/search", status: http.StatusNoContent, remaining: "0", reset: "4"},
+		{name: "refused", path: "/search", status: http.StatusTooManyRequests, remaining: "0", reset: "4", retryAfter: "2"},
+		{name: "no group", path: "/menu", status: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		limited := tt.remaining != ""
+		want := map[string]string{
+			"RateLimit-Limit":     "",
+			"RateLimit-Remaining": tt.remaining,
+			"RateLimit-Reset":     tt.reset,
+			"RateLimit-Policy":    "",
+			"Retry-After":         tt.retryAfter,
+		}
+		if limited {
+			want["RateLimit-Limit"] = "2"
+			want["RateLimit-Policy"] = "2;w=4"
+		}
+		for header, value := range want {
+			if got := rec.Header().Get(header); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, header, got, value)
+			}
+		}
+	}
+	if want := "search:ip:192.0.2.1"; store.keys[0] != want {
+		t.Errorf("bucket key = %q, want %q", store.keys[0], want)
+	}
+
+	store.err = errors.New("store down")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("store failure: status %d, want the request let through", rec.Code)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		key        RateLimitKey
+		remoteAddr string
+		forwarded  string
+		staffID    string
+		want       string
+	}{
+		{name: "direct client", key: ClientIPKey("X-Forwarded-For", proxies), remoteAddr: "203.0.113.9:5000", want: "ip:203.0.113.9"},
+		{name: "client through the gateway", key: ClientIPKey("X-Forwarded-For", proxies), remoteAddr: "10.1.2.3:5000",
+			forwarded: "192.0.2.1, 198.51.100.7", want: "ip:198.51.100.7"},
+		{name: "spoofed forwarded address", key: ClientIPKey("X-Forwarded-For", proxies), remoteAddr: "203.0.113.9:5000",
+			forwarded: "198.51.100.7", want: "ip:203.0.113.9"},
+		{name: "no trusted proxies", key: ClientIPKey("X-Forwarded-For", nil), remoteAddr: "10.1.2.3:5000",
+			forwarded: "198.51.100.7", want: "ip:10.1.2.3"},
+		{name: "staff through the gateway", key: StaffKey("X-Forwarded-For", proxies), remoteAddr: "10.1.2.3:5000",
+			forwarded: "198.51.100.7", staffID: "7", want: "staff:7"},
+		{name: "mapped gateway address", key: StaffKey("X-Forwarded-For", proxies), remoteAddr: "[::ffff:10.1.2.3]:5000",
+			forwarded: "198.51.100.7", staffID: "7", want: "staff:7"},
+		{name: "no staff id", key: StaffKey("X-Forwarded-For", proxies), remoteAddr: "10.1.2.3:5000",
+			forwarded: "198.51.100.7", want: "ip:198.51.100.7"},
+		{name: "invalid staff id", key: StaffKey("X-Forwarded-For", proxies), remoteAddr: "10.1.2.3:5000",
+			forwarded: "198.51.100.7", staffID: "0", want: "ip:198.51.100.7"},
+		{name: "spoofed staff id", key: StaffKey("X-Forwarded-For", proxies), remoteAddr: "203.0.113.9:5000",
+			forwarded: "198.51.100.7", staffID: "7", want: "ip:203.0.113.9"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.staffID != "" {
+			req.Header.Set(StaffIDHeader, tt.staffID)
+		}
+		if got := tt.key(req); got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document describing every endpoint.
+// The document is built once from apiOperations, with schemas reflected
+// from the request and response types, so it follows the DTOs as they
+// change.
+type OpenAPIHandler struct {
+	doc []byte
+}
+
+func NewOpenAPIHandler() (*OpenAPIHandler, error) {
+	doc, err := json.Marshal(buildOpenAPI(apiOperations))
+	if err != nil {
+		return nil, fmt.Errorf("build OpenAPI document: %w", err)
+	}
+	return &OpenAPIHandler{doc: doc}, nil
+}
+
+func (h *OpenAPIHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("GET /openapi.json", h.getOpenAPI)
+	mux.HandleFunc("GET /openapi.json/", h.getOpenAPI)
+}
+
+func (h *OpenAPIHandler) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.doc)
+}
+
+// apiOperation describes one route of the API.
+type apiOperation struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	// params are the query and header parameters; path parameters are
+	// read from the path.
+	params []apiParam
+	// request is a value of the JSON request body type, nil for none.
+	// requestTypes are its media types, application/json by default.
+	request      any
+	requestTypes []string
+	status       int
+	// response is a value of the success body type, nil for none.
+	// responseTypes are its media types, application/json by default.
+	response      any
+	responseTypes []string
+	errors        []int
+}
+
+type apiParam struct {
+	name        string
+	in          string // query by default
+	typ         string // string by default
+	description string
+	enum        []string
+	required    bool
+}
+
+// Bodies written by utils.WriteError, utils.WriteMessage, writeRowErrors
+// and writeValidationError.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+type messageBody struct {
+	Message string `json:"message"`
+}
+
+type rowErrorsBody struct {
+	Error  string                  `json:"error"`
+	Errors []entity.ImportRowError `json:"errors"`
+}
+
+type validationErrorsBody struct {
+	Error  string           `json:"error"`
+	Errors []dto.FieldError `json:"errors,omitempty"`
+}
+
+const (
+	mediaJSON        = "application/json"
+	mediaCSV         = "text/csv"
+	mediaMergePatch  = "application/merge-patch+json"
+	mediaEventStream = "text/event-stream"
+)
+
+func buildOpenAPI(ops []apiOperation) map[string]any {
+	schemas := newSchemaRegistry()
+
+	paths := make(map[string]map[string]any)
+	for _, op := range ops {
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]any)
+		}
+		paths[op.path][strings.ToLower(op.method)] = schemas.operation(op)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "frappuccino",
+			"version": "1.0.0",
+			"description": "Coffee shop management API. Every path also answers with a trailing slash. " +
+				"Errors are JSON objects with an error message. Rate limited responses carry RateLimit-Limit, " +
+				"RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers, and a 429 also Retry-After.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.schemas,
+		},
+	}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (s *schemaRegistry) operation(op apiOperation) map[string]any {
+	operation := map[string]any{
+		"tags":    []string{op.tag},
+		"summary": op.summary,
+	}
+
+	params := make([]map[string]any, 0)
+	for _, m := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "integer", "format": "int64"},
+		})
+	}
+	opParams := op.params
+	if op.method == http.MethodPost && slices.Contains(IdempotentPaths, op.path) {
+		opParams = append(opParams[:len(opParams):len(opParams)], apiParam{name: "Idempotency-Key", in: "header",
+			description: "retries with the same key replay the first response"})
+	}
+	conditional := op.method == http.MethodGet && !slices.Contains(op.responseTypes, mediaEventStream)
+	if conditional {
+		// the caching middleware covers every GET
+		opParams = append(opParams[:len(opParams):len(opParams)], apiParam{name: "If-None-Match", in: "header",
+			description: "answered with 304 when the ETag still matches"})
+	}
+	for _, p := range opParams {
+		in, typ := p.in, p.typ
+		if in == "" {
+			in = "query"
+		}
+		if typ == "" {
+			typ = "string"
+		}
+		schema := map[string]any{"type": typ}
+		if len(p.enum) > 0 {
+			schema["enum"] = p.enum
+		}
+		param := map[string]any{"name": p.name, "in": in, "schema": schema}
+		if p.description != "" {
+			param["description"] = p.description
+		}
+		if p.required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+
+	if op.request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content":  s.content(op.request, op.requestTypes),
+		}
+	}
+
+	responses := make(map[string]any)
+	success := map[string]any{"description": http.StatusText(op.status)}
+	if op.response != nil {
+		success["content"] = s.content(op.response, op.responseTypes)
+	}
+	responses[fmt.Sprint(op.status)] = success
+	if conditional {
+		responses[fmt.Sprint(http.StatusNotModified)] = map[string]any{"description": http.StatusText(http.StatusNotModified)}
+	}
+	// any request may be rate limited
+	statuses := append(op.errors[:len(op.errors):len(op.errors)], http.StatusTooManyRequests, http.StatusInternalServerError)
+	if op.request != nil {
+		// every body is size limited and must be of a supported media type
+		statuses = append(statuses, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+	}
+	for _, status := range statuses {
+		body := any(errorBody{})
+		switch {
+		case status == http.StatusUnprocessableEntity:
+			body = rowErrorsBody{}
+		case status == http.StatusBadRequest && op.request != nil:
+			body = validationErrorsBody{}
+		}
+		responses[fmt.Sprint(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content":     s.content(body, nil),
+		}
+	}
+	operation["responses"] = responses
+
+	return operation
+}
+
+// content describes a body of v's type in each of the media types; anything
+// but JSON is described as a plain document.
+func (s *schemaRegistry) content(v any, mediaTypes []string) map[string]any {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{mediaJSON}
+	}
+	content := make(map[string]any, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		switch mediaType {
+		case mediaJSON, mediaMergePatch:
+			content[mediaType] = map[string]any{"schema": s.schema(reflect.TypeOf(v))}
+		case xlsxContentType:
+			content[mediaType] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+		default:
+			content[mediaType] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+	}
+	return content
+}
+
+// schemaRegistry turns Go types into JSON schemas, the way encoding/json
+// would encode them. Named structs become components referenced by name.
+type schemaRegistry struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+	used    map[string]reflect.Type
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]any),
+		names:   make(map[reflect.Type]string),
+		used:    make(map[string]reflect.Type),
+	}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func (s *schemaRegistry) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	case t.Kind() == reflect.Pointer:
+		return nullable(s.schema(t.Elem()))
+	case t.Kind() == reflect.Struct && strings.HasPrefix(t.Name(), "PatchField["):
+		// absent leaves the value alone, null clears it
+		value, _ := t.FieldByName("Value")
+		return nullable(s.schema(value.Type))
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	// interfaces hold anything
+	return map[string]any{}
+}
+
+func nullable(schema map[string]any) map[string]any {
+	if _, ok := schema["$ref"]; ok {
+		return map[string]any{"allOf": []any{schema}, "nullable": true}
+	}
+	out := make(map[string]any, len(schema)+1)
+	for k, v := range schema {
+		out[k] = v
+	}
+	out["nullable"] = true
+	return out
+}
+
+func (s *schemaRegistry) ref(t reflect.Type) map[string]any {
+	name, ok := s.names[t]
+	if !ok {
+		name = s.name(t)
+		s.names[t] = name
+		s.used[name] = t
+		// registered before it is built, so recursive types terminate
+		s.schemas[name] = nil
+		s.schemas[name] = s.object(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+var qualifiedName = regexp.MustCompile(`[\w./-]+\.`)
+
+// name is a component name for t: the type's name, with the package
+// prepended when another package already has a type by that name. Type
+// arguments are appended: PaginationResponse[dto.OrderResponse] becomes
+// PaginationResponse_OrderResponse.
+func (s *schemaRegistry) name(t reflect.Type) string {
+	name := qualifiedName.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[", "_", "]", "", ",", "_", "*", "").Replace(name)
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if other, ok := s.used[name]; ok && other != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (s *schemaRegistry) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds the JSON members of struct t. Fields of embedded structs
+// without a JSON name are promoted. Members that are never omitted and
+// cannot be null are required.
+func (s *schemaRegistry) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = s.schema(f.Type)
+		omitempty := strings.Contains(opts, "omitempty")
+		if !omitempty && f.Type.Kind() != reflect.Pointer && !strings.HasPrefix(f.Type.Name(), "PatchField[") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"net/http"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+)
+
+// apiOperations lists every route registered by the handlers. A test fails
+// when a route is registered without an entry here.
+var apiOperations = []apiOperation{
+	// inventory
+	{method: "POST", path: "/inventory", tag: "inventory", summary: "Create an inventory item",
+		request: dto.InventoryItemRequest{}, status: http.StatusCreated, response: messageBody{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/inventory", tag: "inventory", summary: "List inventory items",
+		params: paginationParams(dto.SortByID, dto.SortByName, dto.SortByQuantity, dto.SortByCreatedAt, dto.SortByUpdatedAt),
+		status: http.StatusOK, response: dto.PaginationResponse[entity.InventoryItem]{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/inventory/{id}", tag: "inventory", summary: "Get an inventory item with its ETag",
+		status: http.StatusOK, response: entity.InventoryItem{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "PUT", path: "/inventory/{id}", tag: "inventory", summary: "Replace an inventory item",
+		params: []apiParam{requiredIfMatchParam}, request: dto.InventoryItemRequest{},
+		status: http.StatusOK, response: messageBody{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired}},
+	{method: "PATCH", path: "/inventory/{id}", tag: "inventory", summary: "Merge patch an inventory item",
+		params: []apiParam{ifMatchParam}, request: dto.InventoryItemPatch{}, requestTypes: []string{mediaMergePatch},
+		status: http.StatusOK, response: messageBody{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType}},
+	{method: "DELETE", path: "/inventory/{id}", tag: "inventory",
+		summary: "Delete an inventory item; success is answered with 404 and a message",
+		status:  http.StatusNotFound, response: messageBody{},
+		errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: "POST", path: "/inventory/{id}/waste", tag: "inventory", summary: "Log wasted stock",
+		request: dto.WasteRequest{}, status: http.StatusCreated, response: dto.WasteResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "POST", path: "/inventory/{id}/batches", tag: "inventory", summary: "Receive a batch of stock",
+		request: dto.BatchRequest{}, status: http.StatusCreated, response: dto.BatchResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "GET", path: "/inventory/{id}/batches", tag: "inventory", summary: "List the batches of an inventory item",
+		status: http.StatusOK, response: []dto.BatchResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "GET", path: "/inventory/expiring", tag: "inventory", summary: "List batches expiring soon",
+		params: []apiParam{{name: "within", description: "Go duration or number of days such as 3d; 48h by default"}},
+		status: http.StatusOK, response: []dto.BatchResponse{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/inventory/getLeftOvers", tag: "inventory", summary: "List stock left over",
+		params: paginationParams(dto.SortByQuantity, dto.SortByPrice),
+		status: http.StatusOK, response: dto.PaginationResponse[dto.LeftOverItem]{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "POST", path: "/inventory/import", tag: "inventory", summary: "Create or update inventory items in bulk",
+		request: []dto.InventoryItemRequest{}, requestTypes: []string{mediaJSON, mediaCSV},
+		status: http.StatusOK, response: entity.ImportResult{},
+		errors: []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity}},
+	{method: "GET", path: "/inventory/export", tag: "inventory", summary: "Export every inventory item",
+		params: []apiParam{exportFormatParam}, status: http.StatusOK,
+		response: []dto.LeftOverItem{}, responseTypes: []string{mediaJSON, mediaCSV},
+		errors: []int{http.StatusBadRequest}},
+
+	// menu
+	{method: "POST", path: "/menu", tag: "menu", summary: "Create a menu item",
+		request: dto.MenuItemRequest{}, status: http.StatusCreated, response: dto.MenuItemResponse{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/menu", tag: "menu", summary: "List menu items",
+		params: paginationParams(dto.SortByID, dto.SortByName, dto.SortByPrice, dto.SortByCreatedAt, dto.SortByUpdatedAt),
+		status: http.StatusOK, response: dto.PaginationResponse[dto.MenuItemResponse]{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/menu/{id}", tag: "menu", summary: "Get a menu item with its ingredients and ETag",
+		status: http.StatusOK, response: dto.MenuItemDetailedResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "PUT", path: "/menu/{id}", tag: "menu", summary: "Replace a menu item",
+		params: []apiParam{requiredIfMatchParam}, request: dto.MenuItemRequest{},
+		status: http.StatusOK, response: messageBody{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired}},
+	{method: "PATCH", path: "/menu/{id}", tag: "menu", summary: "Merge patch a menu item",
+		params: []apiParam{ifMatchParam}, request: dto.MenuItemPatch{}, requestTypes: []string{mediaMergePatch},
+		status: http.StatusOK, response: messageBody{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType}},
+	{method: "DELETE", path: "/menu/{id}", tag: "menu", summary: "Delete a menu item",
+		status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "POST", path: "/menu/import", tag: "menu", summary: "Create or update menu items in bulk",
+		request: []dto.MenuImportRow{}, requestTypes: []string{mediaJSON, mediaCSV},
+		status: http.StatusOK, response: entity.ImportResult{},
+		errors: []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity}},
+	{method: "GET", path: "/menu/export", tag: "menu", summary: "Export every menu item",
+		params: []apiParam{exportFormatParam}, status: http.StatusOK,
+		response: []dto.MenuImportRow{}, responseTypes: []string{mediaJSON, mediaCSV},
+		errors: []int{http.StatusBadRequest}},
+
+	// orders
+	{method: "POST", path: "/orders", tag: "orders", summary: "Place an order",
+		request: dto.OrderRequest{}, status: http.StatusCreated, response: dto.OrderResponse{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/orders", tag: "orders", summary: "List orders",
+		params: paginationParams(dto.SortByID, dto.SortByName, dto.SortByPrice, dto.SortByCreatedAt, dto.SortByUpdatedAt),
+		status: http.StatusOK, response: dto.PaginationResponse[dto.OrderResponse]{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/orders/stream", tag: "orders",
+		summary: "Stream order events as Server-Sent Events, each data line an OrderEventResponse",
+		status:  http.StatusOK, response: dto.OrderEventResponse{}, responseTypes: []string{mediaEventStream}},
+	{method: "GET", path: "/kitchen/queue", tag: "orders", summary: "List the open orders the kitchen is working on",
+		status: http.StatusOK, response: []dto.KitchenTicketResponse{}},
+	{method: "GET", path: "/orders/{id}", tag: "orders", summary: "Get an order",
+		status: http.StatusOK, response: dto.OrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "PUT", path: "/orders/{id}", tag: "orders", summary: "Not implemented; answers 200 with an empty body",
+		status: http.StatusOK},
+	{method: "DELETE", path: "/orders/{id}", tag: "orders", summary: "Not implemented; answers 200 with an empty body",
+		status: http.StatusOK},
+	{method: "POST", path: "/orders/{id}/close", tag: "orders", summary: "Close an order",
+		status: http.StatusOK, response: dto.OrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "GET", path: "/orders/numberOfOrderedItems", tag: "orders",
+		summary: "Not implemented; answers 200 with an empty body, see /orders/numberOfOrderedItemsByPeriod",
+		status:  http.StatusOK},
+
+	// purchasing
+	{method: "POST", path: "/suppliers", tag: "purchasing", summary: "Create a supplier",
+		request: dto.SupplierRequest{}, status: http.StatusCreated, response: dto.SupplierResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: "GET", path: "/suppliers", tag: "purchasing", summary: "List suppliers",
+		status: http.StatusOK, response: []dto.SupplierResponse{}},
+	{method: "GET", path: "/suppliers/{id}", tag: "purchasing", summary: "Get a supplier",
+		status: http.StatusOK, response: dto.SupplierResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "PUT", path: "/suppliers/{id}", tag: "purchasing", summary: "Replace a supplier",
+		request: dto.SupplierRequest{}, status: http.StatusOK, response: dto.SupplierResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "DELETE", path: "/suppliers/{id}", tag: "purchasing", summary: "Delete a supplier",
+		status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "POST", path: "/purchase-orders", tag: "purchasing", summary: "Draft a purchase order",
+		request: dto.PurchaseOrderRequest{}, status: http.StatusCreated, response: dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "GET", path: "/purchase-orders", tag: "purchasing", summary: "List purchase orders",
+		params: []apiParam{{name: "status", enum: entity.PurchaseOrderStatuses}},
+		status: http.StatusOK, response: []dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/purchase-orders/{id}", tag: "purchasing", summary: "Get a purchase order",
+		status: http.StatusOK, response: dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "PUT", path: "/purchase-orders/{id}", tag: "purchasing", summary: "Replace a draft purchase order",
+		request: dto.PurchaseOrderRequest{}, status: http.StatusOK, response: dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "DELETE", path: "/purchase-orders/{id}", tag: "purchasing", summary: "Delete a draft purchase order",
+		status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "POST", path: "/purchase-orders/{id}/send", tag: "purchasing", summary: "Send a purchase order to its supplier",
+		status: http.StatusOK, response: dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{method: "POST", path: "/purchase-orders/{id}/receive", tag: "purchasing",
+		summary: "Receive a purchase order; an empty body receives everything outstanding",
+		request: dto.ReceiveRequest{}, status: http.StatusOK, response: dto.PurchaseOrderResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+
+	// reports
+	{method: "GET", path: "/reports/total-sales", tag: "reports", summary: "Total sales",
+		params: reportParams, status: http.StatusOK,
+		response: entity.SalesReport{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/popular-items", tag: "reports", summary: "Best selling menu items",
+		params: reportParams, status: http.StatusOK,
+		response: entity.PopularItemsReport{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/inventory-forecast", tag: "reports", summary: "Forecast inventory use and suggest reorders",
+		params: []apiParam{
+			{name: "as_of", description: "RFC 3339 timestamp or date"},
+			{name: "window_days", typ: "integer", description: "days of history to forecast from, 30 by default"},
+			{name: "cover_days", typ: "integer", description: "days of stock to reorder for, 14 by default"},
+			{name: "source", enum: []string{entity.ForecastSourceAuto, entity.ForecastSourceLedger, entity.ForecastSourceOrders}},
+			reportFormatParam,
+		},
+		status: http.StatusOK, response: entity.InventoryForecast{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/inventory-valuation", tag: "reports", summary: "Value inventory",
+		params: []apiParam{{name: "as_of", description: "RFC 3339 timestamp or date"}, valuationMethodParam, reportFormatParam},
+		status: http.StatusOK, response: entity.InventoryValuation{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/cogs", tag: "reports", summary: "Cost of goods sold and gross margin",
+		params: append([]apiParam{valuationMethodParam}, reportParams...), status: http.StatusOK,
+		response: entity.COGSReport{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/waste", tag: "reports", summary: "Wasted stock by item, reason and staff member",
+		params: append([]apiParam{valuationMethodParam}, reportParams...), status: http.StatusOK,
+		response: entity.WasteReport{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/reports/orderedItemsByPeriod", tag: "reports", summary: "Ordered items per day or month",
+		params: []apiParam{
+			{name: "period", required: true, enum: []string{"day", "month"}},
+			{name: "month", description: "month name, with period=day"},
+			{name: "year", typ: "integer"},
+			{name: "breakdown", enum: []string{entity.BreakdownMenuItem}},
+			reportFormatParam,
+		},
+		status: http.StatusOK, response: entity.TotalItemsByPeriod{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/orders/numberOfOrderedItemsByPeriod", tag: "reports", summary: "Ordered items per menu item",
+		params: []apiParam{
+			{name: "startDate", description: "YYYY-MM-DD"},
+			{name: "endDate", description: "YYYY-MM-DD"},
+			reportFormatParam,
+		},
+		status: http.StatusOK, response: entity.NumberOfOrderedItemsByPeriod{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+
+	// search
+	{method: "GET", path: "/reports/search", tag: "search", summary: "Search menu items, orders, inventory and customers",
+		params: append([]apiParam{
+			{name: "q", required: true},
+			{name: "filter", description: "comma separated set of menu, orders, inventory and customers; all by default"},
+			{name: "minPrice", typ: "number"},
+			{name: "maxPrice", typ: "number"},
+			{name: "from", description: "RFC 3339 timestamp or date; orders and customers only"},
+			{name: "to", description: "RFC 3339 timestamp or date, inclusive; orders and customers only"},
+			{name: "status", description: "comma separated order statuses; orders and customers only"},
+			{name: "page", typ: "integer", description: "ignored by CSV and XLSX exports, which list every match"},
+			{name: "pageSize", typ: "integer", description: "ignored by CSV and XLSX exports"},
+		}, reportFormatParam),
+		status: http.StatusOK, response: entity.SearchResult{}, responseTypes: reportMediaTypes,
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/search/suggest", tag: "search", summary: "Autocomplete menu item and customer names",
+		params: []apiParam{{name: "q", required: true}, {name: "limit", typ: "integer", description: "1 to 50, 10 by default"}},
+		status: http.StatusOK, response: []entity.SearchSuggestion{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "POST", path: "/search/reindex", tag: "search", summary: "Recompute the search index of every order",
+		status: http.StatusOK, response: map[string]int64{}},
+
+	// webhooks
+	{method: "POST", path: "/webhooks", tag: "webhooks", summary: "Subscribe to events",
+		request: dto.WebhookSubscriptionRequest{}, status: http.StatusCreated, response: dto.WebhookSubscriptionResponse{},
+		errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/webhooks", tag: "webhooks", summary: "List subscriptions",
+		status: http.StatusOK, response: []dto.WebhookSubscriptionResponse{}},
+	{method: "GET", path: "/webhooks/{id}", tag: "webhooks", summary: "Get a subscription",
+		status: http.StatusOK, response: dto.WebhookSubscriptionResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "DELETE", path: "/webhooks/{id}", tag: "webhooks", summary: "Delete a subscription",
+		status: http.StatusNoContent, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "GET", path: "/webhooks/{id}/deliveries", tag: "webhooks", summary: "List the deliveries of a subscription",
+		status: http.StatusOK, response: []dto.WebhookDeliveryResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	{method: "GET", path: "/openapi.json", tag: "meta", summary: "This document",
+		status: http.StatusOK, response: map[string]any{}},
+}
+
+var (
+	ifMatchParam = apiParam{name: "If-Match", in: "header",
+		description: "ETag from GET; the update fails with 412 when the item changed since"}
+	requiredIfMatchParam = apiParam{name: "If-Match", in: "header", required: true,
+		description: "ETag from GET; 428 without it, 412 when the item changed since"}
+	exportFormatParam = apiParam{name: "format", enum: []string{dto.FormatJSON, dto.FormatCSV},
+		description: "overrides the Accept header"}
+	reportFormatParam = apiParam{name: "format", enum: []string{dto.FormatJSON, dto.FormatCSV, dto.FormatXLSX},
+		description: "overrides the Accept header"}
+	valuationMethodParam = apiParam{name: "method", enum: []string{entity.ValuationFIFO, entity.ValuationWeightedAverage},
+		description: "the configured inventory valuation by default"}
+
+	reportParams = []apiParam{
+		{name: "from", description: "RFC 3339 timestamp or date; required with group_by"},
+		{name: "to", description: "RFC 3339 timestamp or date, inclusive; now by default"},
+		{name: "group_by", enum: []string{entity.GroupByHour, entity.GroupByDay, entity.GroupByWeek, entity.GroupByMonth}},
+		{name: "breakdown", description: "comma separated set of payment_method, category and staff"},
+		{name: "limit", typ: "integer", description: "rows per breakdown, 10 by default and at most 100"},
+		reportFormatParam,
+	}
+	reportMediaTypes = []string{mediaJSON, mediaCSV, xlsxContentType}
+)
+
+// paginationParams are the query parameters of a paginated list sortable
+// by sorts.
+func paginationParams(sorts ...dto.SortOption) []apiParam {
+	enum := make([]string, len(sorts))
+	for i, s := range sorts {
+		enum[i] = string(s)
+	}
+	return []apiParam{
+		{name: "page", typ: "integer", description: "1 by default"},
+		{name: "pageSize", typ: "integer", description: "10 by default"},
+		{name: "sortBy", enum: enum},
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// registeredRoutes reads the patterns handed to HandleFunc in this package,
+// without their trailing slash variants.
+func registeredRoutes(t *testing.T) map[string]bool {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("parse handlers: %v", err)
+	}
+
+	routes := make(map[string]bool)
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "HandleFunc" && sel.Sel.Name != "Handle") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			pattern, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatalf("%s: %v", fset.Position(lit.Pos()), err)
+			}
+			if len(pattern) > 1 && strings.HasSuffix(pattern, "/") {
+				pattern = strings.TrimSuffix(pattern, "/")
+			}
+			routes[pattern] = true
+			return true
+		})
+	}
+	if len(routes) == 0 {
+		t.Fatal("found no registered routes")
+	}
+	return routes
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	h, err := NewOpenAPIHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	h.RegisterEndpoints(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+
+	documented := make(map[string]bool)
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := registeredRoutes(t)
+	var missing, stale []string
+	for route := range registered {
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	for _, route := range missing {
+		t.Errorf("%s is registered but missing from the OpenAPI document", route)
+	}
+	for _, route := range stale {
+		t.Errorf("%s is in the OpenAPI document but not registered", route)
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	doc := buildOpenAPI(apiOperations)
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	const prefix = `"#/components/schemas/`
+	for rest := string(b); ; {
+		i := strings.Index(rest, prefix)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(prefix):]
+		name := rest[:strings.IndexByte(rest, '"')]
+		if schemas[name] == nil {
+			t.Errorf("reference to undefined schema %s", name)
+		}
+	}
+}
+
+func TestRoutesDoNotConflict(t *testing.T) {
+	// ServeMux panics on patterns that overlap without one being more
+	// specific, so the server would not start
+	defer func() {
+		if p := recover(); p != nil {
+			t.Fatal(p)
+		}
+	}()
+	mux := http.NewServeMux()
+	NewInventoryHandler(nil, nil).RegisterEndpoints(mux)
+	NewMenuHandler(nil, nil).RegisterEndpoints(mux)
+	NewOrderHandler(nil, nil, nil).RegisterEndpoints(mux)
+	NewReportHandler(nil, nil).RegisterEndpoints(mux)
+	NewWebhookHandler(nil, nil).RegisterEndpoints(mux)
+	NewSupplierHandler(nil, nil).RegisterEndpoints(mux)
+	NewPurchaseOrderHandler(nil, nil).RegisterEndpoints(mux)
+	h, err := NewOpenAPIHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.RegisterEndpoints(mux)
+}
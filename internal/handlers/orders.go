@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
 	"frappuccino-alem/internal/utils"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type OrderService interface {
@@ -18,15 +23,24 @@ type OrderService interface {
 	DeleteOrderById(ctx context.Context, OrderId string) error
 	CloseOrderById(ctx context.Context, OrderId string) error
 	GetNumberOfOrderedItems(ctx context.Context, startDate, endDate string) (map[string]int, error)
+	GetKitchenQueue(ctx context.Context) ([]entity.Order, error)
 }
 
+type OrderEventSubscriber interface {
+	Subscribe() (<-chan entity.OrderEvent, func())
+}
+
+// streamHeartbeat keeps idle event streams alive through proxies.
+const streamHeartbeat = 15 * time.Second
+
 type OrderHandler struct {
 	service OrderService
+	events  OrderEventSubscriber
 	logger  *slog.Logger
 }
 
-func NewOrderHandler(service OrderService, logger *slog.Logger) *OrderHandler {
-	return &OrderHandler{service, logger}
+func NewOrderHandler(service OrderService, events OrderEventSubscriber, logger *slog.Logger) *OrderHandler {
+	return &OrderHandler{service, events, logger}
 }
 
 func (h *OrderHandler) RegisterEndpoints(mux *http.ServeMux) {
@@ -36,6 +50,12 @@ func (h *OrderHandler) RegisterEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("GET /orders", h.getPaginatedOrders)
 	mux.HandleFunc("GET /orders/", h.getPaginatedOrders)
 
+	mux.HandleFunc("GET /orders/stream", h.streamOrders)
+	mux.HandleFunc("GET /orders/stream/", h.streamOrders)
+
+	mux.HandleFunc("GET /kitchen/queue", h.getKitchenQueue)
+	mux.HandleFunc("GET /kitchen/queue/", h.getKitchenQueue)
+
 	mux.HandleFunc("GET /orders/{id}", h.getOrderById)
 	mux.HandleFunc("GET /orders/{id}/", h.getOrderById)
 
@@ -56,17 +76,21 @@ func (h *OrderHandler) createOrder(w http.ResponseWriter, r *http.Request) {
 	var req dto.OrderRequest
 	if err := utils.ParseJSON(r, &req); err != nil {
 		h.logger.Error("Failed to parse order request", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid request payload"))
+		writeParseError(w, err)
 		return
 	}
 	if err := req.Validate(); err != nil {
 		h.logger.Error("Invalid order request", "error", err.Error())
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid request payload: %v", err))
+		writeValidationError(w, err)
 		return
 	}
 
 	entityItem := req.MapToEntity()
 	item, err := h.service.CreateOrder(r.Context(), entityItem)
+	if errors.Is(err, store.ErrInvalidInput) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to create menu item", "error", err.Error())
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to create menu item: %v", err))
@@ -92,7 +116,7 @@ func (h *OrderHandler) getPaginatedOrders(w http.ResponseWriter, r *http.Request
 
 	paginatedData, err := h.service.GetPaginatedOrders(r.Context(), pagination)
 	if err != nil {
-		h.logger.Error("Failed to get orders", err)
+		h.logger.Error("Failed to get orders", "error", err.Error())
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -111,6 +135,23 @@ func (h *OrderHandler) getPaginatedOrders(w http.ResponseWriter, r *http.Request
 }
 
 func (h *OrderHandler) getOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := h.service.GetOrderById(r.Context(), strconv.FormatInt(id, 10))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with ID %d not found", id))
+			return
+		}
+		h.logger.Error("Failed to get order", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.OrderToResponse(order))
 }
 
 func (h *OrderHandler) updateOrderById(w http.ResponseWriter, r *http.Request) {
@@ -120,7 +161,101 @@ func (h *OrderHandler) deleteOrderById(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *OrderHandler) closeOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	orderID := strconv.FormatInt(id, 10)
+
+	if err := h.service.CloseOrderById(r.Context(), orderID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with ID %d not found", id))
+		case errors.Is(err, store.ErrConflict):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			h.logger.Error("Failed to close order", "error", err.Error())
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	order, err := h.service.GetOrderById(r.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get closed order", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.OrderToResponse(order))
 }
 
 func (h *OrderHandler) getNumberOfOrderedItems(w http.ResponseWriter, r *http.Request) {
 }
+
+func (h *OrderHandler) getKitchenQueue(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.service.GetKitchenQueue(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get kitchen queue", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := time.Now()
+	tickets := make([]dto.KitchenTicketResponse, 0, len(orders))
+	for _, order := range orders {
+		tickets = append(tickets, dto.OrderToKitchenTicket(order, now))
+	}
+	utils.WriteJSON(w, http.StatusOK, tickets)
+}
+
+// streamOrders pushes order events as Server-Sent Events until the client
+// disconnects.
+func (h *OrderHandler) streamOrders(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// the server write timeout would otherwise cut every stream short
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Error("Failed to clear write deadline for order stream", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-events:
+			if !ok {
+				// the stream stopped, either for shutdown or to be restarted;
+				// the client reconnects after the retry delay
+				return
+			}
+			data, err := json.Marshal(dto.OrderEventToResponse(event))
+			if err != nil {
+				h.logger.Error("Failed to encode order event", "error", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
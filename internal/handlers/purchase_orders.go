@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+type PurchaseOrderService interface {
+	CreatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error)
+	GetPurchaseOrders(ctx context.Context, status string) ([]entity.PurchaseOrder, error)
+	GetPurchaseOrderById(ctx context.Context, id int64) (entity.PurchaseOrder, error)
+	UpdatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error)
+	DeletePurchaseOrder(ctx context.Context, id int64) error
+	SendPurchaseOrder(ctx context.Context, id int64) (entity.PurchaseOrder, error)
+	ReceivePurchaseOrder(ctx context.Context, id int64, receipts []entity.PurchaseOrderReceipt) (entity.PurchaseOrder, error)
+}
+
+type PurchaseOrderHandler struct {
+	service PurchaseOrderService
+	logger  *slog.Logger
+}
+
+func NewPurchaseOrderHandler(service PurchaseOrderService, logger *slog.Logger) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{service, logger}
+}
+
+func (h *PurchaseOrderHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /purchase-orders", h.createPurchaseOrder)
+	mux.HandleFunc("POST /purchase-orders/", h.createPurchaseOrder)
+
+	mux.HandleFunc("GET /purchase-orders", h.getPurchaseOrders)
+	mux.HandleFunc("GET /purchase-orders/", h.getPurchaseOrders)
+
+	mux.HandleFunc("GET /purchase-orders/{id}", h.getPurchaseOrderById)
+	mux.HandleFunc("GET /purchase-orders/{id}/", h.getPurchaseOrderById)
+
+	mux.HandleFunc("PUT /purchase-orders/{id}", h.updatePurchaseOrder)
+	mux.HandleFunc("PUT /purchase-orders/{id}/", h.updatePurchaseOrder)
+
+	mux.HandleFunc("DELETE /purchase-orders/{id}", h.deletePurchaseOrder)
+	mux.HandleFunc("DELETE /purchase-orders/{id}/", h.deletePurchaseOrder)
+
+	mux.HandleFunc("POST /purchase-orders/{id}/send", h.sendPurchaseOrder)
+	mux.HandleFunc("POST /purchase-orders/{id}/send/", h.sendPurchaseOrder)
+
+	mux.HandleFunc("POST /purchase-orders/{id}/receive", h.receivePurchaseOrder)
+	mux.HandleFunc("POST /purchase-orders/{id}/receive/", h.receivePurchaseOrder)
+}
+
+func (h *PurchaseOrderHandler) createPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var req dto.PurchaseOrderRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse purchase order request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	po, err := h.service.CreatePurchaseOrder(r.Context(), req.MapToEntity())
+	if err != nil {
+		h.handleError(w, 0, err)
+		return
+	}
+	h.logger.Info("Created purchase order", slog.Int64("id", po.ID), slog.Int64("supplier_id", po.SupplierID))
+	utils.WriteJSON(w, http.StatusCreated, dto.PurchaseOrderToResponse(po))
+}
+
+// getPurchaseOrders lists purchase orders, optionally filtered by ?status=.
+func (h *PurchaseOrderHandler) getPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !entity.IsValidPurchaseOrderStatus(status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid status %q, must be one of: %s",
+			status, strings.Join(entity.PurchaseOrderStatuses, ", ")))
+		return
+	}
+
+	orders, err := h.service.GetPurchaseOrders(r.Context(), status)
+	if err != nil {
+		h.handleError(w, 0, err)
+		return
+	}
+
+	response := make([]dto.PurchaseOrderResponse, 0, len(orders))
+	for _, po := range orders {
+		response = append(response, dto.PurchaseOrderToResponse(po))
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *PurchaseOrderHandler) getPurchaseOrderById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	po, err := h.service.GetPurchaseOrderById(r.Context(), id)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.PurchaseOrderToResponse(po))
+}
+
+func (h *PurchaseOrderHandler) updatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.PurchaseOrderRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse purchase order request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if req.SupplierID == nil {
+		// the supplier of an existing order is fixed, so it may be left out
+		req.SupplierID = new(int64)
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	po := req.MapToEntity()
+	po.ID = id
+	po, err = h.service.UpdatePurchaseOrder(r.Context(), po)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.PurchaseOrderToResponse(po))
+}
+
+func (h *PurchaseOrderHandler) deletePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.DeletePurchaseOrder(r.Context(), id); err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PurchaseOrderHandler) sendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	po, err := h.service.SendPurchaseOrder(r.Context(), id)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	h.logger.Info("Sent purchase order", slog.Int64("id", id))
+	utils.WriteJSON(w, http.StatusOK, dto.PurchaseOrderToResponse(po))
+}
+
+// receivePurchaseOrder books a delivery. The body is optional: without one
+// everything outstanding is received.
+func (h *PurchaseOrderHandler) receivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.ReceiveRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			h.logger.Error("Failed to parse receive request", "error", err.Error())
+			writeParseError(w, err)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	po, err := h.service.ReceivePurchaseOrder(r.Context(), id, req.MapToEntity())
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	h.logger.Info("Received purchase order", slog.Int64("id", id), slog.String("status", po.Status))
+	utils.WriteJSON(w, http.StatusOK, dto.PurchaseOrderToResponse(po))
+}
+
+func (h *PurchaseOrderHandler) handleError(w http.ResponseWriter, id int64, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("purchase order with ID %d not found", id))
+	case errors.Is(err, store.ErrInvalidInput):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, store.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		h.logger.Error("Failed to process purchase order", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to process purchase order"))
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/pkg/lib/xlsx"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// reportFormat picks the format of a report from ?format= or, failing that,
+// the Accept header. JSON is the default.
+func reportFormat(r *http.Request) (string, error) {
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case dto.FormatJSON, dto.FormatCSV, dto.FormatXLSX:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported format %q: must be json, csv or xlsx", format)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		switch strings.TrimSpace(strings.ToLower(mediaType)) {
+		case "application/json":
+			return dto.FormatJSON, nil
+		case "text/csv":
+			return dto.FormatCSV, nil
+		case xlsxContentType:
+			return dto.FormatXLSX, nil
+		}
+	}
+	return dto.FormatJSON, nil
+}
+
+// tableWriter receives the rows of a CSV or XLSX report.
+type tableWriter interface {
+	WriteRow(values []any) error
+	Close() error
+}
+
+// reportTable sends the response headers and the header row only once the
+// first row is written, so a report that fails before producing anything can
+// still be answered with a proper error.
+type reportTable struct {
+	w      http.ResponseWriter
+	format string
+	name   string
+	header []string
+	out    tableWriter
+}
+
+func newReportTable(w http.ResponseWriter, format, name string, header ...string) *reportTable {
+	return &reportTable{w: w, format: format, name: name, header: header}
+}
+
+func (t *reportTable) started() bool {
+	return t.out != nil
+}
+
+func (t *reportTable) start() error {
+	startExport(t.w, t.format, t.name)
+	if t.format == dto.FormatXLSX {
+		xw, err := xlsx.NewWriter(t.w, t.name)
+		if err != nil {
+			return err
+		}
+		t.out = xw
+	} else {
+		t.out = &csvTable{csv.NewWriter(t.w)}
+	}
+
+	header := make([]any, len(t.header))
+	for i, name := range t.header {
+		header[i] = name
+	}
+	return t.out.WriteRow(header)
+}
+
+func (t *reportTable) WriteRow(values []any) error {
+	if t.out == nil {
+		if err := t.start(); err != nil {
+			return err
+		}
+	}
+	return t.out.WriteRow(values)
+}
+
+func (t *reportTable) Close() error {
+	if t.out == nil {
+		if err := t.start(); err != nil {
+			return err
+		}
+	}
+	return t.out.Close()
+}
+
+type csvTable struct {
+	cw *csv.Writer
+}
+
+func (t *csvTable) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			record[i] = v
+		case int:
+			record[i] = strconv.Itoa(v)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.Format(time.RFC3339)
+			}
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return t.cw.Write(record)
+}
+
+func (t *csvTable) Close() error {
+	t.cw.Flush()
+	return t.cw.Error()
+}
+
+// finishReportExport closes a table once its rows are written. Errors that
+// happen before the first row get a normal error response; later ones can
+// only be logged, leaving the client with a truncated file.
+func (h *ReportHandler) finishReportExport(w http.ResponseWriter, table *reportTable, report string, err error) {
+	if err != nil && !table.started() {
+		h.writeReportError(w, report, err)
+		return
+	}
+	if err == nil {
+		err = table.Close()
+	}
+	if err != nil {
+		h.logger.Error("failed to export "+report, "error", err.Error())
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
 	"frappuccino-alem/internal/utils"
 	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,11 +17,24 @@ import (
 )
 
 type ReportService interface {
-	GetTotalSales(ctx context.Context) (float64, error)
-	GetPopularItems(ctx context.Context) ([]entity.PopularItem, error)
-	GetFilterSearch(ctx context.Context, search string, filter string, minPrice float64, maxPrice float64) (entity.SearchResult, error)
-	GetTotalItemsByPeriod(ctx context.Context, period string, month int, year int) (entity.TotalItemsByPeriod, error)
+	GetTotalSales(ctx context.Context, q entity.ReportQuery) (entity.SalesReport, error)
+	GetPopularItems(ctx context.Context, q entity.ReportQuery) (entity.PopularItemsReport, error)
+	GetFilterSearch(ctx context.Context, query entity.SearchQuery) (entity.SearchResult, error)
+	Suggest(ctx context.Context, text string, limit int) ([]entity.SearchSuggestion, error)
+	ReindexSearch(ctx context.Context) (int64, error)
+	GetTotalItemsByPeriod(ctx context.Context, period string, month int, year int, byMenuItem bool) (entity.TotalItemsByPeriod, error)
 	GetOrderedItemsReport(ctx context.Context, startDate time.Time, endDate time.Time) (entity.NumberOfOrderedItemsByPeriod, error)
+
+	GetInventoryForecast(ctx context.Context, asOf time.Time, windowDays, coverDays int, source string) (entity.InventoryForecast, error)
+	GetInventoryValuation(ctx context.Context, asOf time.Time, method string) (entity.InventoryValuation, error)
+	GetCOGS(ctx context.Context, q entity.ReportQuery, method string) (entity.COGSReport, error)
+	GetWasteReport(ctx context.Context, q entity.ReportQuery, method string) (entity.WasteReport, error)
+
+	ExportTotalSales(ctx context.Context, q entity.ReportQuery, fn func(breakdown string, row entity.SalesRow) error) error
+	ExportPopularItems(ctx context.Context, q entity.ReportQuery, fn func(breakdown string, rank int, row entity.PopularItemRow) error) error
+	ExportTotalItemsByPeriod(ctx context.Context, period string, month int, year int, byMenuItem bool, fn func(row entity.ItemsRow, label string) error) error
+	ExportFilterSearch(ctx context.Context, query entity.SearchQuery, fn func(hit entity.SearchHit) error) error
+	ExportOrderedItems(ctx context.Context, startDate, endDate time.Time, fn func(name string, quantity int) error) error
 }
 
 type ReportHandler struct {
@@ -37,87 +53,458 @@ func (h *ReportHandler) RegisterEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("GET /reports/popular-items", h.GetPopularItems)
 	mux.HandleFunc("GET /reports/popular-items/", h.GetPopularItems)
 
+	mux.HandleFunc("GET /reports/inventory-forecast", h.GetInventoryForecast)
+	mux.HandleFunc("GET /reports/inventory-forecast/", h.GetInventoryForecast)
+
+	mux.HandleFunc("GET /reports/inventory-valuation", h.GetInventoryValuation)
+	mux.HandleFunc("GET /reports/inventory-valuation/", h.GetInventoryValuation)
+
+	mux.HandleFunc("GET /reports/cogs", h.GetCOGS)
+	mux.HandleFunc("GET /reports/cogs/", h.GetCOGS)
+
+	mux.HandleFunc("GET /reports/waste", h.GetWasteReport)
+	mux.HandleFunc("GET /reports/waste/", h.GetWasteReport)
+
 	mux.HandleFunc("GET /reports/search", h.GetFilterSearch)
+	mux.HandleFunc("GET /search/suggest", h.GetSearchSuggestions)
+	mux.HandleFunc("GET /search/suggest/", h.GetSearchSuggestions)
+	mux.HandleFunc("POST /search/reindex", h.ReindexSearch)
+	mux.HandleFunc("POST /search/reindex/", h.ReindexSearch)
 	mux.HandleFunc("GET /reports/orderedItemsByPeriod", h.GetTotalItemsByPeriod)
 
 	mux.HandleFunc("GET /orders/numberOfOrderedItemsByPeriod", h.GetNumberOfOrderedItems)
 	mux.HandleFunc("GET /orders/numberOfOrderedItemsByPeriod/", h.GetNumberOfOrderedItems)
 }
 
+// Every report answers in JSON, CSV or XLSX, chosen by ?format= or the
+// Accept header. CSV and XLSX are flat tables streamed as they are read.
+
 func (h *ReportHandler) GetPopularItems(w http.ResponseWriter, r *http.Request) {
-	popularItems, err := h.service.GetPopularItems(r.Context())
+	format, q, err := parseReportRequest(w, r)
 	if err != nil {
-		h.logger.Error("failed to get popular items", "error", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if popularItems == nil {
-		h.logger.Info("no popular items found")
-		http.Error(w, "No popular items found", http.StatusNotFound)
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "popular-items",
+			"period_start", "breakdown", "key", "label", "rank",
+			"product_id", "product_name", "total_quantity", "revenue")
+		err := h.service.ExportPopularItems(r.Context(), q, func(breakdown string, rank int, row entity.PopularItemRow) error {
+			return table.WriteRow([]any{
+				row.Bucket, breakdown, row.Key, row.Label, rank,
+				row.Item.ProductId, row.Item.ProductName, row.Item.Sold, row.Item.Revenue,
+			})
+		})
+		h.finishReportExport(w, table, "popular items", err)
+		return
+	}
+
+	report, err := h.service.GetPopularItems(r.Context(), q)
+	if err != nil {
+		h.writeReportError(w, "popular items", err)
+		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, popularItems)
+	utils.WriteJSON(w, http.StatusOK, report)
 }
 
 func (h *ReportHandler) GetTotalSales(w http.ResponseWriter, r *http.Request) {
-	totalSales, err := h.service.GetTotalSales(r.Context())
+	format, q, err := parseReportRequest(w, r)
 	if err != nil {
-		h.logger.Error("failed to get total sales", "error", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]float64{
-		"total_sales": totalSales})
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "total-sales",
+			"period_start", "breakdown", "key", "label", "total_sales", "order_count")
+		err := h.service.ExportTotalSales(r.Context(), q, func(breakdown string, row entity.SalesRow) error {
+			return table.WriteRow([]any{row.Bucket, breakdown, row.Key, row.Label, row.Revenue, row.OrderCount})
+		})
+		h.finishReportExport(w, table, "total sales", err)
+		return
+	}
+
+	report, err := h.service.GetTotalSales(r.Context(), q)
+	if err != nil {
+		h.writeReportError(w, "total sales", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
 }
 
-func (h *ReportHandler) GetFilterSearch(w http.ResponseWriter, r *http.Request) {
-	url := r.URL.Query()
+// GetInventoryForecast projects stockouts from usage over the window_days
+// (default 30) before as_of (default now) and suggests reorders covering
+// cover_days (default 14). source picks the usage data: ledger, orders or
+// auto, which prefers the ledger.
+func (h *ReportHandler) GetInventoryForecast(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	querystring := url.Get("q")
-	filter := url.Get("filter")
-	minPriceStr := url.Get("minPrice")
-	maxPriceStr := url.Get("maxPrice")
+	q := r.URL.Query()
+	asOf := time.Now().UTC()
+	if s := q.Get("as_of"); s != "" {
+		if asOf, err = parseReportTime(s, true); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid as_of: %w", err))
+			return
+		}
+	}
+	windowDays, err := parseDaysParam(q.Get("window_days"), 30, 1)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid window_days: %w", err))
+		return
+	}
+	coverDays, err := parseDaysParam(q.Get("cover_days"), 14, 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid cover_days: %w", err))
+		return
+	}
+	source := q.Get("source")
+	switch source {
+	case "":
+		source = entity.ForecastSourceAuto
+	case entity.ForecastSourceAuto, entity.ForecastSourceLedger, entity.ForecastSourceOrders:
+	default:
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid source %q: must be auto, ledger or orders", source))
+		return
+	}
 
-	if querystring == "" {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("query parameter is required"))
+	forecast, err := h.service.GetInventoryForecast(r.Context(), asOf, windowDays, coverDays, source)
+	if err != nil {
+		h.writeReportError(w, "inventory forecast", err)
 		return
 	}
 
-	if minPriceStr == "" {
-		minPriceStr = "0"
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "inventory-forecast",
+			"inventory_id", "name", "unit", "quantity", "reorder_level", "usage_source", "usage",
+			"avg_daily_usage", "days_until_stockout", "stockout_date", "suggested_reorder_quantity", "estimated_cost")
+		for _, item := range forecast.Items {
+			var days, date any
+			if item.DaysUntilStockout != nil {
+				days, date = *item.DaysUntilStockout, *item.StockoutDate
+			}
+			err = table.WriteRow([]any{
+				item.InventoryID, item.Name, item.Unit, item.Quantity, item.ReorderLevel, item.UsageSource, item.Usage,
+				item.AvgDailyUsage, days, date, item.SuggestedReorder, item.EstimatedCost,
+			})
+			if err != nil {
+				break
+			}
+		}
+		h.finishReportExport(w, table, "inventory forecast", err)
+		return
 	}
-	if maxPriceStr == "" {
-		maxPriceStr = "0"
+
+	utils.WriteJSON(w, http.StatusOK, forecast)
+}
+
+// GetInventoryValuation values stock at as_of (default now) by replaying
+// the inventory ledger. method is fifo or weighted_average and defaults to
+// the configured one.
+func (h *ReportHandler) GetInventoryValuation(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
 	}
 
-	minPriceFloat, err := strconv.ParseFloat(minPriceStr, 64)
+	q := r.URL.Query()
+	asOf := time.Now().UTC()
+	if s := q.Get("as_of"); s != "" {
+		if asOf, err = parseReportTime(s, true); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid as_of: %w", err))
+			return
+		}
+	}
+	method, err := parseValuationMethod(q.Get("method"))
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid minPrice parameter"))
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	maxPriceFloat, err := strconv.ParseFloat(maxPriceStr, 64)
+	valuation, err := h.service.GetInventoryValuation(r.Context(), asOf, method)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid maxPrice parameter"))
+		h.writeReportError(w, "inventory valuation", err)
 		return
 	}
 
-	if minPriceFloat < 0 {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("minPrice cannot be negative"))
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "inventory-valuation",
+			"inventory_id", "name", "unit", "quantity", "unit_cost", "value")
+		for _, item := range valuation.Items {
+			err = table.WriteRow([]any{item.InventoryID, item.Name, item.Unit, item.Quantity, item.UnitCost, item.Value})
+			if err != nil {
+				break
+			}
+		}
+		h.finishReportExport(w, table, "inventory valuation", err)
 		return
 	}
-	if maxPriceFloat < 0 {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("maxPrice cannot be negative"))
+
+	utils.WriteJSON(w, http.StatusOK, valuation)
+}
+
+// GetCOGS reports the cost of goods sold between from and to next to the
+// revenue of the same range, optionally per group_by period. Exports have a
+// row per period, or a single row when the report is not grouped.
+func (h *ReportHandler) GetCOGS(w http.ResponseWriter, r *http.Request) {
+	format, q, err := parseReportRequest(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
-	if minPriceFloat > maxPriceFloat {
-		utils.WriteError(w, http.StatusBadRequest, errors.New("minPrice cannot be greater than maxPrice"))
+	if len(q.Breakdowns) > 0 {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("breakdown is not supported by the cogs report"))
+		return
+	}
+	method, err := parseValuationMethod(r.URL.Query().Get("method"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	data, err := h.service.GetFilterSearch(r.Context(), querystring, filter, minPriceFloat, maxPriceFloat)
+	report, err := h.service.GetCOGS(r.Context(), q, method)
+	if err != nil {
+		h.writeReportError(w, "cogs", err)
+		return
+	}
+
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "cogs", "period_start", "revenue", "cogs", "gross_profit")
+		if len(report.Buckets) == 0 {
+			var start any
+			if report.From != nil {
+				start = *report.From
+			}
+			err = table.WriteRow([]any{start, report.Revenue, report.COGS, report.GrossProfit})
+		}
+		for _, b := range report.Buckets {
+			if err = table.WriteRow([]any{b.PeriodStart, b.Revenue, b.COGS, b.GrossProfit}); err != nil {
+				break
+			}
+		}
+		h.finishReportExport(w, table, "cogs", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// GetWasteReport sums the waste logged between from and to per item, reason
+// and staff member. Exports have a row per item and reason.
+func (h *ReportHandler) GetWasteReport(w http.ResponseWriter, r *http.Request) {
+	format, q, err := parseReportRequest(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if q.GroupBy != "" || len(q.Breakdowns) > 0 {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("group_by and breakdown are not supported by the waste report"))
+		return
+	}
+	method, err := parseValuationMethod(r.URL.Query().Get("method"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.service.GetWasteReport(r.Context(), q, method)
+	if err != nil {
+		h.writeReportError(w, "waste", err)
+		return
+	}
+
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "waste",
+			"inventory_id", "name", "unit", "reason", "quantity", "cost", "entry_count")
+	rows:
+		for _, item := range report.Items {
+			for _, reason := range item.Reasons {
+				err = table.WriteRow([]any{
+					item.InventoryID, item.Name, item.Unit, reason.Reason, reason.Quantity, reason.Cost, reason.EntryCount,
+				})
+				if err != nil {
+					break rows
+				}
+			}
+		}
+		h.finishReportExport(w, table, "waste", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// parseValuationMethod accepts an empty method, which leaves the choice to
+// the configuration.
+func parseValuationMethod(s string) (string, error) {
+	if s != "" && !entity.IsValidValuationMethod(s) {
+		return "", fmt.Errorf("invalid method %q: must be fifo or weighted_average", s)
+	}
+	return s, nil
+}
+
+// parseDaysParam reads a number of days between min and 365, returning def
+// when s is empty.
+func parseDaysParam(s string, def, min int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > 365 {
+		return 0, fmt.Errorf("must be a whole number of days between %d and 365", min)
+	}
+	return n, nil
+}
+
+func (h *ReportHandler) writeReportError(w http.ResponseWriter, report string, err error) {
+	if errors.Is(err, store.ErrInvalidInput) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.logger.Error("failed to get "+report, "error", err.Error())
+	utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("could not get %s", report))
+}
+
+// parseReportRequest negotiates the response format and parses the report
+// query.
+func parseReportRequest(w http.ResponseWriter, r *http.Request) (string, entity.ReportQuery, error) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		return "", entity.ReportQuery{}, err
+	}
+	q, err := parseReportQuery(r)
+	return format, q, err
+}
+
+// negotiateReportFormat is reportFormat for responses that vary by Accept.
+func negotiateReportFormat(w http.ResponseWriter, r *http.Request) (string, error) {
+	w.Header().Add("Vary", "Accept")
+	return reportFormat(r)
+}
+
+const (
+	defaultReportLimit = 10
+	maxReportLimit     = 100
+)
+
+// parseReportQuery reads from, to, group_by, breakdown and limit. from and
+// to take RFC 3339 timestamps or dates; a date in to includes that whole
+// day. to defaults to now and from to the beginning of time, which is only
+// allowed without group_by.
+func parseReportQuery(r *http.Request) (entity.ReportQuery, error) {
+	values := r.URL.Query()
+	q := entity.ReportQuery{
+		To:      time.Now().UTC(),
+		GroupBy: values.Get("group_by"),
+		Limit:   defaultReportLimit,
+	}
+
+	var err error
+	if from := values.Get("from"); from != "" {
+		if q.From, err = parseReportTime(from, false); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if to := values.Get("to"); to != "" {
+		if q.To, err = parseReportTime(to, true); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if !q.From.IsZero() && !q.From.Before(q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	switch q.GroupBy {
+	case "", entity.GroupByHour, entity.GroupByDay, entity.GroupByWeek, entity.GroupByMonth:
+	default:
+		return q, fmt.Errorf("invalid group_by %q: must be hour, day, week or month", q.GroupBy)
+	}
+	if q.GroupBy != "" && q.From.IsZero() {
+		return q, errors.New("from is required with group_by")
+	}
+
+	seen := make(map[string]bool)
+	for _, breakdown := range strings.Split(values.Get("breakdown"), ",") {
+		breakdown = strings.TrimSpace(breakdown)
+		if breakdown == "" || seen[breakdown] {
+			continue
+		}
+		switch breakdown {
+		case entity.BreakdownPaymentMethod, entity.BreakdownCategory, entity.BreakdownStaff:
+		default:
+			return q, fmt.Errorf("invalid breakdown %q: must be payment_method, category or staff", breakdown)
+		}
+		seen[breakdown] = true
+		q.Breakdowns = append(q.Breakdowns, breakdown)
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxReportLimit {
+			return q, fmt.Errorf("invalid limit: must be between 1 and %d", maxReportLimit)
+		}
+		q.Limit = n
+	}
+
+	return q, nil
+}
+
+func parseReportTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// maxSearchPageSize bounds the page size of search results.
+const maxSearchPageSize = 100
+
+// GetFilterSearch pages through what matches q, with typos and partial
+// words tolerated. filter is a comma separated set of menu, orders,
+// inventory and customers, all of them by default. minPrice and maxPrice
+// may each be left open; from, to and status narrow orders and customers.
+// page and pageSize (default 10) apply to each kind of result; CSV and XLSX
+// exports ignore them and list every match.
+func (h *ReportHandler) GetFilterSearch(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "search", "type", "id", "name", "amount", "relevance", "snippet")
+		err := h.service.ExportFilterSearch(r.Context(), query, func(hit entity.SearchHit) error {
+			var id any
+			if hit.ID != nil {
+				id = *hit.ID
+			}
+			return table.WriteRow([]any{hit.Type, id, hit.Name, hit.Amount, hit.Relevance, hit.Snippet})
+		})
+		h.finishReportExport(w, table, "search", err)
+		return
+	}
+
+	data, err := h.service.GetFilterSearch(r.Context(), query)
 	if err != nil {
 		h.logger.Error("could not get filter search", "error", err.Error())
 		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not get filter search"))
@@ -127,7 +514,143 @@ func (h *ReportHandler) GetFilterSearch(w http.ResponseWriter, r *http.Request)
 	utils.WriteJSON(w, http.StatusOK, data)
 }
 
+func parseSearchQuery(r *http.Request) (entity.SearchQuery, error) {
+	url := r.URL.Query()
+
+	query := entity.SearchQuery{Text: strings.TrimSpace(url.Get("q"))}
+	if query.Text == "" {
+		return entity.SearchQuery{}, errors.New("query parameter is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, target := range strings.Split(url.Get("filter"), ",") {
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target == "" || seen[target] {
+			continue
+		}
+		if !entity.IsValidSearchTarget(target) {
+			return entity.SearchQuery{}, fmt.Errorf("invalid filter %q, expected a comma separated set of %s",
+				target, strings.Join(entity.SearchTargets, ", "))
+		}
+		seen[target] = true
+		query.Targets = append(query.Targets, target)
+	}
+
+	var err error
+	if query.MinPrice, err = parseSearchPrice(url.Get("minPrice"), "minPrice"); err != nil {
+		return entity.SearchQuery{}, err
+	}
+	if query.MaxPrice, err = parseSearchPrice(url.Get("maxPrice"), "maxPrice"); err != nil {
+		return entity.SearchQuery{}, err
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return entity.SearchQuery{}, errors.New("minPrice cannot be greater than maxPrice")
+	}
+
+	if s := url.Get("from"); s != "" {
+		if query.From, err = parseReportTime(s, false); err != nil {
+			return entity.SearchQuery{}, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if s := url.Get("to"); s != "" {
+		if query.To, err = parseReportTime(s, true); err != nil {
+			return entity.SearchQuery{}, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return entity.SearchQuery{}, errors.New("from must be before to")
+	}
+
+	for _, status := range strings.Split(url.Get("status"), ",") {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if status == "" {
+			continue
+		}
+		if !entity.ParseStatus(status).IsValid() {
+			return entity.SearchQuery{}, fmt.Errorf("invalid status %q", status)
+		}
+		query.Statuses = append(query.Statuses, status)
+	}
+
+	pagination, err := dto.NewPaginationFromRequest(r, nil)
+	if err != nil {
+		return entity.SearchQuery{}, err
+	}
+	if pagination.PageSize > maxSearchPageSize {
+		return entity.SearchQuery{}, fmt.Errorf("pageSize cannot be greater than %d", maxSearchPageSize)
+	}
+	query.Limit = pagination.PageSize
+	query.Offset = (pagination.Page - 1) * pagination.PageSize
+
+	return query, nil
+}
+
+// parseSearchPrice parses an optional price bound, nil when absent.
+func parseSearchPrice(s, name string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, fmt.Errorf("invalid %s parameter", name)
+	}
+	if price < 0 {
+		return nil, fmt.Errorf("%s cannot be negative", name)
+	}
+	return &price, nil
+}
+
+// GetSearchSuggestions autocompletes menu item and customer names from the
+// start of a name typed in q. limit defaults to 10.
+func (h *ReportHandler) GetSearchSuggestions(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("query parameter is required"))
+		return
+	}
+
+	limit := 10
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 50 {
+			utils.WriteError(w, http.StatusBadRequest, errors.New("limit must be a whole number between 1 and 50"))
+			return
+		}
+		limit = n
+	}
+
+	suggestions, err := h.service.Suggest(r.Context(), text, limit)
+	if err != nil {
+		h.logger.Error("could not get search suggestions", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not get search suggestions"))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// ReindexSearch recomputes the search vector of every order.
+func (h *ReportHandler) ReindexSearch(w http.ResponseWriter, r *http.Request) {
+	n, err := h.service.ReindexSearch(r.Context())
+	if err != nil {
+		h.logger.Error("could not reindex search", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("could not reindex search"))
+		return
+	}
+	h.logger.Info("reindexed order search", slog.Int64("orders", n))
+
+	utils.WriteJSON(w, http.StatusOK, map[string]int64{"reindexed_orders": n})
+}
+
+// GetTotalItemsByPeriod reports ordered items per day of a month
+// (period=day&month=<name>) or per month of a year (period=month). Passing
+// breakdown=menu_item adds per-item buckets.
 func (h *ReportHandler) GetTotalItemsByPeriod(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 	q := r.URL.Query()
 
 	period := q.Get("period")
@@ -136,60 +659,71 @@ func (h *ReportHandler) GetTotalItemsByPeriod(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	var month int
 	switch period {
 	case "day":
 		monthStr := q.Get("month")
-		yearStr := q.Get("year")
-
 		if monthStr == "" {
 			utils.WriteError(w, http.StatusBadRequest, errors.New("month parameter is required for period=day"))
 			return
 		}
 
-		month, err := monthNameToNumber(monthStr)
+		month, err = monthNameToNumber(monthStr)
 		if err != nil {
 			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid month: %v", err))
 			return
 		}
-
-		year, err := parseYearParam(yearStr)
-		if err != nil {
-			utils.WriteError(w, http.StatusBadRequest, errors.New("valid year parameter required"))
-			return
-		}
-
-		data, err := h.service.GetTotalItemsByPeriod(r.Context(), "day", month, year)
-		if err != nil {
-			h.logger.Error("could not get total items by period", "error", err.Error())
-			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not get total items by period"))
-			return
-		}
-
-		utils.WriteJSON(w, http.StatusOK, data)
-
 	case "month":
-		yearStr := q.Get("year")
-		year, err := parseYearParam(yearStr)
-		if err != nil {
-			utils.WriteError(w, http.StatusBadRequest, errors.New("valid year parameter required"))
-			return
-		}
-
-		data, err := h.service.GetTotalItemsByPeriod(r.Context(), "month", 0, year)
-		if err != nil {
-			h.logger.Error("could not get total items by period", "error", err.Error())
-			utils.WriteError(w, http.StatusInternalServerError, errors.New("could not get total items by period"))
-			return
-		}
+	default:
+		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid period value"))
+		return
+	}
 
-		utils.WriteJSON(w, http.StatusOK, data)
+	year, err := parseYearParam(q.Get("year"))
+	if err != nil || year < 1 || year > 9999 {
+		utils.WriteError(w, http.StatusBadRequest, errors.New("valid year parameter required"))
+		return
+	}
 
+	var byMenuItem bool
+	switch breakdown := q.Get("breakdown"); breakdown {
+	case "":
+	case entity.BreakdownMenuItem:
+		byMenuItem = true
 	default:
-		utils.WriteError(w, http.StatusBadRequest, errors.New("invalid period value"))
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid breakdown %q: must be menu_item", breakdown))
+		return
 	}
+
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "items-by-period",
+			"period_start", "label", "menu_item_id", "menu_item", "item_count", "revenue")
+		err := h.service.ExportTotalItemsByPeriod(r.Context(), period, month, year, byMenuItem, func(row entity.ItemsRow, label string) error {
+			var id any
+			if row.Key != "" {
+				id, _ = strconv.ParseInt(row.Key, 10, 64)
+			}
+			return table.WriteRow([]any{row.Bucket, label, id, row.Label, row.ItemCount, row.Revenue})
+		})
+		h.finishReportExport(w, table, "total items by period", err)
+		return
+	}
+
+	data, err := h.service.GetTotalItemsByPeriod(r.Context(), period, month, year, byMenuItem)
+	if err != nil {
+		h.writeReportError(w, "total items by period", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, data)
 }
 
 func (h *ReportHandler) GetNumberOfOrderedItems(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateReportFormat(w, r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 	q := r.URL.Query()
 
 	startDate := q.Get("startDate")
@@ -219,6 +753,15 @@ func (h *ReportHandler) GetNumberOfOrderedItems(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	if format != dto.FormatJSON {
+		table := newReportTable(w, format, "ordered-items", "item", "quantity")
+		err := h.service.ExportOrderedItems(r.Context(), start, end, func(name string, quantity int) error {
+			return table.WriteRow([]any{name, quantity})
+		})
+		h.finishReportExport(w, table, "ordered items", err)
+		return
+	}
+
 	data, err := h.service.GetOrderedItemsReport(r.Context(), start, end)
 	if err != nil {
 		h.logger.Error("could not get total items by period", "error", err.Error())
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+type SupplierService interface {
+	CreateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error)
+	GetSuppliers(ctx context.Context) ([]entity.Supplier, error)
+	GetSupplierById(ctx context.Context, id int64) (entity.Supplier, error)
+	UpdateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error)
+	DeleteSupplier(ctx context.Context, id int64) error
+}
+
+type SupplierHandler struct {
+	service SupplierService
+	logger  *slog.Logger
+}
+
+func NewSupplierHandler(service SupplierService, logger *slog.Logger) *SupplierHandler {
+	return &SupplierHandler{service, logger}
+}
+
+func (h *SupplierHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /suppliers", h.createSupplier)
+	mux.HandleFunc("POST /suppliers/", h.createSupplier)
+
+	mux.HandleFunc("GET /suppliers", h.getSuppliers)
+	mux.HandleFunc("GET /suppliers/", h.getSuppliers)
+
+	mux.HandleFunc("GET /suppliers/{id}", h.getSupplierById)
+	mux.HandleFunc("GET /suppliers/{id}/", h.getSupplierById)
+
+	mux.HandleFunc("PUT /suppliers/{id}", h.updateSupplier)
+	mux.HandleFunc("PUT /suppliers/{id}/", h.updateSupplier)
+
+	mux.HandleFunc("DELETE /suppliers/{id}", h.deleteSupplier)
+	mux.HandleFunc("DELETE /suppliers/{id}/", h.deleteSupplier)
+}
+
+func (h *SupplierHandler) createSupplier(w http.ResponseWriter, r *http.Request) {
+	var req dto.SupplierRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse supplier request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	supplier, err := h.service.CreateSupplier(r.Context(), req.MapToEntity())
+	if err != nil {
+		h.handleError(w, 0, err)
+		return
+	}
+	h.logger.Info("Created supplier", slog.Int64("id", supplier.ID))
+	utils.WriteJSON(w, http.StatusCreated, dto.SupplierToResponse(supplier))
+}
+
+func (h *SupplierHandler) getSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.service.GetSuppliers(r.Context())
+	if err != nil {
+		h.handleError(w, 0, err)
+		return
+	}
+
+	response := make([]dto.SupplierResponse, 0, len(suppliers))
+	for _, s := range suppliers {
+		response = append(response, dto.SupplierToResponse(s))
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *SupplierHandler) getSupplierById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	supplier, err := h.service.GetSupplierById(r.Context(), id)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.SupplierToResponse(supplier))
+}
+
+// updateSupplier replaces the supplier, including its whole price list.
+func (h *SupplierHandler) updateSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.SupplierRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse supplier request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	supplier := req.MapToEntity()
+	supplier.ID = id
+	supplier, err = h.service.UpdateSupplier(r.Context(), supplier)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.SupplierToResponse(supplier))
+}
+
+func (h *SupplierHandler) deleteSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.DeleteSupplier(r.Context(), id); err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SupplierHandler) handleError(w http.ResponseWriter, id int64, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("supplier with ID %d not found", id))
+	case errors.Is(err, store.ErrInvalidInput):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, store.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		h.logger.Error("Failed to process supplier", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to process supplier"))
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+// IdempotentPaths are the create endpoints that honor an Idempotency-Key.
+// Retrying one of them after a lost response would otherwise create the
+// record twice; the other POSTs act on an existing record and either repeat
+// harmlessly or fail the second time.
+var IdempotentPaths = []string{"/orders", "/menu", "/inventory", "/suppliers", "/purchase-orders", "/webhooks"}
+
+// importFormat reads the format of a bulk import from its Content-Type.
+func importFormat(r *http.Request) (string, error) {
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "text/csv":
+		return dto.FormatCSV, nil
+	case "application/json", "":
+		return dto.FormatJSON, nil
+	}
+	return "", errors.New("Content-Type must be text/csv or application/json")
+}
+
+// exportFormat picks the export format from ?format= or, failing that, the Accept header.
+func exportFormat(r *http.Request) (string, error) {
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case dto.FormatCSV, dto.FormatJSON:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		return dto.FormatCSV, nil
+	}
+	return dto.FormatJSON, nil
+}
+
+func startExport(w http.ResponseWriter, format, name string) {
+	contentType := "application/json"
+	switch format {
+	case dto.FormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	case dto.FormatXLSX:
+		contentType = xlsxContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeImportError answers a rejected import, listing the offending rows when there are any.
+func writeImportError(w http.ResponseWriter, err error) {
+	var rowErrs store.RowErrors
+	if errors.As(err, &rowErrs) {
+		writeRowErrors(w, rowErrs)
+		return
+	}
+	utils.WriteError(w, http.StatusInternalServerError, errors.New("failed to import items"))
+}
+
+// writeParseError answers a request whose body could not be read: 413 past
+// the body size limit, 415 when it is not JSON and 400 otherwise.
+func writeParseError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body must be at most %d bytes", tooLarge.Limit))
+	case errors.Is(err, utils.ErrUnsupportedMediaType):
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+	default:
+		utils.WriteError(w, http.StatusBadRequest, err)
+	}
+}
+
+// writeValidationError answers a request that failed validation, listing
+// every invalid property.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var fieldErrs dto.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusBadRequest, map[string]any{
+		"error":  "invalid request payload",
+		"errors": fieldErrs,
+	})
+}
+
+func writeRowErrors(w http.ResponseWriter, rowErrs []entity.ImportRowError) {
+	utils.WriteJSON(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":  "some rows are invalid, nothing was imported",
+		"errors": rowErrs,
+	})
+}
+
+// jsonArrayWriter streams a JSON array one element at a time.
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	sep := ","
+	if a.count == 0 {
+		sep = "["
+	}
+	a.count++
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	_, err = a.w.Write(b)
+	return err
+}
+
+func (a *jsonArrayWriter) Close() error {
+	if a.count == 0 {
+		_, err := io.WriteString(a.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(a.w, "]\n")
+	return err
+}
+
+func hasRowError(rowErrs []entity.ImportRowError, row int) bool {
+	for _, e := range rowErrs {
+		if e.Row == row {
+			return true
+		}
+	}
+	return false
+}
+
+func sortRowErrors(rowErrs []entity.ImportRowError) {
+	sort.SliceStable(rowErrs, func(i, j int) bool { return rowErrs[i].Row < rowErrs[j].Row })
+}
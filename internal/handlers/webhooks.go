@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/handlers/dto"
+	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
+)
+
+// deliveryLogLimit caps how many recent deliveries the log endpoint returns.
+const deliveryLogLimit = 100
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	GetSubscriptionById(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]entity.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	service WebhookService
+	logger  *slog.Logger
+}
+
+func NewWebhookHandler(service WebhookService, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{service, logger}
+}
+
+func (h *WebhookHandler) RegisterEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("POST /webhooks", h.createSubscription)
+	mux.HandleFunc("POST /webhooks/", h.createSubscription)
+
+	mux.HandleFunc("GET /webhooks", h.getSubscriptions)
+	mux.HandleFunc("GET /webhooks/", h.getSubscriptions)
+
+	mux.HandleFunc("GET /webhooks/{id}", h.getSubscriptionById)
+	mux.HandleFunc("GET /webhooks/{id}/", h.getSubscriptionById)
+
+	mux.HandleFunc("DELETE /webhooks/{id}", h.deleteSubscription)
+	mux.HandleFunc("DELETE /webhooks/{id}/", h.deleteSubscription)
+
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", h.getDeliveries)
+	mux.HandleFunc("GET /webhooks/{id}/deliveries/", h.getDeliveries)
+}
+
+func (h *WebhookHandler) createSubscription(w http.ResponseWriter, r *http.Request) {
+	var req dto.WebhookSubscriptionRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		h.logger.Error("Failed to parse webhook subscription request", "error", err.Error())
+		writeParseError(w, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	sub, err := h.service.CreateSubscription(r.Context(), req.MapToEntity())
+	if err != nil {
+		h.logger.Error("Failed to create webhook subscription", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := dto.WebhookSubscriptionToResponse(sub)
+	response.Secret = sub.Secret
+	utils.WriteJSON(w, http.StatusCreated, response)
+}
+
+func (h *WebhookHandler) getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.service.GetSubscriptions(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get webhook subscriptions", "error", err.Error())
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := make([]dto.WebhookSubscriptionResponse, 0, len(subs))
+	for _, sub := range subs {
+		response = append(response, dto.WebhookSubscriptionToResponse(sub))
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *WebhookHandler) getSubscriptionById(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.service.GetSubscriptionById(r.Context(), id)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, dto.WebhookSubscriptionToResponse(sub))
+}
+
+func (h *WebhookHandler) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.DeleteSubscription(r.Context(), id); err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) getDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePathID(r, "id")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(r.Context(), id, deliveryLogLimit)
+	if err != nil {
+		h.handleError(w, id, err)
+		return
+	}
+
+	response := make([]dto.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		response = append(response, dto.WebhookDeliveryToResponse(d))
+	}
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *WebhookHandler) handleError(w http.ResponseWriter, id int64, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("webhook subscription with ID %d not found", id))
+		return
+	}
+	h.logger.Error("Failed to process webhook subscription", "error", err.Error())
+	utils.WriteError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,120 @@
+// Package jobs runs background work out of a Postgres table. Jobs are
+// enqueued with Enqueue, optionally inside the transaction of the change that
+// caused them, and picked up by Runner workers with FOR UPDATE SKIP LOCKED,
+// so any number of app instances can share one queue.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Job is a claimed job as seen by its handler.
+type Job struct {
+	ID          int64
+	Kind        string
+	Payload     json.RawMessage
+	Attempt     int
+	MaxAttempts int
+	RunAt       time.Time
+	CreatedAt   time.Time
+}
+
+// Decode unmarshals the job payload into v.
+func (j Job) Decode(v any) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("decode %s payload: %w", j.Kind, err))
+	}
+	return nil
+}
+
+// HandlerFunc does the work of one job kind. Returning an error schedules a
+// retry until the job runs out of attempts.
+type HandlerFunc func(ctx context.Context, job Job) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: the job is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Options tune a single enqueued job. The zero value runs the job as soon as
+// possible with the runner's default number of attempts.
+type Options struct {
+	RunAt       time.Time
+	MaxAttempts int
+	// UniqueKey makes Enqueue a no-op when a job with the same key exists.
+	UniqueKey string
+}
+
+// Enqueue adds a job. Passing a *sql.Tx makes the job part of that
+// transaction: it only becomes visible to workers if the transaction commits.
+// It reports whether a job was added, which is false only for a duplicate
+// UniqueKey.
+func Enqueue(ctx context.Context, db Execer, kind string, payload any, opts Options) (bool, error) {
+	const op = "jobs.Enqueue"
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("%s: encode payload: %w", op, err)
+	}
+	if payload == nil {
+		data = []byte("{}")
+	}
+
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	var uniqueKey sql.NullString
+	if opts.UniqueKey != "" {
+		uniqueKey = sql.NullString{String: opts.UniqueKey, Valid: true}
+	}
+
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO jobs (kind, payload, max_attempts, run_at, unique_key)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) DO NOTHING`,
+		kind, data, maxAttempts, runAt, uniqueKey)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n > 0, nil
+}
+
+// defaultMaxAttempts applies to jobs enqueued without MaxAttempts outside of
+// a Runner; Runner.Enqueue uses the configured value instead.
+const defaultMaxAttempts = 5
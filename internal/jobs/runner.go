@@ -0,0 +1,393 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"frappuccino-alem/internal/config"
+
+	"github.com/lib/pq"
+)
+
+// Retry delays grow from retryBaseDelay, doubling per attempt up to retryMaxDelay.
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// A supervised loop that returns early is restarted after a delay growing
+// from restartBaseDelay, doubling per restart up to restartMaxDelay.
+const (
+	restartBaseDelay = time.Second
+	restartMaxDelay  = time.Minute
+)
+
+// maintenanceInterval is how often the scheduler enqueues due cron jobs and
+// requeues jobs whose worker disappeared.
+const maintenanceInterval = 15 * time.Second
+
+// KindPrune is the built-in job that deletes old succeeded jobs.
+const KindPrune = "jobs.prune"
+
+type scheduled struct {
+	name     string
+	schedule Schedule
+	kind     string
+	payload  any
+	next     time.Time
+}
+
+// supervised is a long-running loop the runner owns next to its workers.
+type supervised struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Runner executes registered job kinds with a pool of workers and enqueues
+// scheduled jobs. Register handlers, schedules and supervised loops before
+// Start.
+type Runner struct {
+	db       *sql.DB
+	cfg      config.Jobs
+	logger   *slog.Logger
+	workerID string
+
+	handlers   map[string]HandlerFunc
+	schedules  []*scheduled
+	supervised []supervised
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(db *sql.DB, cfg config.Jobs, logger *slog.Logger) *Runner {
+	host, _ := os.Hostname()
+	r := &Runner{
+		db:       db,
+		cfg:      cfg,
+		logger:   logger.With(slog.String("component", "jobs")),
+		workerID: host + ":" + strconv.Itoa(os.Getpid()),
+		handlers: make(map[string]HandlerFunc),
+	}
+	r.Register(KindPrune, r.prune)
+	r.mustSchedule("prune finished jobs", "@hourly", KindPrune, nil)
+	return r
+}
+
+// Register sets the handler for a job kind. Workers only claim kinds they
+// have a handler for.
+func (r *Runner) Register(kind string, h HandlerFunc) {
+	r.handlers[kind] = h
+}
+
+// Schedule enqueues a job of kind on every tick of spec (see ParseSchedule).
+// Each tick is enqueued once no matter how many instances are running.
+func (r *Runner) Schedule(name, spec, kind string, payload any) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	r.schedules = append(r.schedules, &scheduled{
+		name:     name,
+		schedule: schedule,
+		kind:     kind,
+		payload:  payload,
+		next:     schedule.Next(time.Now()),
+	})
+	return nil
+}
+
+// Supervise runs fn from Start until Stop, which waits for it to return just
+// like it waits for running jobs. fn must return once its ctx is done; if it
+// returns any earlier, with or without an error, it is started again.
+func (r *Runner) Supervise(name string, fn func(ctx context.Context) error) {
+	r.supervised = append(r.supervised, supervised{name, fn})
+}
+
+func (r *Runner) mustSchedule(name, spec, kind string, payload any) {
+	if err := r.Schedule(name, spec, kind, payload); err != nil {
+		panic(err)
+	}
+}
+
+// Enqueue adds a job with the runner's default number of attempts.
+func (r *Runner) Enqueue(ctx context.Context, db Execer, kind string, payload any, opts Options) (bool, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = r.cfg.MaxAttempts
+	}
+	return Enqueue(ctx, db, kind, payload, opts)
+}
+
+// Start launches the workers, the scheduler and the supervised loops. They
+// run until Stop is called or ctx is cancelled.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	for i := 0; i < r.cfg.Workers; i++ {
+		r.wg.Add(1)
+		go func(worker int) {
+			defer r.wg.Done()
+			r.work(ctx, r.workerID+"/"+strconv.Itoa(worker), kinds)
+		}(i)
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.maintain(ctx)
+	}()
+
+	for _, sv := range r.supervised {
+		r.wg.Add(1)
+		go func(sv supervised) {
+			defer r.wg.Done()
+			r.supervise(ctx, sv)
+		}(sv)
+	}
+
+	r.logger.Info("job runner started", slog.Int("workers", r.cfg.Workers), slog.Any("kinds", kinds))
+}
+
+// Stop asks the workers to finish their current job and the supervised loops
+// to return, and waits for them, or until ctx is done. Jobs cut off by ctx
+// are retried by another worker later.
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		r.logger.Info("job runner stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs.Runner.Stop: %w", ctx.Err())
+	}
+}
+
+// supervise keeps sv running until ctx is done. The restart delay starts
+// over once the loop has stayed up longer than restartMaxDelay.
+func (r *Runner) supervise(ctx context.Context, sv supervised) {
+	logger := r.logger.With(slog.String("name", sv.name))
+	delay := restartBaseDelay
+	for {
+		started := time.Now()
+		err := sv.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > restartMaxDelay {
+			delay = restartBaseDelay
+		}
+		if err != nil {
+			logger.Error("supervised loop stopped, restarting", "error", err.Error(), slog.Duration("delay", delay))
+		} else {
+			logger.Warn("supervised loop returned, restarting", slog.Duration("delay", delay))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, restartMaxDelay)
+	}
+}
+
+func (r *Runner) work(ctx context.Context, workerID string, kinds []string) {
+	for {
+		job, err := r.claim(ctx, workerID, kinds)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to claim job", "error", err.Error())
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.cfg.PollInterval):
+			}
+			continue
+		}
+		r.execute(ctx, *job)
+	}
+}
+
+func (r *Runner) claim(ctx context.Context, workerID string, kinds []string) (*Job, error) {
+	var job Job
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), locked_by = $1, updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE status = 'queued' AND run_at <= NOW() AND kind = ANY($2)
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts, max_attempts, run_at, created_at`,
+		workerID, pq.Array(kinds),
+	).Scan(&job.ID, &job.Kind, &job.Payload, &job.Attempt, &job.MaxAttempts, &job.RunAt, &job.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *Runner) execute(ctx context.Context, job Job) {
+	logger := r.logger.With(slog.Int64("job_id", job.ID), slog.String("kind", job.Kind), slog.Int("attempt", job.Attempt))
+
+	// a stopping runner lets the job finish within its own timeout
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.JobTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := r.run(jobCtx, job)
+	logger = logger.With(slog.Duration("duration", time.Since(start)))
+
+	// finishing must not be skipped because the runner is stopping
+	finishCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		_, dbErr := r.db.ExecContext(finishCtx, `
+			UPDATE jobs
+			SET status = 'succeeded', finished_at = NOW(), locked_at = NULL, locked_by = NULL, last_error = NULL, updated_at = NOW()
+			WHERE id = $1`, job.ID)
+		if dbErr != nil {
+			logger.Error("failed to mark job succeeded", "error", dbErr.Error())
+		}
+		logger.Debug("job succeeded")
+		return
+	}
+
+	if isPermanent(err) || job.Attempt >= job.MaxAttempts {
+		_, dbErr := r.db.ExecContext(finishCtx, `
+			UPDATE jobs
+			SET status = 'dead', finished_at = NOW(), locked_at = NULL, locked_by = NULL, last_error = $2, updated_at = NOW()
+			WHERE id = $1`, job.ID, err.Error())
+		if dbErr != nil {
+			logger.Error("failed to dead-letter job", "error", dbErr.Error())
+		}
+		logger.Error("job dead-lettered", "error", err.Error())
+		return
+	}
+
+	retryAt := time.Now().Add(retryDelay(job.Attempt))
+	_, dbErr := r.db.ExecContext(finishCtx, `
+		UPDATE jobs
+		SET status = 'queued', run_at = $2, locked_at = NULL, locked_by = NULL, last_error = $3, updated_at = NOW()
+		WHERE id = $1`, job.ID, retryAt, err.Error())
+	if dbErr != nil {
+		logger.Error("failed to reschedule job", "error", dbErr.Error())
+	}
+	logger.Warn("job failed, will retry", "error", err.Error(), slog.Time("retry_at", retryAt))
+}
+
+// run calls the handler, turning a panic into an error.
+func (r *Runner) run(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return r.handlers[job.Kind](ctx, job)
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// maintain enqueues due scheduled jobs and requeues jobs left running by a
+// worker that died.
+func (r *Runner) maintain(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		r.enqueueScheduled(ctx)
+		r.requeueStale(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) enqueueScheduled(ctx context.Context) {
+	now := time.Now()
+	for _, s := range r.schedules {
+		if s.next.IsZero() || now.Before(s.next) {
+			continue
+		}
+		// the slot time in the key lets only one instance enqueue each run
+		key := fmt.Sprintf("schedule:%s:%d", s.name, s.next.Unix())
+		_, err := r.Enqueue(ctx, r.db, s.kind, s.payload, Options{RunAt: s.next, UniqueKey: key})
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to enqueue scheduled job", "schedule", s.name, "error", err.Error())
+			}
+			continue
+		}
+		// runs missed while the app was down are not caught up
+		s.next = s.schedule.Next(now)
+	}
+}
+
+func (r *Runner) requeueStale(ctx context.Context) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+			last_error = 'worker stopped responding',
+			locked_at = NULL, locked_by = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - make_interval(secs => $1)`,
+		(2 * r.cfg.JobTimeout).Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("failed to requeue stale jobs", "error", err.Error())
+		}
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger.Warn("requeued stale jobs", slog.Int64("count", n))
+	}
+}
+
+func (r *Runner) prune(ctx context.Context, job Job) error {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM jobs
+		WHERE status = 'succeeded' AND finished_at < NOW() - make_interval(secs => $1)`,
+		r.cfg.Retention.Seconds())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger.Info("pruned finished jobs", slog.Int64("count", n))
+	}
+	return nil
+}
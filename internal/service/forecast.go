@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+)
+
+// GetInventoryForecast projects when every inventory item runs out from its
+// average daily usage over the windowDays before asOf, and suggests how much
+// to order so that stock covers coverDays of usage on top of the reorder
+// level. Stock levels are today's, whatever asOf is.
+func (s *ReportService) GetInventoryForecast(ctx context.Context, asOf time.Time, windowDays, coverDays int, source string) (entity.InventoryForecast, error) {
+	const op = "service.GetInventoryForecast"
+
+	forecast := entity.InventoryForecast{
+		AsOf:       asOf,
+		WindowDays: windowDays,
+		CoverDays:  coverDays,
+		Source:     source,
+		Items:      []entity.InventoryForecastItem{},
+	}
+	switch source {
+	case entity.ForecastSourceAuto, entity.ForecastSourceLedger, entity.ForecastSourceOrders:
+	default:
+		return forecast, fmt.Errorf("%s: %w: unknown source %q", op, store.ErrInvalidInput, source)
+	}
+	if windowDays < 1 || coverDays < 0 {
+		return forecast, fmt.Errorf("%s: %w: window must be at least a day and cover not negative", op, store.ErrInvalidInput)
+	}
+
+	usage, err := s.repo.GetInventoryUsage(ctx, asOf.AddDate(0, 0, -windowDays), asOf)
+	if err != nil {
+		return forecast, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, u := range usage {
+		item := entity.InventoryForecastItem{
+			InventoryID:  u.InventoryID,
+			Name:         u.Name,
+			Unit:         u.Unit,
+			Quantity:     u.Quantity,
+			ReorderLevel: u.ReorderLevel,
+			UsageSource:  source,
+		}
+		if source == entity.ForecastSourceAuto {
+			item.UsageSource = entity.ForecastSourceOrders
+			if u.LedgerEntries > 0 {
+				item.UsageSource = entity.ForecastSourceLedger
+			}
+		}
+		item.Usage = u.OrderUsage
+		if item.UsageSource == entity.ForecastSourceLedger {
+			item.Usage = u.LedgerUsage
+		}
+
+		avg := item.Usage / float64(windowDays)
+		item.AvgDailyUsage = math.Round(avg*1000) / 1000
+		if avg > 0 {
+			days := roundQuantity(u.Quantity / avg)
+			stockout := asOf.Add(time.Duration(days * float64(24*time.Hour)))
+			item.DaysUntilStockout, item.StockoutDate = &days, &stockout
+		}
+
+		target := avg*float64(coverDays) + u.ReorderLevel
+		if target > u.Quantity {
+			item.SuggestedReorder = math.Ceil((target-u.Quantity)*100) / 100
+			item.EstimatedCost = roundQuantity(item.SuggestedReorder * u.Price)
+		}
+		forecast.EstimatedCost += item.EstimatedCost
+		forecast.Items = append(forecast.Items, item)
+	}
+	forecast.EstimatedCost = roundQuantity(forecast.EstimatedCost)
+
+	// soonest to run out first, unused items last
+	sort.SliceStable(forecast.Items, func(i, j int) bool {
+		a, b := forecast.Items[i].DaysUntilStockout, forecast.Items[j].DaysUntilStockout
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		default:
+			return *a < *b
+		}
+	})
+
+	return forecast, nil
+}
+
+func roundQuantity(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -8,6 +8,7 @@ import (
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
 	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
 )
 
 type InventoryService interface {
@@ -16,7 +17,15 @@ type InventoryService interface {
 	GetPaginatedLeftOverItems(ctx context.Context, pagination *dto.Pagination) (*dto.PaginationResponse[dto.LeftOverItem], error)
 	GetInventoryItemById(ctx context.Context, id int64) (entity.InventoryItem, error)
 	DeleteInventoryItemById(ctx context.Context, id int64) (entity.InventoryItem, error)
-	UpdateInventoryItemById(ctx context.Context, id int64, request dto.InventoryItemRequest) error
+	UpdateInventoryItemById(ctx context.Context, id int64, request dto.InventoryItemRequest, ifMatch string) error
+	PatchInventoryItemById(ctx context.Context, id int64, patch dto.InventoryItemPatch, ifMatch string) error
+	ImportInventoryItems(ctx context.Context, items []entity.InventoryItem) (entity.ImportResult, error)
+	ExportInventoryItems(ctx context.Context, fn func(item entity.InventoryItem) error) error
+	LogWaste(ctx context.Context, entry entity.WasteEntry) (entity.WasteEntry, error)
+	CreateBatch(ctx context.Context, batch entity.InventoryBatch) (entity.InventoryBatch, error)
+	GetBatches(ctx context.Context, inventoryID int64) ([]entity.InventoryBatch, error)
+	GetExpiringBatches(ctx context.Context, within time.Duration) ([]entity.InventoryBatch, error)
+	WasteExpiredBatches(ctx context.Context) ([]entity.WasteEntry, error)
 }
 
 type inventoryService struct {
@@ -101,44 +110,68 @@ func (s *inventoryService) DeleteInventoryItemById(ctx context.Context, Inventor
 	return item, nil
 }
 
-func (s *inventoryService) UpdateInventoryItemById(ctx context.Context, InventoryId int64, req dto.InventoryItemRequest) error {
-	const op = "service.UpdateInventoryItemById"
+// UpdateInventoryItemById replaces the inventory item with req, which must
+// describe the whole item. A non-empty ifMatch must match the item's current
+// ETag, otherwise store.ErrPreconditionFailed is returned.
+func (s *inventoryService) UpdateInventoryItemById(ctx context.Context, InventoryId int64, req dto.InventoryItemRequest, ifMatch string) error {
+	replacement := req.MapToEntity()
 	return s.repo.UpdateByID(ctx, int64(InventoryId), func(item *entity.InventoryItem) (updated bool, err error) {
-		if req.Name != nil {
-			if item.ItemName != *req.Name {
-				updated = true
-				item.ItemName = *req.Name
-			}
+		if ifMatch != "" && !utils.MatchesETag(ifMatch, utils.ETag(item.ID, item.UpdatedAt)) {
+			return false, store.ErrPreconditionFailed
 		}
 
-		if req.Quantity != nil {
-			if item.Quantity != *req.Quantity {
-				updated = true
-				item.Quantity = *req.Quantity
-			}
+		updated = item.ItemName != replacement.ItemName ||
+			item.Quantity != replacement.Quantity ||
+			item.Unit != replacement.Unit ||
+			item.Price != replacement.Price ||
+			item.ReorderLevel != replacement.ReorderLevel
+		if !updated {
+			return false, nil
 		}
 
-		if req.UnitType != nil {
-			if item.Unit != *req.UnitType {
-				updated = true
-				item.Unit = *req.UnitType
-			}
+		item.ItemName = replacement.ItemName
+		item.Quantity = replacement.Quantity
+		item.Unit = replacement.Unit
+		item.Price = replacement.Price
+		item.ReorderLevel = replacement.ReorderLevel
+		item.UpdatedAt = time.Now()
+		return true, nil
+	})
+}
+
+// PatchInventoryItemById applies an RFC 7396 merge patch to the inventory item.
+func (s *inventoryService) PatchInventoryItemById(ctx context.Context, InventoryId int64, patch dto.InventoryItemPatch, ifMatch string) error {
+	return s.repo.UpdateByID(ctx, int64(InventoryId), func(item *entity.InventoryItem) (updated bool, err error) {
+		if ifMatch != "" && !utils.MatchesETag(ifMatch, utils.ETag(item.ID, item.UpdatedAt)) {
+			return false, store.ErrPreconditionFailed
 		}
 
-		if req.Price != nil {
-			if item.Price != *req.Price {
-				updated = true
-				item.Price = *req.Price
-			}
+		if patch.Name.Set && item.ItemName != patch.Name.Value {
+			updated = true
+			item.ItemName = patch.Name.Value
+		}
+		if patch.Quantity.Set && item.Quantity != patch.Quantity.Value {
+			updated = true
+			item.Quantity = patch.Quantity.Value
+		}
+		if patch.UnitType.Set && item.Unit != patch.UnitType.Value {
+			updated = true
+			item.Unit = patch.UnitType.Value
+		}
+		if patch.Price.Set && item.Price != patch.Price.Value {
+			updated = true
+			item.Price = patch.Price.Value
+		}
+		if patch.ReorderLevel.Set && item.ReorderLevel != patch.ReorderLevel.Value {
+			// Value is zero for null, which is what clearing means
+			updated = true
+			item.ReorderLevel = patch.ReorderLevel.Value
 		}
 
 		if updated {
 			item.UpdatedAt = time.Now()
-			return
 		}
-
-		err = fmt.Errorf("no fields were updated")
-		return
+		return updated, nil
 	})
 }
 
@@ -177,3 +210,79 @@ func (s *inventoryService) GetPaginatedLeftOverItems(ctx context.Context, pagina
 
 	return response, nil
 }
+
+func (s *inventoryService) ImportInventoryItems(ctx context.Context, items []entity.InventoryItem) (entity.ImportResult, error) {
+	const op = "service.ImportInventoryItems"
+
+	result, err := s.repo.ImportInventoryItems(ctx, items)
+	if err != nil {
+		return entity.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+func (s *inventoryService) ExportInventoryItems(ctx context.Context, fn func(item entity.InventoryItem) error) error {
+	const op = "service.ExportInventoryItems"
+
+	if err := s.repo.ExportInventoryItems(ctx, fn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *inventoryService) LogWaste(ctx context.Context, entry entity.WasteEntry) (entity.WasteEntry, error) {
+	const op = "service.LogWaste"
+
+	entry, err := s.repo.LogWaste(ctx, entry)
+	if err != nil {
+		return entity.WasteEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+	return entry, nil
+}
+
+func (s *inventoryService) CreateBatch(ctx context.Context, batch entity.InventoryBatch) (entity.InventoryBatch, error) {
+	const op = "service.CreateBatch"
+
+	if batch.ExpiresAt != nil && !batch.ReceivedAt.IsZero() && !batch.ExpiresAt.After(batch.ReceivedAt) {
+		return entity.InventoryBatch{}, fmt.Errorf("%s: %w: batch expires before it was received", op, store.ErrInvalidInput)
+	}
+	batch, err := s.repo.CreateBatch(ctx, batch)
+	if err != nil {
+		return entity.InventoryBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return batch, nil
+}
+
+func (s *inventoryService) GetBatches(ctx context.Context, inventoryID int64) ([]entity.InventoryBatch, error) {
+	const op = "service.GetBatches"
+
+	batches, err := s.repo.GetBatches(ctx, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return batches, nil
+}
+
+// GetExpiringBatches lists the batches with stock left that expire within
+// the given time from now, including those already expired.
+func (s *inventoryService) GetExpiringBatches(ctx context.Context, within time.Duration) ([]entity.InventoryBatch, error) {
+	const op = "service.GetExpiringBatches"
+
+	batches, err := s.repo.GetExpiringBatches(ctx, time.Now().Add(within))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return batches, nil
+}
+
+// WasteExpiredBatches logs the stock left in expired batches as waste.
+func (s *inventoryService) WasteExpiredBatches(ctx context.Context) ([]entity.WasteEntry, error) {
+	const op = "service.WasteExpiredBatches"
+
+	entries, err := s.repo.WasteExpiredBatches(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return entries, nil
+}
@@ -9,6 +9,7 @@ import (
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
 	"frappuccino-alem/internal/store"
+	"frappuccino-alem/internal/utils"
 )
 
 type MenuService interface {
@@ -16,7 +17,10 @@ type MenuService interface {
 	GetPaginatedMenuItems(ctx context.Context, pagination *dto.Pagination) (*dto.PaginationResponse[entity.MenuItem], error)
 	GetMenuItemById(ctx context.Context, id int64) (entity.MenuItem, error)
 	DeleteMenuItemById(ctx context.Context, id int64) error
-	UpdateMenuItemById(ctx context.Context, id int64, request dto.MenuItemRequest) error
+	UpdateMenuItemById(ctx context.Context, id int64, request dto.MenuItemRequest, ifMatch string) error
+	PatchMenuItemById(ctx context.Context, id int64, patch dto.MenuItemPatch, ifMatch string) error
+	ImportMenuItems(ctx context.Context, items []entity.MenuItem) (entity.ImportResult, error)
+	ExportMenuItems(ctx context.Context, fn func(item entity.MenuItem) error) error
 }
 
 type menuService struct {
@@ -38,20 +42,10 @@ func (s *menuService) CreateMenuItem(ctx context.Context, item entity.MenuItem)
 	const op = "service.CreateMenuItem"
 
 	// Validate ingredients exist
-	for i, ing := range item.Ingredients {
-
-		inventoryItem, err := s.inventoryRepo.GetInventoryItemById(ctx, ing.ItemID)
-		if err != nil {
-			if errors.Is(err, store.ErrNotFound) {
-				return entity.MenuItem{}, fmt.Errorf("ingredient %d not found", ing.ItemID)
-			}
-			return entity.MenuItem{}, fmt.Errorf("%s: %w", op, err)
-		}
-		item.Ingredients[i].Name = inventoryItem.ItemName
-		item.Ingredients[i].Unit = inventoryItem.Unit
-		item.Ingredients[i].Price = inventoryItem.Price
+	if err := s.checkIngredientsExist(ctx, item.Ingredients); err != nil {
+		return entity.MenuItem{}, fmt.Errorf("%s: %w", op, err)
 	}
-	
+
 	// Proceed with creation
 	id, err := s.menuRepo.CreateMenuItem(ctx, item)
 	if err != nil {
@@ -97,70 +91,158 @@ func (s *menuService) GetMenuItemById(ctx context.Context, id int64) (entity.Men
 	return item, nil
 }
 
-func (s *menuService) UpdateMenuItemById(ctx context.Context, id int64, req dto.MenuItemRequest) error {
+// UpdateMenuItemById replaces the menu item with req, which must describe
+// the whole item. A non-empty ifMatch must match the item's current ETag,
+// otherwise store.ErrPreconditionFailed is returned.
+func (s *menuService) UpdateMenuItemById(ctx context.Context, id int64, req dto.MenuItemRequest, ifMatch string) error {
 	const op = "service.UpdateMenuItemById"
 
+	replacement := req.MapToEntity()
+	if err := s.checkIngredientsExist(ctx, replacement.Ingredients); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return s.menuRepo.UpdateByID(ctx, int64(id), func(item *entity.MenuItem) (updated bool, err error) {
-		if req.Name != nil {
-			if item.Name != *req.Name {
-				updated = true
-				item.Name = *req.Name
-			}
+		if ifMatch != "" && !utils.MatchesETag(ifMatch, utils.ETag(item.ID, item.UpdatedAt)) {
+			return false, store.ErrPreconditionFailed
 		}
 
-		if req.Price != nil {
-			if item.Price != *req.Price {
-				updated = true
-				item.Price = *req.Price
-			}
+		item.Name = replacement.Name
+		item.Description = replacement.Description
+		item.Price = replacement.Price
+		item.Categories = nonNilStrings(replacement.Categories)
+		item.Allergens = nonNilStrings(replacement.Allergens)
+		item.Metadata = replacement.Metadata
+		if item.Metadata == nil {
+			item.Metadata = entity.JSONB{}
 		}
+		item.Ingredients = replacement.Ingredients
+		item.UpdatedAt = time.Now()
+		return true, nil
+	})
+}
 
-		if req.Description != nil {
-			if item.Description != *req.Description {
-				updated = true
-				item.Description = *req.Description
-			}
+// PatchMenuItemById applies an RFC 7396 merge patch to the menu item.
+// Metadata is merged recursively and ingredients are merged by item_id.
+func (s *menuService) PatchMenuItemById(ctx context.Context, id int64, patch dto.MenuItemPatch, ifMatch string) error {
+	const op = "service.PatchMenuItemById"
+
+	var added []entity.MenuIngredient
+	for _, ing := range patch.Ingredients.Value {
+		if !ing.Quantity.Null {
+			added = append(added, entity.MenuIngredient{ItemID: *ing.ItemID})
 		}
+	}
+	if err := s.checkIngredientsExist(ctx, added); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-		if req.Price != nil {
-			if item.Price != *req.Price {
-				updated = true
-				item.Price = *req.Price
-			}
+	return s.menuRepo.UpdateByID(ctx, int64(id), func(item *entity.MenuItem) (updated bool, err error) {
+		if ifMatch != "" && !utils.MatchesETag(ifMatch, utils.ETag(item.ID, item.UpdatedAt)) {
+			return false, store.ErrPreconditionFailed
 		}
 
-		if req.Categories != nil {
-			if !testEq(item.Categories, *req.Categories) {
-				updated = true
-				item.Categories = *req.Categories
+		if patch.Name.Set {
+			item.Name = patch.Name.Value
+		}
+		if patch.Description.Set {
+			item.Description = patch.Description.Value
+		}
+		if patch.Price.Set {
+			item.Price = patch.Price.Value
+		}
+		if patch.Categories.Set {
+			item.Categories = nonNilStrings(patch.Categories.Value)
+		}
+		if patch.Allergens.Set {
+			item.Allergens = nonNilStrings(patch.Allergens.Value)
+		}
+		if patch.Metadata.Null {
+			item.Metadata = entity.JSONB{}
+		} else if patch.Metadata.Set {
+			item.Metadata = dto.MergePatch(item.Metadata, patch.Metadata.Value)
+		}
+		if patch.Ingredients.Set {
+			item.Ingredients = mergeIngredients(item.Ingredients, patch.Ingredients.Value)
+			if len(item.Ingredients) == 0 {
+				return false, fmt.Errorf("at least one ingredient is required: %w", store.ErrInvalidInput)
 			}
 		}
 
-		if req.Allergens != nil {
-			if !testEq(item.Allergens, *req.Allergens) {
-				updated = true
-				item.Allergens = *req.Allergens
+		item.UpdatedAt = time.Now()
+		return true, nil
+	})
+}
+
+func (s *menuService) ImportMenuItems(ctx context.Context, items []entity.MenuItem) (entity.ImportResult, error) {
+	const op = "service.ImportMenuItems"
+
+	result, err := s.menuRepo.ImportMenuItems(ctx, items)
+	if err != nil {
+		return entity.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+func (s *menuService) ExportMenuItems(ctx context.Context, fn func(item entity.MenuItem) error) error {
+	const op = "service.ExportMenuItems"
+
+	if err := s.menuRepo.ExportMenuItems(ctx, fn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// checkIngredientsExist makes sure every referenced inventory item exists
+// and fills in its name, unit and price.
+func (s *menuService) checkIngredientsExist(ctx context.Context, ingredients []entity.MenuIngredient) error {
+	for i, ing := range ingredients {
+		inventoryItem, err := s.inventoryRepo.GetInventoryItemById(ctx, ing.ItemID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("ingredient %d not found: %w", ing.ItemID, store.ErrInvalidInput)
 			}
+			return err
 		}
+		ingredients[i].Name = inventoryItem.ItemName
+		ingredients[i].Unit = inventoryItem.Unit
+		ingredients[i].Price = inventoryItem.Price
+	}
+	return nil
+}
 
-		if req.Metadata != nil {
-			updated = true
-			item.Metadata = *req.Metadata
-		}
+func mergeIngredients(current []entity.MenuIngredient, patch []dto.IngredientPatch) []entity.MenuIngredient {
+	merged := make([]entity.MenuIngredient, 0, len(current)+len(patch))
+	merged = append(merged, current...)
 
-		if req.Ingredients != nil {
-			updated = true
-			item.Ingredients = req.MapToEntity().Ingredients
+	for _, p := range patch {
+		idx := -1
+		for i, ing := range merged {
+			if ing.ItemID == *p.ItemID {
+				idx = i
+				break
+			}
 		}
 
-		if updated {
-			item.UpdatedAt = time.Now()
-			return
+		switch {
+		case p.Quantity.Null && idx >= 0:
+			merged = append(merged[:idx], merged[idx+1:]...)
+		case p.Quantity.Null:
+			// removing an ingredient the item does not have is a no-op
+		case idx >= 0:
+			merged[idx].Quantity = p.Quantity.Value
+		default:
+			merged = append(merged, entity.MenuIngredient{ItemID: *p.ItemID, Quantity: p.Quantity.Value})
 		}
+	}
+	return merged
+}
 
-		err = fmt.Errorf("no fields were updated")
-		return
-	})
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
 }
 
 func (s *menuService) DeleteMenuItemById(ctx context.Context, id int64) error {
@@ -171,15 +253,3 @@ func (s *menuService) DeleteMenuItemById(ctx context.Context, id int64) error {
 	}
 	return nil
 }
-
-func testEq(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
-}
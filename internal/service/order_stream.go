@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events for it are dropped.
+const subscriberBuffer = 32
+
+type OrderEventSource interface {
+	Listen(ctx context.Context, fn func(entity.OrderEvent)) error
+}
+
+// OrderStream fans order events from the database out to every connected
+// subscriber, attaching the current state of the order to each event.
+type OrderStream struct {
+	source    OrderEventSource
+	orderRepo OrderRepository
+	logger    *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan entity.OrderEvent]struct{}
+}
+
+func NewOrderStream(source OrderEventSource, orderRepo OrderRepository, logger *slog.Logger) *OrderStream {
+	return &OrderStream{
+		source:      source,
+		orderRepo:   orderRepo,
+		logger:      logger,
+		subscribers: make(map[chan entity.OrderEvent]struct{}),
+	}
+}
+
+// Run delivers events until ctx is cancelled. When it returns, every
+// subscriber channel is closed so that open streams can end.
+func (s *OrderStream) Run(ctx context.Context) error {
+	const op = "service.OrderStream.Run"
+	defer s.closeSubscribers()
+
+	err := s.source.Listen(ctx, func(event entity.OrderEvent) {
+		s.publish(ctx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Subscribe registers a new subscriber. The returned func must be called
+// once the subscriber goes away.
+func (s *OrderStream) Subscribe() (<-chan entity.OrderEvent, func()) {
+	ch := make(chan entity.OrderEvent, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *OrderStream) closeSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+func (s *OrderStream) publish(ctx context.Context, event entity.OrderEvent) {
+	s.mu.Lock()
+	listeners := len(s.subscribers)
+	s.mu.Unlock()
+	if listeners == 0 {
+		return
+	}
+
+	order, err := s.orderRepo.GetOrderById(ctx, strconv.FormatInt(event.OrderID, 10))
+	if err != nil {
+		// the order may be gone already, the event itself is still worth sending
+		s.logger.Warn("failed to load order for event", "order_id", event.OrderID, "error", err.Error())
+	} else {
+		event.Order = &order
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			s.logger.Warn("dropping order event for slow subscriber", "order_id", event.OrderID, "type", event.Type)
+		}
+	}
+}
@@ -17,6 +17,7 @@ type OrderRepository interface {
 	DeleteOrderById(ctx context.Context, OrderId string) error
 	CloseOrderById(ctx context.Context, OrderId string) error
 	GetNumberOfOrderedItems(ctx context.Context, startDate, endDate string) (map[string]int, error)
+	GetKitchenQueue(ctx context.Context) ([]entity.Order, error)
 }
 
 type OrderService struct {
@@ -149,3 +150,12 @@ func (s *OrderService) GetNumberOfOrderedItems(ctx context.Context, startDate, e
 
 	return OrderMap, nil
 }
+
+func (s *OrderService) GetKitchenQueue(ctx context.Context) ([]entity.Order, error) {
+	const op = "service.GetKitchenQueue"
+	orders, err := s.orderRepo.GetKitchenQueue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return orders, nil
+}
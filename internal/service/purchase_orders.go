@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+)
+
+type PurchaseOrderRepository interface {
+	CreatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error)
+	GetPurchaseOrders(ctx context.Context, status string) ([]entity.PurchaseOrder, error)
+	GetPurchaseOrderById(ctx context.Context, id int64) (entity.PurchaseOrder, error)
+	UpdatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error)
+	DeletePurchaseOrder(ctx context.Context, id int64) error
+	SendPurchaseOrder(ctx context.Context, id int64) (entity.PurchaseOrder, error)
+	ReceivePurchaseOrder(ctx context.Context, id int64, receipts []entity.PurchaseOrderReceipt) (entity.PurchaseOrder, error)
+}
+
+type PurchaseOrderService struct {
+	repo      PurchaseOrderRepository
+	suppliers SupplierRepository
+}
+
+func NewPurchaseOrderService(repo PurchaseOrderRepository, suppliers SupplierRepository) *PurchaseOrderService {
+	return &PurchaseOrderService{repo, suppliers}
+}
+
+func (s *PurchaseOrderService) CreatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error) {
+	const op = "service.CreatePurchaseOrder"
+
+	if err := s.applyPriceList(ctx, &po); err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	created, err := s.repo.CreatePurchaseOrder(ctx, po)
+	if err != nil {
+		return created, fmt.Errorf("%s: %w", op, err)
+	}
+	return created, nil
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrders(ctx context.Context, status string) ([]entity.PurchaseOrder, error) {
+	const op = "service.GetPurchaseOrders"
+	orders, err := s.repo.GetPurchaseOrders(ctx, status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return orders, nil
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrderById(ctx context.Context, id int64) (entity.PurchaseOrder, error) {
+	const op = "service.GetPurchaseOrderById"
+	po, err := s.repo.GetPurchaseOrderById(ctx, id)
+	if err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+// UpdatePurchaseOrder replaces a draft. The supplier cannot change.
+func (s *PurchaseOrderService) UpdatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error) {
+	const op = "service.UpdatePurchaseOrder"
+
+	current, err := s.repo.GetPurchaseOrderById(ctx, po.ID)
+	if err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	po.SupplierID = current.SupplierID
+	if err := s.applyPriceList(ctx, &po); err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	updated, err := s.repo.UpdatePurchaseOrder(ctx, po)
+	if err != nil {
+		return updated, fmt.Errorf("%s: %w", op, err)
+	}
+	return updated, nil
+}
+
+func (s *PurchaseOrderService) DeletePurchaseOrder(ctx context.Context, id int64) error {
+	const op = "service.DeletePurchaseOrder"
+	if err := s.repo.DeletePurchaseOrder(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *PurchaseOrderService) SendPurchaseOrder(ctx context.Context, id int64) (entity.PurchaseOrder, error) {
+	const op = "service.SendPurchaseOrder"
+	po, err := s.repo.SendPurchaseOrder(ctx, id)
+	if err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+func (s *PurchaseOrderService) ReceivePurchaseOrder(ctx context.Context, id int64, receipts []entity.PurchaseOrderReceipt) (entity.PurchaseOrder, error) {
+	const op = "service.ReceivePurchaseOrder"
+	po, err := s.repo.ReceivePurchaseOrder(ctx, id, receipts)
+	if err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+// applyPriceList prices lines without a unit cost from the supplier's price
+// list and, when no date was given, expects delivery after the longest lead
+// time of the ordered items.
+func (s *PurchaseOrderService) applyPriceList(ctx context.Context, po *entity.PurchaseOrder) error {
+	supplier, err := s.suppliers.GetSupplierById(ctx, po.SupplierID)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("%w: supplier %d does not exist", store.ErrInvalidInput, po.SupplierID)
+	}
+	if err != nil {
+		return err
+	}
+
+	leadTime, listed := 0, false
+	for i := range po.Lines {
+		line := &po.Lines[i]
+		item, ok := supplier.Item(line.InventoryID)
+		if !ok {
+			if line.UnitCost == 0 {
+				return fmt.Errorf("%w: %s does not list inventory item %d, give a unit_cost", store.ErrInvalidInput, supplier.Name, line.InventoryID)
+			}
+			continue
+		}
+		if line.UnitCost == 0 {
+			line.UnitCost = item.UnitPrice
+		}
+		leadTime, listed = max(leadTime, item.LeadTimeDays), true
+	}
+
+	if po.ExpectedAt == nil && listed {
+		expected := time.Now().AddDate(0, 0, leadTime)
+		po.ExpectedAt = &expected
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// The Export methods stream report rows to fn as the store reads them, for
+// exports too large to build in memory. Rows come out flat: one per period
+// and breakdown key, with empty periods filled in.
+
+// ExportTotalSales streams the overall sales followed by every breakdown of
+// q. Breakdown is empty for the overall rows.
+func (s *ReportService) ExportTotalSales(ctx context.Context, q entity.ReportQuery, fn func(breakdown string, row entity.SalesRow) error) error {
+	const op = "service.ExportTotalSales"
+
+	starts, err := periodStarts(q.From, q.To, q.GroupBy)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, breakdown := range append([]string{""}, q.Breakdowns...) {
+		filler := &gapFiller[entity.SalesRow]{
+			starts: starts,
+			emit:   func(row entity.SalesRow) error { return fn(breakdown, row) },
+			bucket: func(row entity.SalesRow) time.Time { return row.Bucket },
+			key:    func(row entity.SalesRow) string { return row.Key },
+			blank: func(like entity.SalesRow, start time.Time) entity.SalesRow {
+				return entity.SalesRow{Bucket: start, Key: like.Key, Label: like.Label}
+			},
+		}
+		filter := reportFilter(q, breakdown)
+		filter.KeyFirst = true
+		if err := s.repo.GetTotalSales(ctx, filter, filler.add); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		// the overall series is complete even without a single sale
+		if err := filler.finish(breakdown == ""); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// ExportPopularItems streams the ranked items of q: overall, then per period
+// when grouped, then the same for every breakdown. Rank restarts at 1 in
+// every period and breakdown key.
+func (s *ReportService) ExportPopularItems(ctx context.Context, q entity.ReportQuery, fn func(breakdown string, rank int, row entity.PopularItemRow) error) error {
+	const op = "service.ExportPopularItems"
+
+	if _, err := periodStarts(q.From, q.To, q.GroupBy); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var filters []entity.ReportFilter
+	for _, breakdown := range append([]string{""}, q.Breakdowns...) {
+		filter := reportFilter(q, breakdown)
+		filter.GroupBy = ""
+		filters = append(filters, filter)
+		if q.GroupBy != "" {
+			filters = append(filters, reportFilter(q, breakdown))
+		}
+	}
+
+	for _, filter := range filters {
+		var rank int
+		var prev entity.PopularItemRow
+		err := s.repo.GetPopularItems(ctx, filter, func(row entity.PopularItemRow) error {
+			if !row.Bucket.Equal(prev.Bucket) || row.Key != prev.Key {
+				rank = 0
+			}
+			rank++
+			prev = row
+			return fn(filter.Breakdown, rank, row)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// ExportTotalItemsByPeriod streams the buckets of GetTotalItemsByPeriod or,
+// with byMenuItem, the buckets of every menu item that sold in the range.
+func (s *ReportService) ExportTotalItemsByPeriod(ctx context.Context, period string, month int, year int, byMenuItem bool, fn func(row entity.ItemsRow, label string) error) error {
+	const op = "service.ExportTotalItemsByPeriod"
+
+	filter, err := itemsPeriodFilter(period, month, year)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	starts, err := periodStarts(filter.From, filter.To, period)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if byMenuItem {
+		filter.Breakdown = entity.BreakdownMenuItem
+	}
+	filter.KeyFirst = true
+
+	filler := &gapFiller[entity.ItemsRow]{
+		starts: starts,
+		emit:   func(row entity.ItemsRow) error { return fn(row, periodLabel(row.Bucket, period)) },
+		bucket: func(row entity.ItemsRow) time.Time { return row.Bucket },
+		key:    func(row entity.ItemsRow) string { return row.Key },
+		blank: func(like entity.ItemsRow, start time.Time) entity.ItemsRow {
+			return entity.ItemsRow{Bucket: start, Key: like.Key, Label: like.Label}
+		},
+	}
+	if err := s.repo.GetItemsByPeriod(ctx, filter, filler.add); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := filler.finish(!byMenuItem); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ExportFilterSearch streams every match of the query, ignoring its page:
+// the menu items, then the orders, inventory items and customers, each most
+// relevant first.
+func (s *ReportService) ExportFilterSearch(ctx context.Context, query entity.SearchQuery, fn func(hit entity.SearchHit) error) error {
+	const op = "service.ExportFilterSearch"
+
+	query.Limit, query.Offset = 0, 0
+	var err error
+	if query.Searches(entity.SearchMenu) {
+		_, err = s.repo.SearchMenuItems(ctx, query, func(item entity.SearchMenuItem) error { return fn(menuItemHit(item)) })
+	}
+	if err == nil && query.Searches(entity.SearchOrders) {
+		_, err = s.repo.SearchOrders(ctx, query, func(order entity.SearchOrder) error { return fn(orderHit(order)) })
+	}
+	if err == nil && query.Searches(entity.SearchInventory) {
+		_, err = s.repo.SearchInventory(ctx, query, func(item entity.SearchInventoryItem) error { return fn(inventoryItemHit(item)) })
+	}
+	if err == nil && query.Searches(entity.SearchCustomers) {
+		_, err = s.repo.SearchCustomers(ctx, query, func(c entity.SearchCustomer) error { return fn(customerHit(c)) })
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ExportOrderedItems streams how many of every menu item were ordered
+// between startDate and endDate, by name.
+func (s *ReportService) ExportOrderedItems(ctx context.Context, startDate, endDate time.Time, fn func(name string, quantity int) error) error {
+	const op = "service.ExportOrderedItems"
+
+	if err := s.repo.GetOrderedItems(ctx, startDate, endDate, fn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// gapFiller passes rows sorted by key and bucket on to emit, inserting a
+// blank row for every period start a key has no row for. Without periods
+// it only passes rows on.
+type gapFiller[T any] struct {
+	starts []time.Time
+	emit   func(T) error
+	bucket func(T) time.Time
+	key    func(T) string
+	blank  func(like T, start time.Time) T
+
+	last T
+	seen bool
+	next int
+}
+
+func (g *gapFiller[T]) add(row T) error {
+	if g.seen && g.key(row) != g.key(g.last) {
+		if err := g.fill(); err != nil {
+			return err
+		}
+		g.next = 0
+	}
+	g.last, g.seen = row, true
+
+	bucket := g.bucket(row)
+	for ; g.next < len(g.starts) && g.starts[g.next].Before(bucket); g.next++ {
+		if err := g.emit(g.blank(row, g.starts[g.next])); err != nil {
+			return err
+		}
+	}
+	if g.next < len(g.starts) && g.starts[g.next].Equal(bucket) {
+		g.next++
+	}
+	return g.emit(row)
+}
+
+// finish fills the periods after the last row. With always set, a series
+// of blank rows is emitted even if no row came at all; ungrouped, that is a
+// single blank row.
+func (g *gapFiller[T]) finish(always bool) error {
+	if !g.seen {
+		if !always {
+			return nil
+		}
+		if g.starts == nil {
+			var zero T
+			return g.emit(zero)
+		}
+	}
+	return g.fill()
+}
+
+func (g *gapFiller[T]) fill() error {
+	for ; g.next < len(g.starts); g.next++ {
+		if err := g.emit(g.blank(g.last, g.starts[g.next])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
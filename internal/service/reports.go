@@ -4,118 +4,551 @@ import (
 	"context"
 	"fmt"
 	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type ReportRepository interface {
-	GetPopularItems(ctx context.Context) ([]entity.PopularItem, error)
-	GetTotalSales(ctx context.Context) (float64, error)
-	SearchMenuItems(ctx context.Context, query string, minPrice, maxPrice float64) ([]entity.SearchMenuItem, error)
-	SearchOrders(ctx context.Context, query string, minPrice, maxPrice float64) ([]entity.SearchOrder, error)
-	GetTotalItemsByDay(ctx context.Context, month int, year int) (map[int]int, error)
-	GetTotalItemsByMonth(ctx context.Context, year int) (map[int]int, error)
-	GetOrderedItemsReport(ctx context.Context, startDate, endDate time.Time) (entity.NumberOfOrderedItemsByPeriod, error)
+	GetPopularItems(ctx context.Context, filter entity.ReportFilter, fn func(entity.PopularItemRow) error) error
+	GetTotalSales(ctx context.Context, filter entity.ReportFilter, fn func(entity.SalesRow) error) error
+	SearchMenuItems(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchMenuItem) error) (int, error)
+	SearchOrders(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchOrder) error) (int, error)
+	SearchInventory(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchInventoryItem) error) (int, error)
+	SearchCustomers(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchCustomer) error) (int, error)
+	ReindexOrders(ctx context.Context) (int64, error)
+	Suggest(ctx context.Context, text string, limit int) ([]entity.SearchSuggestion, error)
+	GetItemsByPeriod(ctx context.Context, filter entity.ReportFilter, fn func(entity.ItemsRow) error) error
+	GetOrderedItems(ctx context.Context, startDate, endDate time.Time, fn func(name string, quantity int) error) error
+	GetInventoryUsage(ctx context.Context, from, to time.Time) ([]entity.InventoryUsage, error)
+	GetCostedItems(ctx context.Context) ([]entity.CostedItem, error)
+	GetInventoryLedger(ctx context.Context, to time.Time, fn func(entity.LedgerEntry) error) error
 }
 
 type ReportService struct {
 	repo ReportRepository
+	// valuation is the costing method used when a report does not ask for one.
+	valuation string
 }
 
-func NewReportService(repo ReportRepository) *ReportService {
-	return &ReportService{repo}
+func NewReportService(repo ReportRepository, valuation string) *ReportService {
+	return &ReportService{repo, valuation}
 }
 
-func (s *ReportService) GetPopularItems(ctx context.Context) ([]entity.PopularItem, error) {
-	const op = "service.GetPopularItems"
-	popularItems, err := s.repo.GetPopularItems(ctx)
+// maxReportBuckets keeps a fine grouping over a long range from producing
+// an unbounded response.
+const maxReportBuckets = 5000
+
+// GetTotalSales sums completed orders in the query range, optionally per
+// period and per breakdown value. Periods without sales are reported as zero.
+func (s *ReportService) GetTotalSales(ctx context.Context, q entity.ReportQuery) (entity.SalesReport, error) {
+	const op = "service.GetTotalSales"
+
+	starts, err := periodStarts(q.From, q.To, q.GroupBy)
+	if err != nil {
+		return entity.SalesReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := entity.SalesReport{From: optionalTime(q.From), To: q.To, GroupBy: q.GroupBy}
+	rows, err := collect(ctx, s.repo.GetTotalSales, reportFilter(q, ""))
 	if err != nil {
-		return []entity.PopularItem{}, fmt.Errorf("%s: %w", op, err)
+		return entity.SalesReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, row := range rows {
+		report.TotalSales += row.Revenue
+		report.OrderCount += row.OrderCount
+	}
+	if q.GroupBy != "" {
+		report.Buckets = salesBuckets(starts, rows)
 	}
 
-	return popularItems, nil
+	for _, breakdown := range q.Breakdowns {
+		rows, err := collect(ctx, s.repo.GetTotalSales, reportFilter(q, breakdown))
+		if err != nil {
+			return entity.SalesReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if report.Breakdowns == nil {
+			report.Breakdowns = make(map[string][]entity.SalesGroup)
+		}
+		report.Breakdowns[breakdown] = salesGroups(breakdown, starts, q.GroupBy != "", rows)
+	}
+
+	return report, nil
 }
 
-func (s *ReportService) GetTotalSales(ctx context.Context) (float64, error) {
-	const op = "service.GetTotalSales"
-	totalSales, err := s.repo.GetTotalSales(ctx)
+// GetPopularItems returns the best selling items in the query range, plus
+// the top items of every period and breakdown value when asked for.
+func (s *ReportService) GetPopularItems(ctx context.Context, q entity.ReportQuery) (entity.PopularItemsReport, error) {
+	const op = "service.GetPopularItems"
+
+	starts, err := periodStarts(q.From, q.To, q.GroupBy)
 	if err != nil {
-		return -1, fmt.Errorf("%s: %w", op, err)
+		return entity.PopularItemsReport{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return totalSales, nil
-}
 
-func (s *ReportService) GetTotalItemsByPeriod(ctx context.Context, period string, month int, year int) (entity.TotalItemsByPeriod, error) {
-	const op = "service.GetTotalItemsByPeriod"
-	result := entity.TotalItemsByPeriod{}
+	report := entity.PopularItemsReport{From: optionalTime(q.From), To: q.To, GroupBy: q.GroupBy}
 
-	switch period {
-	case "day":
-		dayCounts, err := s.repo.GetTotalItemsByDay(ctx, month, year)
+	overall := reportFilter(q, "")
+	overall.GroupBy = ""
+	rows, err := collect(ctx, s.repo.GetPopularItems, overall)
+	if err != nil {
+		return entity.PopularItemsReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	report.Items = popularItems(rows)
+
+	if q.GroupBy != "" {
+		rows, err := collect(ctx, s.repo.GetPopularItems, reportFilter(q, ""))
 		if err != nil {
-			return result, fmt.Errorf("%s: %w", op, err)
+			return entity.PopularItemsReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		report.Buckets = popularItemsBuckets(starts, rows)
+	}
+
+	for _, breakdown := range q.Breakdowns {
+		filter := reportFilter(q, breakdown)
+		filter.GroupBy = ""
+		totals, err := collect(ctx, s.repo.GetPopularItems, filter)
+		if err != nil {
+			return entity.PopularItemsReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		var periods []entity.PopularItemRow
+		if q.GroupBy != "" {
+			if periods, err = collect(ctx, s.repo.GetPopularItems, reportFilter(q, breakdown)); err != nil {
+				return entity.PopularItemsReport{}, fmt.Errorf("%s: %w", op, err)
+			}
 		}
+		if report.Breakdowns == nil {
+			report.Breakdowns = make(map[string][]entity.PopularItemsGroup)
+		}
+		report.Breakdowns[breakdown] = popularItemsGroups(starts, q.GroupBy != "", totals, periods)
+	}
+
+	return report, nil
+}
+
+// collect gathers the rows a streaming report query yields.
+func collect[T any](ctx context.Context, query func(context.Context, entity.ReportFilter, func(T) error) error, filter entity.ReportFilter) ([]T, error) {
+	var rows []T
+	err := query(ctx, filter, func(row T) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func reportFilter(q entity.ReportQuery, breakdown string) entity.ReportFilter {
+	return entity.ReportFilter{
+		From:      q.From,
+		To:        q.To,
+		GroupBy:   q.GroupBy,
+		Breakdown: breakdown,
+		Limit:     q.Limit,
+	}
+}
 
-		result.Period = "day"
-		result.Month = time.Month(month).String()
-		result.Year = year
-		result.OrderedItems = make([]map[string]int, 0)
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// truncatePeriod returns the start of the UTC period containing t. Weeks
+// start on Monday, as with Postgres date_trunc.
+func truncatePeriod(t time.Time, groupBy string) time.Time {
+	t = t.UTC()
+	switch groupBy {
+	case entity.GroupByHour:
+		return t.Truncate(time.Hour)
+	case entity.GroupByDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case entity.GroupByWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case entity.GroupByMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
 
-		for day, count := range dayCounts {
-			result.OrderedItems = append(result.OrderedItems,
-				map[string]int{strconv.Itoa(day): count})
+func nextPeriod(t time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case entity.GroupByHour:
+		return t.Add(time.Hour)
+	case entity.GroupByDay:
+		return t.AddDate(0, 0, 1)
+	case entity.GroupByWeek:
+		return t.AddDate(0, 0, 7)
+	case entity.GroupByMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t
+}
+
+// periodStarts lists the start of every period overlapping [from, to). It
+// returns nil when the report is not grouped.
+func periodStarts(from, to time.Time, groupBy string) ([]time.Time, error) {
+	switch groupBy {
+	case "":
+		return nil, nil
+	case entity.GroupByHour, entity.GroupByDay, entity.GroupByWeek, entity.GroupByMonth:
+	default:
+		return nil, fmt.Errorf("%w: unknown group_by %q", store.ErrInvalidInput, groupBy)
+	}
+	if from.IsZero() {
+		return nil, fmt.Errorf("%w: from is required with group_by", store.ErrInvalidInput)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", store.ErrInvalidInput)
+	}
+
+	var starts []time.Time
+	for t := truncatePeriod(from, groupBy); t.Before(to); t = nextPeriod(t, groupBy) {
+		if len(starts) == maxReportBuckets {
+			return nil, fmt.Errorf("%w: range has more than %d %s periods", store.ErrInvalidInput, maxReportBuckets, groupBy)
 		}
+		starts = append(starts, t)
+	}
+	return starts, nil
+}
 
-	case "month":
-		monthCounts, err := s.repo.GetTotalItemsByMonth(ctx, year)
-		if err != nil {
-			return result, fmt.Errorf("%s: %w", op, err)
+func salesBuckets(starts []time.Time, rows []entity.SalesRow) []entity.SalesBucket {
+	index := make(map[time.Time]int, len(starts))
+	buckets := make([]entity.SalesBucket, len(starts))
+	for i, start := range starts {
+		index[start] = i
+		buckets[i].PeriodStart = start
+	}
+	for _, row := range rows {
+		if i, ok := index[row.Bucket]; ok {
+			buckets[i].TotalSales += row.Revenue
+			buckets[i].OrderCount += row.OrderCount
 		}
+	}
+	return buckets
+}
 
-		result.Period = "month"
-		result.Year = year
-		result.OrderedItems = make([]map[string]int, 0)
+// salesGroups folds breakdown rows into one group per key, ordered by
+// revenue. Payment methods are always all listed, even without sales.
+func salesGroups(breakdown string, starts []time.Time, grouped bool, rows []entity.SalesRow) []entity.SalesGroup {
+	var groups []entity.SalesGroup
+	byKey := make(map[string][]entity.SalesRow)
+	add := func(key, label string) {
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = []entity.SalesRow{}
+			groups = append(groups, entity.SalesGroup{Key: key, Label: label})
+		}
+	}
+	if breakdown == entity.BreakdownPaymentMethod {
+		for _, m := range []entity.PaymentMethod{entity.PaymentCash, entity.PaymentCard, entity.PaymentOnline} {
+			add(m.String(), m.String())
+		}
+	}
+	for _, row := range rows {
+		add(row.Key, row.Label)
+		byKey[row.Key] = append(byKey[row.Key], row)
+	}
 
-		for monthNum, count := range monthCounts {
-			monthName := strings.ToLower(time.Month(monthNum).String())
-			result.OrderedItems = append(result.OrderedItems,
-				map[string]int{monthName: count})
+	for i := range groups {
+		for _, row := range byKey[groups[i].Key] {
+			groups[i].TotalSales += row.Revenue
+			groups[i].OrderCount += row.OrderCount
 		}
+		if grouped {
+			groups[i].Buckets = salesBuckets(starts, byKey[groups[i].Key])
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].TotalSales > groups[j].TotalSales
+	})
+	return groups
+}
 
-	default:
-		return result, fmt.Errorf("%s: invalid period", op)
+func popularItems(rows []entity.PopularItemRow) []entity.PopularItem {
+	items := make([]entity.PopularItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.Item)
 	}
+	return items
+}
+
+func popularItemsBuckets(starts []time.Time, rows []entity.PopularItemRow) []entity.PopularItemsBucket {
+	index := make(map[time.Time]int, len(starts))
+	buckets := make([]entity.PopularItemsBucket, len(starts))
+	for i, start := range starts {
+		index[start] = i
+		buckets[i] = entity.PopularItemsBucket{PeriodStart: start, Items: []entity.PopularItem{}}
+	}
+	for _, row := range rows {
+		if i, ok := index[row.Bucket]; ok {
+			buckets[i].Items = append(buckets[i].Items, row.Item)
+		}
+	}
+	return buckets
+}
+
+// popularItemsGroups builds one group per breakdown key from the ungrouped
+// rows in totals and, when grouped, the per-period rows in periods. Groups
+// keep the store's key order.
+func popularItemsGroups(starts []time.Time, grouped bool, totals, periods []entity.PopularItemRow) []entity.PopularItemsGroup {
+	var groups []entity.PopularItemsGroup
+	index := make(map[string]int)
+	for _, row := range totals {
+		i, ok := index[row.Key]
+		if !ok {
+			i = len(groups)
+			index[row.Key] = i
+			groups = append(groups, entity.PopularItemsGroup{Key: row.Key, Label: row.Label})
+		}
+		groups[i].Items = append(groups[i].Items, row.Item)
+	}
+	if !grouped {
+		return groups
+	}
+
+	byKey := make(map[string][]entity.PopularItemRow)
+	for _, row := range periods {
+		byKey[row.Key] = append(byKey[row.Key], row)
+	}
+	for i := range groups {
+		groups[i].Buckets = popularItemsBuckets(starts, byKey[groups[i].Key])
+	}
+	return groups
+}
+
+// GetTotalItemsByPeriod counts ordered items for every day of the month
+// (period "day") or every month of the year (period "month"). With byMenuItem
+// the counts are also broken down per menu item, best sellers first.
+func (s *ReportService) GetTotalItemsByPeriod(ctx context.Context, period string, month int, year int, byMenuItem bool) (entity.TotalItemsByPeriod, error) {
+	const op = "service.GetTotalItemsByPeriod"
+	result := entity.TotalItemsByPeriod{Period: period, Year: year}
+
+	filter, err := itemsPeriodFilter(period, month, year)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+	if period == entity.GroupByDay {
+		result.Month = strings.ToLower(time.Month(month).String())
+	}
+	starts, err := periodStarts(filter.From, filter.To, period)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := collect(ctx, s.repo.GetItemsByPeriod, filter)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+	result.Buckets = periodBuckets(starts, period, rows)
+	for _, bucket := range result.Buckets {
+		result.ItemCount += bucket.ItemCount
+		result.Revenue += bucket.Revenue
+	}
+
+	if !byMenuItem {
+		return result, nil
+	}
+
+	filter.Breakdown = entity.BreakdownMenuItem
+	rows, err = collect(ctx, s.repo.GetItemsByPeriod, filter)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", op, err)
+	}
+	byKey := make(map[string][]entity.ItemsRow)
+	result.MenuItems = []entity.MenuItemPeriods{}
+	for _, row := range rows {
+		if _, ok := byKey[row.Key]; !ok {
+			id, err := strconv.ParseInt(row.Key, 10, 64)
+			if err != nil {
+				return result, fmt.Errorf("%s: menu item id %q: %w", op, row.Key, err)
+			}
+			result.MenuItems = append(result.MenuItems, entity.MenuItemPeriods{MenuItemID: id, Name: row.Label})
+		}
+		byKey[row.Key] = append(byKey[row.Key], row)
+	}
+	for i := range result.MenuItems {
+		item := &result.MenuItems[i]
+		item.Buckets = periodBuckets(starts, period, byKey[strconv.FormatInt(item.MenuItemID, 10)])
+		for _, bucket := range item.Buckets {
+			item.ItemCount += bucket.ItemCount
+			item.Revenue += bucket.Revenue
+		}
+	}
+	sort.SliceStable(result.MenuItems, func(i, j int) bool {
+		a, b := result.MenuItems[i], result.MenuItems[j]
+		if a.ItemCount != b.ItemCount {
+			return a.ItemCount > b.ItemCount
+		}
+		return a.Name < b.Name
+	})
 
 	return result, nil
 }
 
-func (s *ReportService) GetFilterSearch(ctx context.Context, search string, filter string, minPrice float64, maxPrice float64) (entity.SearchResult, error) {
+// itemsPeriodFilter covers the month of a day report or the year of a
+// month report.
+func itemsPeriodFilter(period string, month int, year int) (entity.ReportFilter, error) {
+	filter := entity.ReportFilter{GroupBy: period}
+	switch period {
+	case entity.GroupByDay:
+		if month < 1 || month > 12 {
+			return filter, fmt.Errorf("%w: invalid month %d", store.ErrInvalidInput, month)
+		}
+		filter.From = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		filter.To = filter.From.AddDate(0, 1, 0)
+	case entity.GroupByMonth:
+		filter.From = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		filter.To = filter.From.AddDate(1, 0, 0)
+	default:
+		return filter, fmt.Errorf("%w: invalid period %q", store.ErrInvalidInput, period)
+	}
+	return filter, nil
+}
+
+// periodBuckets zero-fills rows into one bucket per period start.
+func periodBuckets(starts []time.Time, period string, rows []entity.ItemsRow) []entity.PeriodBucket {
+	index := make(map[time.Time]int, len(starts))
+	buckets := make([]entity.PeriodBucket, len(starts))
+	for i, start := range starts {
+		index[start] = i
+		buckets[i] = entity.PeriodBucket{PeriodStart: start, Label: periodLabel(start, period)}
+	}
+	for _, row := range rows {
+		if i, ok := index[row.Bucket]; ok {
+			buckets[i].ItemCount += row.ItemCount
+			buckets[i].Revenue += row.Revenue
+		}
+	}
+	return buckets
+}
+
+func periodLabel(start time.Time, period string) string {
+	switch period {
+	case entity.GroupByMonth:
+		return strings.ToLower(start.Month().String())
+	}
+	return start.Format("2006-01-02")
+}
+
+// GetFilterSearch returns a page of each kind of result the query looks
+// through, and the page's results of every kind merged by relevance.
+func (s *ReportService) GetFilterSearch(ctx context.Context, query entity.SearchQuery) (entity.SearchResult, error) {
 	const op = "service.GetFilterSearch"
-	var results entity.SearchResult
 
-	var menuResults []entity.SearchMenuItem
-	var orderResults []entity.SearchOrder
+	results := entity.SearchResult{PageSize: query.Limit, Results: make([]entity.SearchHit, 0)}
+	if query.Limit > 0 {
+		results.Page = query.Offset/query.Limit + 1
+	}
+
 	var err error
-	if filter == "menu" || filter == "menu,orders" || filter == "" {
-		menuResults, err = s.repo.SearchMenuItems(ctx, search, minPrice, maxPrice)
+	if query.Searches(entity.SearchMenu) {
+		results.MenuItemsTotal, err = s.repo.SearchMenuItems(ctx, query, func(item entity.SearchMenuItem) error {
+			results.MenuItems = append(results.MenuItems, item)
+			results.Results = append(results.Results, menuItemHit(item))
+			return nil
+		})
+		if err != nil {
+			return entity.SearchResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if query.Searches(entity.SearchOrders) {
+		results.OrdersTotal, err = s.repo.SearchOrders(ctx, query, func(order entity.SearchOrder) error {
+			results.Orders = append(results.Orders, order)
+			results.Results = append(results.Results, orderHit(order))
+			return nil
+		})
 		if err != nil {
 			return entity.SearchResult{}, fmt.Errorf("%s: %w", op, err)
 		}
 	}
-	if filter == "orders" || filter == "menu,orders" || filter == "" {
-		orderResults, err = s.repo.SearchOrders(ctx, search, minPrice, maxPrice)
+	if query.Searches(entity.SearchInventory) {
+		results.InventoryTotal, err = s.repo.SearchInventory(ctx, query, func(item entity.SearchInventoryItem) error {
+			results.InventoryItems = append(results.InventoryItems, item)
+			results.Results = append(results.Results, inventoryItemHit(item))
+			return nil
+		})
+		if err != nil {
+			return entity.SearchResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if query.Searches(entity.SearchCustomers) {
+		results.CustomersTotal, err = s.repo.SearchCustomers(ctx, query, func(c entity.SearchCustomer) error {
+			results.Customers = append(results.Customers, c)
+			results.Results = append(results.Results, customerHit(c))
+			return nil
+		})
 		if err != nil {
 			return entity.SearchResult{}, fmt.Errorf("%s: %w", op, err)
 		}
 	}
 
-	results.MenuItems = menuResults
-	results.Orders = orderResults
-	results.Matches = len(menuResults) + len(orderResults)
+	// each kind comes most relevant first already, so a stable sort keeps
+	// ties in the order above
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		return results.Results[i].Relevance > results.Results[j].Relevance
+	})
+	results.Matches = results.MenuItemsTotal + results.OrdersTotal + results.InventoryTotal + results.CustomersTotal
 	return results, nil
 }
 
+func menuItemHit(item entity.SearchMenuItem) entity.SearchHit {
+	id := int64(item.ID)
+	return entity.SearchHit{Type: entity.HitMenuItem, ID: &id,
+		Name: item.Name, Amount: item.Price, Relevance: item.Relevance, Snippet: item.Snippet}
+}
+
+func orderHit(order entity.SearchOrder) entity.SearchHit {
+	id := int64(order.ID)
+	return entity.SearchHit{Type: entity.HitOrder, ID: &id,
+		Name: order.CustomerName, Amount: order.Total, Relevance: order.Relevance, Snippet: order.Snippet}
+}
+
+func inventoryItemHit(item entity.SearchInventoryItem) entity.SearchHit {
+	id := int64(item.ID)
+	return entity.SearchHit{Type: entity.HitInventoryItem, ID: &id,
+		Name: item.Name, Amount: item.Price, Relevance: item.Relevance, Snippet: item.Snippet}
+}
+
+func customerHit(c entity.SearchCustomer) entity.SearchHit {
+	return entity.SearchHit{Type: entity.HitCustomer,
+		Name: c.Name, Amount: c.TotalSpent, Relevance: c.Relevance, Snippet: c.Snippet}
+}
+
+// Suggest completes a partly typed menu item or customer name.
+func (s *ReportService) Suggest(ctx context.Context, text string, limit int) ([]entity.SearchSuggestion, error) {
+	const op = "service.Suggest"
+
+	suggestions, err := s.repo.Suggest(ctx, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return suggestions, nil
+}
+
+// ReindexSearch rebuilds the order search index and returns how many orders
+// it covered.
+func (s *ReportService) ReindexSearch(ctx context.Context) (int64, error) {
+	const op = "service.ReindexSearch"
+
+	n, err := s.repo.ReindexOrders(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
 func (s *ReportService) GetOrderedItemsReport(ctx context.Context, startDate, endDate time.Time) (entity.NumberOfOrderedItemsByPeriod, error) {
-	return s.repo.GetOrderedItemsReport(ctx, startDate, endDate)
+	const op = "service.GetOrderedItemsReport"
+
+	report := entity.NumberOfOrderedItemsByPeriod{OrderedItems: make(map[string]int)}
+	err := s.repo.GetOrderedItems(ctx, startDate, endDate, func(name string, quantity int) error {
+		report.OrderedItems[name] = quantity
+		return nil
+	})
+	if err != nil {
+		return entity.NumberOfOrderedItemsByPeriod{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return report, nil
 }
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestTruncatePeriod(t *testing.T) {
+	plusFive := time.FixedZone("UTC+5", 5*60*60)
+	tests := []struct {
+		name    string
+		t       time.Time
+		groupBy string
+		want    time.Time
+	}{
+		{"hour", time.Date(2024, 3, 5, 14, 59, 59, 0, time.UTC), entity.GroupByHour, date(2024, 3, 5, 14)},
+		{"day", date(2024, 3, 5, 23), entity.GroupByDay, date(2024, 3, 5, 0)},
+		{"day in UTC, not the local zone", time.Date(2024, 3, 5, 2, 0, 0, 0, plusFive), entity.GroupByDay, date(2024, 3, 4, 0)},
+		{"week from a Monday", date(2024, 3, 4, 9), entity.GroupByWeek, date(2024, 3, 4, 0)},
+		{"week from the Sunday before it", date(2024, 3, 3, 23), entity.GroupByWeek, date(2024, 2, 26, 0)},
+		{"week from a Sunday across a year", date(2023, 12, 31, 12), entity.GroupByWeek, date(2023, 12, 25, 0)},
+		{"week starting in the previous year", date(2025, 1, 1, 0), entity.GroupByWeek, date(2024, 12, 30, 0)},
+		{"month", date(2024, 1, 31, 18), entity.GroupByMonth, date(2024, 1, 1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncatePeriod(tt.t, tt.groupBy); !got.Equal(tt.want) {
+				t.Errorf("truncatePeriod(%v, %s) = %v, want %v", tt.t, tt.groupBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodStarts(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Time
+		groupBy  string
+		want     []time.Time
+		wantErr  string
+	}{
+		{
+			name: "not grouped", from: date(2024, 1, 1, 0), to: date(2024, 2, 1, 0),
+		},
+		{
+			name: "to is exclusive", from: date(2024, 1, 1, 0), to: date(2024, 1, 3, 0), groupBy: entity.GroupByDay,
+			want: []time.Time{date(2024, 1, 1, 0), date(2024, 1, 2, 0)},
+		},
+		{
+			name: "partial periods at both ends", from: date(2024, 1, 1, 12), to: date(2024, 1, 2, 1), groupBy: entity.GroupByDay,
+			want: []time.Time{date(2024, 1, 1, 0), date(2024, 1, 2, 0)},
+		},
+		{
+			name: "month rollover from the 31st", from: date(2024, 1, 31, 10), to: date(2024, 3, 1, 0), groupBy: entity.GroupByMonth,
+			want: []time.Time{date(2024, 1, 1, 0), date(2024, 2, 1, 0)},
+		},
+		{
+			name: "days across a month end", from: date(2024, 1, 30, 0), to: date(2024, 2, 2, 0), groupBy: entity.GroupByDay,
+			want: []time.Time{date(2024, 1, 30, 0), date(2024, 1, 31, 0), date(2024, 2, 1, 0)},
+		},
+		{
+			name: "weeks across a Sunday and Monday", from: date(2024, 3, 3, 8), to: date(2024, 3, 5, 0), groupBy: entity.GroupByWeek,
+			want: []time.Time{date(2024, 2, 26, 0), date(2024, 3, 4, 0)},
+		},
+		{
+			name: "hours", from: date(2024, 3, 3, 22), to: date(2024, 3, 4, 1), groupBy: entity.GroupByHour,
+			want: []time.Time{date(2024, 3, 3, 22), date(2024, 3, 3, 23), date(2024, 3, 4, 0)},
+		},
+		{
+			name: "unknown grouping", from: date(2024, 1, 1, 0), to: date(2024, 2, 1, 0), groupBy: "fortnight",
+			wantErr: `unknown group_by "fortnight"`,
+		},
+		{
+			name: "open start", to: date(2024, 2, 1, 0), groupBy: entity.GroupByDay,
+			wantErr: "from is required with group_by",
+		},
+		{
+			name: "empty range", from: date(2024, 2, 1, 0), to: date(2024, 2, 1, 0), groupBy: entity.GroupByDay,
+			wantErr: "from must be before to",
+		},
+		{
+			name: "too many periods", from: date(2024, 1, 1, 0), to: date(2025, 1, 1, 0), groupBy: entity.GroupByHour,
+			wantErr: "range has more than 5000 hour periods",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := periodStarts(tt.from, tt.to, tt.groupBy)
+			if tt.wantErr != "" {
+				if !errors.Is(err, store.ErrInvalidInput) || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Fatalf("periodStarts() error = %v, want invalid input %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("periodStarts() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("periodStarts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// exactly maxReportBuckets periods still fit
+	from := date(2024, 1, 1, 0)
+	starts, err := periodStarts(from, from.Add(maxReportBuckets*time.Hour), entity.GroupByHour)
+	if err != nil || len(starts) != maxReportBuckets {
+		t.Errorf("periodStarts() over %d hours = %d periods, %v", maxReportBuckets, len(starts), err)
+	}
+}
+
+func TestSalesBuckets(t *testing.T) {
+	starts := []time.Time{date(2024, 1, 1, 0), date(2024, 1, 2, 0), date(2024, 1, 3, 0)}
+	rows := []entity.SalesRow{
+		{Bucket: date(2024, 1, 3, 0), Revenue: 5, OrderCount: 1},
+		{Bucket: date(2024, 1, 1, 0), Revenue: 10, OrderCount: 2},
+		{Bucket: date(2024, 1, 3, 0), Revenue: 2.5, OrderCount: 1},
+		{Bucket: date(2024, 1, 9, 0), Revenue: 99, OrderCount: 9},
+	}
+	want := []entity.SalesBucket{
+		{PeriodStart: date(2024, 1, 1, 0), TotalSales: 10, OrderCount: 2},
+		{PeriodStart: date(2024, 1, 2, 0)},
+		{PeriodStart: date(2024, 1, 3, 0), TotalSales: 7.5, OrderCount: 2},
+	}
+	if got := salesBuckets(starts, rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("salesBuckets() = %+v, want %+v", got, want)
+	}
+}
+
+// itemsRepo answers GetItemsByPeriod with canned rows per breakdown.
+type itemsRepo struct {
+	ReportRepository
+
+	filters []entity.ReportFilter
+	rows    map[string][]entity.ItemsRow
+}
+
+func (r *itemsRepo) GetItemsByPeriod(ctx context.Context, filter entity.ReportFilter, fn func(entity.ItemsRow) error) error {
+	r.filters = append(r.filters, filter)
+	for _, row := range r.rows[filter.Breakdown] {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGetTotalItemsByPeriod(t *testing.T) {
+	repo := &itemsRepo{rows: map[string][]entity.ItemsRow{
+		// out of order, nothing sold in most months
+		"": {
+			{Bucket: date(2024, 11, 1, 0), ItemCount: 4, Revenue: 20},
+			{Bucket: date(2024, 2, 1, 0), ItemCount: 1, Revenue: 3},
+			{Bucket: date(2024, 5, 1, 0), ItemCount: 2, Revenue: 9},
+		},
+		entity.BreakdownMenuItem: {
+			{Bucket: date(2024, 11, 1, 0), Key: "7", Label: "Latte", ItemCount: 4, Revenue: 20},
+			{Bucket: date(2024, 5, 1, 0), Key: "3", Label: "Americano", ItemCount: 2, Revenue: 9},
+			{Bucket: date(2024, 2, 1, 0), Key: "7", Label: "Latte", ItemCount: 1, Revenue: 3},
+		},
+	}}
+	s := NewReportService(repo, entity.ValuationFIFO)
+
+	got, err := s.GetTotalItemsByPeriod(context.Background(), entity.GroupByMonth, 0, 2024, true)
+	if err != nil {
+		t.Fatalf("GetTotalItemsByPeriod() error = %v", err)
+	}
+	if f := repo.filters[0]; !f.From.Equal(date(2024, 1, 1, 0)) || !f.To.Equal(date(2025, 1, 1, 0)) {
+		t.Errorf("queried %v to %v, want the year 2024", f.From, f.To)
+	}
+	if got.ItemCount != 7 || got.Revenue != 32 {
+		t.Errorf("totals = %d items, %v revenue, want 7, 32", got.ItemCount, got.Revenue)
+	}
+
+	sold := map[time.Month]entity.PeriodBucket{
+		time.February: {ItemCount: 1, Revenue: 3},
+		time.May:      {ItemCount: 2, Revenue: 9},
+		time.November: {ItemCount: 4, Revenue: 20},
+	}
+	if len(got.Buckets) != 12 {
+		t.Fatalf("got %d buckets, want one per month", len(got.Buckets))
+	}
+	for i, b := range got.Buckets {
+		month := time.Month(i + 1)
+		want := sold[month]
+		want.PeriodStart = date(2024, month, 1, 0)
+		want.Label = strings.ToLower(month.String())
+		if b != want {
+			t.Errorf("bucket %d = %+v, want %+v", i, b, want)
+		}
+	}
+
+	if len(got.MenuItems) != 2 || got.MenuItems[0].Name != "Latte" || got.MenuItems[1].Name != "Americano" {
+		t.Fatalf("menu items = %+v, want Latte then Americano", got.MenuItems)
+	}
+	latte := got.MenuItems[0]
+	if latte.MenuItemID != 7 || latte.ItemCount != 5 || len(latte.Buckets) != 12 {
+		t.Errorf("Latte = %+v, want id 7, 5 items over 12 buckets", latte)
+	}
+	for i, b := range latte.Buckets {
+		if want := map[int]int{1: 1, 10: 4}[i]; b.ItemCount != want {
+			t.Errorf("Latte bucket %d has %d items, want %d", i, b.ItemCount, want)
+		}
+	}
+}
+
+func TestGetTotalItemsByPeriodDays(t *testing.T) {
+	repo := &itemsRepo{rows: map[string][]entity.ItemsRow{"": {
+		{Bucket: date(2024, 2, 29, 0), ItemCount: 2},
+		{Bucket: date(2024, 2, 1, 0), ItemCount: 1},
+	}}}
+	s := NewReportService(repo, entity.ValuationFIFO)
+
+	got, err := s.GetTotalItemsByPeriod(context.Background(), entity.GroupByDay, 2, 2024, false)
+	if err != nil {
+		t.Fatalf("GetTotalItemsByPeriod() error = %v", err)
+	}
+	if got.Month != "february" || len(got.Buckets) != 29 {
+		t.Fatalf("got %s with %d buckets, want february with 29", got.Month, len(got.Buckets))
+	}
+	for i, b := range got.Buckets {
+		want := map[int]int{0: 1, 28: 2}[i]
+		if !b.PeriodStart.Equal(date(2024, 2, i+1, 0)) || b.Label != date(2024, 2, i+1, 0).Format("2006-01-02") || b.ItemCount != want {
+			t.Errorf("bucket %d = %+v, want February %d with %d items", i, b, i+1, want)
+		}
+	}
+	if got.MenuItems != nil || len(repo.filters) != 1 {
+		t.Errorf("the breakdown was queried without byMenuItem")
+	}
+
+	if _, err := s.GetTotalItemsByPeriod(context.Background(), entity.GroupByDay, 13, 2024, false); !errors.Is(err, store.ErrInvalidInput) {
+		t.Errorf("month 13: error = %v, want invalid input", err)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"frappuccino-alem/internal/entity"
+)
+
+type SupplierRepository interface {
+	CreateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error)
+	GetSuppliers(ctx context.Context) ([]entity.Supplier, error)
+	GetSupplierById(ctx context.Context, id int64) (entity.Supplier, error)
+	UpdateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error)
+	DeleteSupplier(ctx context.Context, id int64) error
+}
+
+type SupplierService struct {
+	repo SupplierRepository
+}
+
+func NewSupplierService(repo SupplierRepository) *SupplierService {
+	return &SupplierService{repo}
+}
+
+func (s *SupplierService) CreateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error) {
+	const op = "service.CreateSupplier"
+	created, err := s.repo.CreateSupplier(ctx, supplier)
+	if err != nil {
+		return created, fmt.Errorf("%s: %w", op, err)
+	}
+	return created, nil
+}
+
+func (s *SupplierService) GetSuppliers(ctx context.Context) ([]entity.Supplier, error) {
+	const op = "service.GetSuppliers"
+	suppliers, err := s.repo.GetSuppliers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return suppliers, nil
+}
+
+func (s *SupplierService) GetSupplierById(ctx context.Context, id int64) (entity.Supplier, error) {
+	const op = "service.GetSupplierById"
+	supplier, err := s.repo.GetSupplierById(ctx, id)
+	if err != nil {
+		return supplier, fmt.Errorf("%s: %w", op, err)
+	}
+	return supplier, nil
+}
+
+func (s *SupplierService) UpdateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error) {
+	const op = "service.UpdateSupplier"
+	updated, err := s.repo.UpdateSupplier(ctx, supplier)
+	if err != nil {
+		return updated, fmt.Errorf("%s: %w", op, err)
+	}
+	return updated, nil
+}
+
+func (s *SupplierService) DeleteSupplier(ctx context.Context, id int64) error {
+	const op = "service.DeleteSupplier"
+	if err := s.repo.DeleteSupplier(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
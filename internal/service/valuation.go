@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+)
+
+// quantityEpsilon absorbs float noise left over when layers are used up.
+const quantityEpsilon = 1e-9
+
+// costLayer is stock bought at one unit cost.
+type costLayer struct {
+	quantity float64
+	unitCost float64
+}
+
+// costLedger tracks the cost of the stock of one inventory item. FIFO keeps
+// a layer per receipt and uses up the oldest first; weighted average folds
+// every receipt into a single layer.
+type costLedger struct {
+	method   string
+	layers   []costLayer
+	lastCost float64
+}
+
+func (c *costLedger) receive(quantity, unitCost float64) {
+	c.lastCost = unitCost
+	if c.method == entity.ValuationWeightedAverage && len(c.layers) > 0 {
+		l := &c.layers[0]
+		total := l.quantity + quantity
+		l.unitCost = (l.quantity*l.unitCost + quantity*unitCost) / total
+		l.quantity = total
+		return
+	}
+	c.layers = append(c.layers, costLayer{quantity, unitCost})
+}
+
+// issue takes quantity out of stock and returns what it cost. Anything
+// beyond the stock on record is costed at the last known unit cost.
+func (c *costLedger) issue(quantity float64) float64 {
+	var cost float64
+	for quantity > quantityEpsilon && len(c.layers) > 0 {
+		l := &c.layers[0]
+		used := min(quantity, l.quantity)
+		cost += used * l.unitCost
+		l.quantity -= used
+		quantity -= used
+		if l.quantity <= quantityEpsilon {
+			c.layers = c.layers[1:]
+		}
+	}
+	if quantity > quantityEpsilon {
+		cost += quantity * c.lastCost
+	}
+	return cost
+}
+
+func (c *costLedger) stock() (quantity, value float64) {
+	for _, l := range c.layers {
+		quantity += l.quantity
+		value += l.quantity * l.unitCost
+	}
+	return quantity, value
+}
+
+// unitCost is the average cost of the stock on hand, or the last known cost
+// when there is none.
+func (c *costLedger) unitCost() float64 {
+	if quantity, value := c.stock(); quantity > quantityEpsilon {
+		return value / quantity
+	}
+	return c.lastCost
+}
+
+// costInventory replays the inventory ledger up to to and returns the cost
+// ledger of every item that existed by then. onIssue is called with the cost
+// of every outgoing entry.
+//
+// Every direct change of quantity is booked as an adjustment with its cost
+// at the time, so the ledger explains the stock of an item. Stock that it
+// does not, left over from before adjustments were booked, opens the ledger
+// at the item's current price; stock that left without an entry is reported
+// by missingQuantity. Incoming entries without a unit cost, such as batches
+// received without one, come in at the running unit cost.
+func (s *ReportService) costInventory(ctx context.Context, method string, to time.Time, onIssue func(item entity.CostedItem, e entity.LedgerEntry, cost float64)) ([]entity.CostedItem, map[int64]*costLedger, error) {
+	all, err := s.repo.GetCostedItems(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []entity.CostedItem
+	index := make(map[int64]int, len(all))
+	ledgers := make(map[int64]*costLedger, len(all))
+	for _, item := range all {
+		if !item.CreatedAt.Before(to) {
+			continue
+		}
+		ledger := &costLedger{method: method, lastCost: item.Price}
+		if opening := item.Quantity - item.LedgerBalance; opening > quantityEpsilon {
+			ledger.receive(opening, item.Price)
+		}
+		index[item.InventoryID] = len(items)
+		items = append(items, item)
+		ledgers[item.InventoryID] = ledger
+	}
+
+	err = s.repo.GetInventoryLedger(ctx, to, func(e entity.LedgerEntry) error {
+		ledger, ok := ledgers[e.InventoryID]
+		if !ok {
+			return nil
+		}
+		switch {
+		case e.QuantityChange > 0 && e.UnitCost != nil:
+			ledger.receive(e.QuantityChange, *e.UnitCost)
+		case e.QuantityChange > 0:
+			ledger.receive(e.QuantityChange, ledger.unitCost())
+		case e.QuantityChange < 0:
+			cost := ledger.issue(-e.QuantityChange)
+			if onIssue != nil {
+				onIssue(items[index[e.InventoryID]], e, cost)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, ledgers, nil
+}
+
+// missingQuantity is how much more stock the ledger of item holds than the
+// item has, zero when the ledger holds no more than that.
+func missingQuantity(item entity.CostedItem) float64 {
+	if missing := item.LedgerBalance - item.Quantity; missing > quantityEpsilon {
+		return missing
+	}
+	return 0
+}
+
+func (s *ReportService) valuationMethod(method string) (string, error) {
+	if method == "" {
+		return s.valuation, nil
+	}
+	if !entity.IsValidValuationMethod(method) {
+		return "", fmt.Errorf("%w: unknown valuation method %q", store.ErrInvalidInput, method)
+	}
+	return method, nil
+}
+
+// GetInventoryValuation values the stock every item had at asOf. An empty
+// method uses the configured one.
+func (s *ReportService) GetInventoryValuation(ctx context.Context, asOf time.Time, method string) (entity.InventoryValuation, error) {
+	const op = "service.GetInventoryValuation"
+
+	method, err := s.valuationMethod(method)
+	if err != nil {
+		return entity.InventoryValuation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, ledgers, err := s.costInventory(ctx, method, asOf, nil)
+	if err != nil {
+		return entity.InventoryValuation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	valuation := entity.InventoryValuation{
+		AsOf:   asOf,
+		Method: method,
+		Items:  make([]entity.InventoryValuationItem, 0, len(items)),
+	}
+	for _, item := range items {
+		quantity, value := ledgers[item.InventoryID].stock()
+		valuation.Items = append(valuation.Items, entity.InventoryValuationItem{
+			InventoryID:     item.InventoryID,
+			Name:            item.Name,
+			Unit:            item.Unit,
+			Quantity:        roundQuantity(quantity),
+			UnitCost:        roundQuantity(ledgers[item.InventoryID].unitCost()),
+			Value:           roundQuantity(value),
+			MissingQuantity: roundQuantity(missingQuantity(item)),
+		})
+		valuation.TotalValue += value
+	}
+	valuation.TotalValue = roundQuantity(valuation.TotalValue)
+
+	return valuation, nil
+}
+
+// GetCOGS costs the stock used up in the query range and sets it against
+// the sales of completed orders, per period when the query is grouped.
+// Waste is left out, see GetWasteReport, and so are adjustments, which
+// correct the stock count rather than use stock up. An empty method uses
+// the configured one.
+func (s *ReportService) GetCOGS(ctx context.Context, q entity.ReportQuery, method string) (entity.COGSReport, error) {
+	const op = "service.GetCOGS"
+
+	method, err := s.valuationMethod(method)
+	if err != nil {
+		return entity.COGSReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sales, err := s.GetTotalSales(ctx, entity.ReportQuery{From: q.From, To: q.To, GroupBy: q.GroupBy})
+	if err != nil {
+		return entity.COGSReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := entity.COGSReport{
+		From:       sales.From,
+		To:         q.To,
+		GroupBy:    q.GroupBy,
+		Method:     method,
+		Revenue:    sales.TotalSales,
+		OrderCount: sales.OrderCount,
+		Items:      []entity.COGSItem{},
+	}
+	buckets := make(map[time.Time]int, len(sales.Buckets))
+	for i, b := range sales.Buckets {
+		buckets[b.PeriodStart] = i
+		report.Buckets = append(report.Buckets, entity.COGSBucket{PeriodStart: b.PeriodStart, Revenue: b.TotalSales})
+	}
+
+	used := make(map[int64]*entity.COGSItem)
+	_, _, err = s.costInventory(ctx, method, q.To, func(item entity.CostedItem, e entity.LedgerEntry, cost float64) {
+		if e.CreatedAt.Before(q.From) || e.Reason == store.ReasonWaste || e.Reason == store.ReasonAdjustment {
+			return
+		}
+		u, ok := used[item.InventoryID]
+		if !ok {
+			u = &entity.COGSItem{InventoryID: item.InventoryID, Name: item.Name, Unit: item.Unit}
+			used[item.InventoryID] = u
+		}
+		u.QuantityUsed -= e.QuantityChange
+		u.Cost += cost
+		report.COGS += cost
+		if i, ok := buckets[truncatePeriod(e.CreatedAt, q.GroupBy)]; ok {
+			report.Buckets[i].COGS += cost
+		}
+	})
+	if err != nil {
+		return entity.COGSReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, u := range used {
+		u.QuantityUsed = roundQuantity(u.QuantityUsed)
+		u.Cost = roundQuantity(u.Cost)
+		report.Items = append(report.Items, *u)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.InventoryID < b.InventoryID
+	})
+
+	for i := range report.Buckets {
+		b := &report.Buckets[i]
+		b.COGS = roundQuantity(b.COGS)
+		b.GrossProfit = roundQuantity(b.Revenue - b.COGS)
+	}
+	report.COGS = roundQuantity(report.COGS)
+	report.GrossProfit = roundQuantity(report.Revenue - report.COGS)
+	if report.Revenue > 0 {
+		margin := roundQuantity(report.GrossProfit / report.Revenue * 100)
+		report.GrossMargin = &margin
+	}
+
+	return report, nil
+}
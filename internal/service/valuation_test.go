@@ -0,0 +1,140 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"frappuccino-alem/internal/entity"
+)
+
+func TestCostLedger(t *testing.T) {
+	type step struct {
+		receive  float64
+		unitCost float64
+		issue    float64
+		wantCost float64
+	}
+	tests := []struct {
+		name         string
+		method       string
+		lastCost     float64
+		steps        []step
+		wantQuantity float64
+		wantValue    float64
+		wantUnitCost float64
+	}{
+		{
+			name:   "fifo uses the oldest layer first",
+			method: entity.ValuationFIFO,
+			steps: []step{
+				{receive: 10, unitCost: 1},
+				{receive: 10, unitCost: 2},
+				{issue: 15, wantCost: 10*1 + 5*2},
+			},
+			wantQuantity: 5, wantValue: 10, wantUnitCost: 2,
+		},
+		{
+			name:   "fifo issue spanning every layer",
+			method: entity.ValuationFIFO,
+			steps: []step{
+				{receive: 2, unitCost: 3},
+				{receive: 3, unitCost: 4},
+				{receive: 5, unitCost: 5},
+				{issue: 4, wantCost: 2*3 + 2*4},
+				{issue: 6, wantCost: 1*4 + 5*5},
+			},
+			wantQuantity: 0, wantValue: 0, wantUnitCost: 5,
+		},
+		{
+			name:   "average folds receipts into one cost",
+			method: entity.ValuationWeightedAverage,
+			steps: []step{
+				{receive: 10, unitCost: 1},
+				{receive: 10, unitCost: 2},
+				{issue: 15, wantCost: 15 * 1.5},
+			},
+			wantQuantity: 5, wantValue: 7.5, wantUnitCost: 1.5,
+		},
+		{
+			name:   "average after a partial issue",
+			method: entity.ValuationWeightedAverage,
+			steps: []step{
+				{receive: 4, unitCost: 2},
+				{issue: 2, wantCost: 4},
+				{receive: 2, unitCost: 5},
+				{issue: 1, wantCost: 3.5},
+			},
+			wantQuantity: 3, wantValue: 10.5, wantUnitCost: 3.5,
+		},
+		{
+			name:   "fifo issue beyond stock at the last cost",
+			method: entity.ValuationFIFO,
+			steps: []step{
+				{receive: 2, unitCost: 1},
+				{receive: 1, unitCost: 4},
+				{issue: 5, wantCost: 2*1 + 1*4 + 2*4},
+			},
+			wantQuantity: 0, wantValue: 0, wantUnitCost: 4,
+		},
+		{
+			name:   "average issue beyond stock at the last cost",
+			method: entity.ValuationWeightedAverage,
+			steps: []step{
+				{receive: 1, unitCost: 2},
+				{receive: 1, unitCost: 4},
+				{issue: 3, wantCost: 2*3 + 1*4},
+			},
+			wantQuantity: 0, wantValue: 0, wantUnitCost: 4,
+		},
+		{
+			name:         "issue from an empty ledger",
+			method:       entity.ValuationFIFO,
+			lastCost:     1.25,
+			steps:        []step{{issue: 4, wantCost: 5}},
+			wantQuantity: 0, wantValue: 0, wantUnitCost: 1.25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &costLedger{method: tt.method, lastCost: tt.lastCost}
+			for i, st := range tt.steps {
+				if st.receive > 0 {
+					ledger.receive(st.receive, st.unitCost)
+					continue
+				}
+				if got := ledger.issue(st.issue); !almostEqual(got, st.wantCost) {
+					t.Errorf("step %d: issue(%v) = %v, want %v", i, st.issue, got, st.wantCost)
+				}
+			}
+
+			quantity, value := ledger.stock()
+			if !almostEqual(quantity, tt.wantQuantity) || !almostEqual(value, tt.wantValue) {
+				t.Errorf("stock() = %v, %v, want %v, %v", quantity, value, tt.wantQuantity, tt.wantValue)
+			}
+			if got := ledger.unitCost(); !almostEqual(got, tt.wantUnitCost) {
+				t.Errorf("unitCost() = %v, want %v", got, tt.wantUnitCost)
+			}
+		})
+	}
+}
+
+func TestMissingQuantity(t *testing.T) {
+	tests := []struct {
+		quantity, balance, want float64
+	}{
+		{quantity: 5, balance: 5, want: 0},
+		{quantity: 8, balance: 5, want: 0},
+		{quantity: 2, balance: 5, want: 3},
+		{quantity: 0, balance: 0.5, want: 0.5},
+	}
+	for _, tt := range tests {
+		item := entity.CostedItem{Quantity: tt.quantity, LedgerBalance: tt.balance}
+		if got := missingQuantity(item); !almostEqual(got, tt.want) {
+			t.Errorf("missingQuantity(quantity %v, balance %v) = %v, want %v", tt.quantity, tt.balance, got, tt.want)
+		}
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"frappuccino-alem/internal/entity"
+	"frappuccino-alem/internal/store"
+)
+
+// GetWasteReport sums the waste logged in the query range, costing it like
+// the inventory valuation. An empty method uses the configured one.
+func (s *ReportService) GetWasteReport(ctx context.Context, q entity.ReportQuery, method string) (entity.WasteReport, error) {
+	const op = "service.GetWasteReport"
+
+	method, err := s.valuationMethod(method)
+	if err != nil {
+		return entity.WasteReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := entity.WasteReport{
+		From:   optionalTime(q.From),
+		To:     q.To,
+		Method: method,
+		Items:  []entity.WasteItem{},
+		Staff:  []entity.WasteStaffTotal{},
+	}
+	reasons := make(map[string]*entity.WasteReasonTotal, len(entity.WasteReasons))
+	for _, reason := range entity.WasteReasons {
+		reasons[reason] = &entity.WasteReasonTotal{Reason: reason}
+	}
+	items := make(map[int64]*entity.WasteItem)
+	itemReasons := make(map[int64]map[string]*entity.WasteItemReason)
+	staff := make(map[int64]*entity.WasteStaffTotal)
+	var unassigned *entity.WasteStaffTotal
+
+	_, _, err = s.costInventory(ctx, method, q.To, func(item entity.CostedItem, e entity.LedgerEntry, cost float64) {
+		if e.CreatedAt.Before(q.From) || e.Reason != store.ReasonWaste {
+			return
+		}
+		quantity := -e.QuantityChange
+		report.TotalCost += cost
+		report.EntryCount++
+
+		if total, ok := reasons[e.WasteReason]; ok {
+			total.Cost += cost
+			total.EntryCount++
+		}
+
+		w, ok := items[item.InventoryID]
+		if !ok {
+			w = &entity.WasteItem{InventoryID: item.InventoryID, Name: item.Name, Unit: item.Unit}
+			items[item.InventoryID] = w
+			itemReasons[item.InventoryID] = make(map[string]*entity.WasteItemReason)
+		}
+		w.Quantity += quantity
+		w.Cost += cost
+		w.EntryCount++
+		r, ok := itemReasons[item.InventoryID][e.WasteReason]
+		if !ok {
+			r = &entity.WasteItemReason{Reason: e.WasteReason}
+			itemReasons[item.InventoryID][e.WasteReason] = r
+		}
+		r.Quantity += quantity
+		r.Cost += cost
+		r.EntryCount++
+
+		var st *entity.WasteStaffTotal
+		if e.StaffID == nil {
+			if unassigned == nil {
+				unassigned = &entity.WasteStaffTotal{Name: "unassigned"}
+			}
+			st = unassigned
+		} else if st = staff[*e.StaffID]; st == nil {
+			st = &entity.WasteStaffTotal{StaffID: e.StaffID, Name: e.StaffName}
+			staff[*e.StaffID] = st
+		}
+		st.Cost += cost
+		st.EntryCount++
+	})
+	if err != nil {
+		return entity.WasteReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for id, w := range items {
+		w.Quantity = roundQuantity(w.Quantity)
+		w.Cost = roundQuantity(w.Cost)
+		for _, r := range itemReasons[id] {
+			r.Quantity = roundQuantity(r.Quantity)
+			r.Cost = roundQuantity(r.Cost)
+			w.Reasons = append(w.Reasons, *r)
+		}
+		sort.Slice(w.Reasons, func(i, j int) bool {
+			if w.Reasons[i].Cost != w.Reasons[j].Cost {
+				return w.Reasons[i].Cost > w.Reasons[j].Cost
+			}
+			return w.Reasons[i].Reason < w.Reasons[j].Reason
+		})
+		report.Items = append(report.Items, *w)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.InventoryID < b.InventoryID
+	})
+
+	for _, reason := range entity.WasteReasons {
+		total := reasons[reason]
+		total.Cost = roundQuantity(total.Cost)
+		report.Reasons = append(report.Reasons, *total)
+	}
+	sort.SliceStable(report.Reasons, func(i, j int) bool {
+		return report.Reasons[i].Cost > report.Reasons[j].Cost
+	})
+
+	for _, st := range staff {
+		report.Staff = append(report.Staff, *st)
+	}
+	if unassigned != nil {
+		report.Staff = append(report.Staff, *unassigned)
+	}
+	for i := range report.Staff {
+		report.Staff[i].Cost = roundQuantity(report.Staff[i].Cost)
+	}
+	sort.SliceStable(report.Staff, func(i, j int) bool {
+		a, b := report.Staff[i], report.Staff[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.StaffID == nil || b.StaffID == nil {
+			return b.StaffID == nil && a.StaffID != nil
+		}
+		return *a.StaffID < *b.StaffID
+	})
+	report.TotalCost = roundQuantity(report.TotalCost)
+
+	return report, nil
+}
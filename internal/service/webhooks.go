@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/entity"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	GetSubscriptionById(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]entity.WebhookDelivery, error)
+	FanOutEvents(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entity.WebhookDispatch, error)
+	RecordAttempt(ctx context.Context, attempt entity.WebhookAttempt, status string, nextAttemptAt time.Time) error
+}
+
+type WebhookService struct {
+	repo WebhookRepository
+}
+
+func NewWebhookService(repo WebhookRepository) *WebhookService {
+	return &WebhookService{repo}
+}
+
+// CreateSubscription stores a new subscription, generating a signing secret
+// when none was given.
+func (s *WebhookService) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error) {
+	const op = "service.CreateSubscription"
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return sub, fmt.Errorf("%s: %w", op, err)
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	sub.Active = true
+
+	created, err := s.repo.CreateSubscription(ctx, sub)
+	if err != nil {
+		return sub, fmt.Errorf("%s: %w", op, err)
+	}
+	return created, nil
+}
+
+func (s *WebhookService) GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	const op = "service.GetSubscriptions"
+	subs, err := s.repo.GetSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) GetSubscriptionById(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	const op = "service.GetSubscriptionById"
+	sub, err := s.repo.GetSubscriptionById(ctx, id)
+	if err != nil {
+		return sub, fmt.Errorf("%s: %w", op, err)
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	const op = "service.DeleteSubscription"
+	if err := s.repo.DeleteSubscription(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]entity.WebhookDelivery, error) {
+	const op = "service.GetDeliveries"
+	if _, err := s.repo.GetSubscriptionById(ctx, subscriptionID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	deliveries, err := s.repo.GetDeliveries(ctx, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return deliveries, nil
+}
+
+// SignWebhook returns the X-Webhook-Signature value for a delivery: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret.
+// Receivers should recompute it and compare with hmac.Equal.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookDispatcher moves outbox events to subscribers. Several app instances
+// may run one each; deliveries are claimed with SKIP LOCKED.
+type WebhookDispatcher struct {
+	repo   WebhookRepository
+	cfg    config.Webhooks
+	client *http.Client
+	logger *slog.Logger
+}
+
+func NewWebhookDispatcher(repo WebhookRepository, cfg config.Webhooks, logger *slog.Logger) *WebhookDispatcher {
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		// a redirect is treated as a failed delivery rather than followed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &WebhookDispatcher{repo, cfg, client, logger}
+}
+
+// Run polls for work until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) poll(ctx context.Context) {
+	for {
+		n, err := d.repo.FanOutEvents(ctx, d.cfg.BatchSize)
+		if err != nil {
+			d.logger.Error("failed to fan out outbox events", "error", err.Error())
+			break
+		}
+		if n < d.cfg.BatchSize {
+			break
+		}
+	}
+
+	// the lease must outlast a whole batch of requests
+	dispatches, err := d.repo.ClaimDeliveries(ctx, d.cfg.BatchSize, 3*d.cfg.Timeout)
+	if err != nil {
+		d.logger.Error("failed to claim webhook deliveries", "error", err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, dispatch := range dispatches {
+		wg.Add(1)
+		go func(dispatch entity.WebhookDispatch) {
+			defer wg.Done()
+			d.deliver(ctx, dispatch)
+		}(dispatch)
+	}
+	wg.Wait()
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, dispatch entity.WebhookDispatch) {
+	attempt := entity.WebhookAttempt{
+		DeliveryID:  dispatch.DeliveryID,
+		Attempt:     dispatch.Attempt,
+		AttemptedAt: time.Now(),
+	}
+	attempt.StatusCode, attempt.Error = d.send(ctx, dispatch)
+	attempt.Duration = time.Since(attempt.AttemptedAt)
+
+	status := entity.DeliveryPending
+	next := time.Now().Add(d.backoff(dispatch.Attempt))
+	switch {
+	case attempt.Error == "":
+		status = entity.DeliverySucceeded
+	case dispatch.Attempt >= d.cfg.MaxAttempts:
+		status = entity.DeliveryFailed
+	}
+
+	if status != entity.DeliverySucceeded {
+		d.logger.Warn("webhook delivery failed",
+			slog.Int64("delivery_id", dispatch.DeliveryID),
+			slog.Int("attempt", dispatch.Attempt),
+			slog.String("status", status),
+			slog.String("error", attempt.Error))
+	}
+
+	// the attempt happened, so record it even if we are shutting down
+	if err := d.repo.RecordAttempt(context.WithoutCancel(ctx), attempt, status, next); err != nil {
+		d.logger.Error("failed to record webhook attempt", "delivery_id", dispatch.DeliveryID, "error", err.Error())
+	}
+}
+
+// send performs one delivery and returns the response status code (0 when no
+// response arrived) and an error message, empty on success.
+func (d *WebhookDispatcher) send(ctx context.Context, dispatch entity.WebhookDispatch) (int, string) {
+	body, err := json.Marshal(webhookBody{
+		ID:        dispatch.EventID,
+		Type:      dispatch.EventType,
+		CreatedAt: dispatch.EventCreatedAt,
+		Data:      dispatch.Payload,
+	})
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "frappuccino-webhooks/1")
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(dispatch.EventID, 10))
+	req.Header.Set(WebhookEventHeader, dispatch.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(dispatch.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
+
+// backoff doubles the delay with every attempt, caps it and adds up to 10%
+// jitter so that retries of many deliveries do not line up.
+func (d *WebhookDispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.cfg.MaxBackoff)
+	return delay + mathrand.N(delay/10+1)
+}
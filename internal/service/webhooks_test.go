@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/entity"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	got := SignWebhook("s3cret", 1700000000, body)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("SignWebhook() = %s, want %s", got, want)
+	}
+
+	for name, other := range map[string]string{
+		"secret":    SignWebhook("other", 1700000000, body),
+		"timestamp": SignWebhook("s3cret", 1700000001, body),
+		"body":      SignWebhook("s3cret", 1700000000, []byte(`{"id":2}`)),
+	} {
+		if hmac.Equal([]byte(got), []byte(other)) {
+			t.Errorf("signature does not depend on the %s", name)
+		}
+	}
+}
+
+func TestWebhookDispatcherBackoff(t *testing.T) {
+	d := &WebhookDispatcher{cfg: config.Webhooks{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := d.backoff(tt.attempt)
+			if got < tt.base || got > tt.base+tt.base/10 {
+				t.Fatalf("backoff(%d) = %v, want within 10%% above %v", tt.attempt, got, tt.base)
+			}
+		}
+	}
+}
+
+// recordingWebhookRepo keeps the attempts the dispatcher records.
+type recordingWebhookRepo struct {
+	WebhookRepository
+
+	mu       sync.Mutex
+	attempts []recordedAttempt
+}
+
+type recordedAttempt struct {
+	attempt entity.WebhookAttempt
+	status  string
+	next    time.Time
+}
+
+func (r *recordingWebhookRepo) RecordAttempt(ctx context.Context, attempt entity.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, recordedAttempt{attempt, status, nextAttemptAt})
+	return nil
+}
+
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	const secret = "0123456789abcdef"
+	var status int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("bad %s header: %v", WebhookTimestampHeader, err)
+		}
+		want := SignWebhook(secret, timestamp, body)
+		if !hmac.Equal([]byte(r.Header.Get(WebhookSignatureHeader)), []byte(want)) {
+			t.Errorf("signature %q does not verify", r.Header.Get(WebhookSignatureHeader))
+		}
+		if got := r.Header.Get(WebhookEventHeader); got != entity.EventOrderCompleted {
+			t.Errorf("%s = %q, want %q", WebhookEventHeader, got, entity.EventOrderCompleted)
+		}
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	cfg := config.Webhooks{
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Hour,
+	}
+	tests := []struct {
+		name       string
+		status     int
+		attempt    int
+		wantStatus string
+		wantError  bool
+	}{
+		{name: "delivered", status: http.StatusNoContent, attempt: 1, wantStatus: entity.DeliverySucceeded},
+		{name: "retried", status: http.StatusInternalServerError, attempt: 1, wantStatus: entity.DeliveryPending, wantError: true},
+		{name: "redirect is a failure", status: http.StatusFound, attempt: 2, wantStatus: entity.DeliveryPending, wantError: true},
+		{name: "given up after max attempts", status: http.StatusBadGateway, attempt: 3, wantStatus: entity.DeliveryFailed, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &recordingWebhookRepo{}
+			d := NewWebhookDispatcher(repo, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			status = tt.status
+
+			start := time.Now()
+			d.deliver(context.Background(), entity.WebhookDispatch{
+				DeliveryID: 7,
+				Attempt:    tt.attempt,
+				URL:        receiver.URL,
+				Secret:     secret,
+				EventID:    42,
+				EventType:  entity.EventOrderCompleted,
+				Payload:    []byte(`{"order_id":1}`),
+			})
+
+			if len(repo.attempts) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(repo.attempts))
+			}
+			got := repo.attempts[0]
+			if got.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.status, tt.wantStatus)
+			}
+			if got.attempt.StatusCode != tt.status {
+				t.Errorf("status code = %d, want %d", got.attempt.StatusCode, tt.status)
+			}
+			if (got.attempt.Error != "") != tt.wantError {
+				t.Errorf("error = %q, want error %v", got.attempt.Error, tt.wantError)
+			}
+			if got.attempt.DeliveryID != 7 || got.attempt.Attempt != tt.attempt {
+				t.Errorf("attempt = %+v, want delivery 7 attempt %d", got.attempt, tt.attempt)
+			}
+			if tt.wantStatus == entity.DeliveryPending {
+				wantNext := start.Add(d.cfg.InitialBackoff << (tt.attempt - 1))
+				if got.next.Before(wantNext) {
+					t.Errorf("next attempt at %v, want no earlier than %v", got.next, wantNext)
+				}
+			}
+		})
+	}
+}
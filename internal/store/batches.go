@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+const batchColumns = `b.id, b.inventory_id, i.item_name, i.unit, b.lot_code, b.quantity, b.remaining_quantity,
+	b.unit_cost, b.received_at, b.expires_at, b.purchase_order_id`
+
+// batchOrder is the order batches are drawn down in.
+const batchOrder = "b.expires_at NULLS LAST, b.received_at, b.id"
+
+func scanBatch(row interface{ Scan(...any) error }) (entity.InventoryBatch, error) {
+	var b entity.InventoryBatch
+	err := row.Scan(&b.ID, &b.InventoryID, &b.ItemName, &b.Unit, &b.LotCode, &b.Quantity, &b.RemainingQuantity,
+		&b.UnitCost, &b.ReceivedAt, &b.ExpiresAt, &b.PurchaseOrderID)
+	return b, err
+}
+
+// CreateBatch receives a batch of stock outside of a purchase order. Like a
+// purchase order receipt it adds to the inventory and posts a restock entry;
+// with a unit cost the item's price moves to the weighted average.
+func (r *inventoryRepository) CreateBatch(ctx context.Context, batch entity.InventoryBatch) (entity.InventoryBatch, error) {
+	const op = "Store.CreateBatch"
+
+	if batch.ReceivedAt.IsZero() {
+		batch.ReceivedAt = time.Now().UTC()
+	}
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT TRUE FROM inventory WHERE id = $1 FOR UPDATE", batch.InventoryID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventory
+			SET price = CASE WHEN $3::DECIMAL IS NULL THEN price
+					ELSE ROUND((quantity * price + $2 * $3) / (quantity + $2), 2) END,
+				quantity = quantity + $2,
+				updated_at = NOW()
+			WHERE id = $1`, batch.InventoryID, batch.Quantity, batch.UnitCost)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO inventory_transactions (inventory_id, quantity_change, reason, unit_cost, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			batch.InventoryID, batch.Quantity, ReasonRestock, batch.UnitCost, batch.ReceivedAt)
+		if err != nil {
+			return err
+		}
+		return insertBatch(ctx, tx, &batch)
+	})
+	if err != nil {
+		return entity.InventoryBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return batch, nil
+}
+
+// GetBatches lists the batches of an inventory item in the order they are
+// drawn down, used up batches last.
+func (r *inventoryRepository) GetBatches(ctx context.Context, inventoryID int64) ([]entity.InventoryBatch, error) {
+	const op = "Store.GetBatches"
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT TRUE FROM inventory WHERE id = $1", inventoryID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	batches, err := queryBatches(ctx, r.db, `
+		SELECT `+batchColumns+`
+		FROM inventory_batches b
+		JOIN inventory i ON i.id = b.inventory_id
+		WHERE b.inventory_id = $1
+		ORDER BY b.remaining_quantity = 0, `+batchOrder, inventoryID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return batches, nil
+}
+
+// GetExpiringBatches lists the batches with stock left that expire before
+// the given time, including those already expired, soonest first.
+func (r *inventoryRepository) GetExpiringBatches(ctx context.Context, before time.Time) ([]entity.InventoryBatch, error) {
+	const op = "Store.GetExpiringBatches"
+
+	batches, err := queryBatches(ctx, r.db, `
+		SELECT `+batchColumns+`
+		FROM inventory_batches b
+		JOIN inventory i ON i.id = b.inventory_id
+		WHERE b.remaining_quantity > 0 AND b.expires_at < $1
+		ORDER BY `+batchOrder, before)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return batches, nil
+}
+
+// WasteExpiredBatches logs whatever is left of every batch that expired by
+// now as expired waste and empties the batch.
+func (r *inventoryRepository) WasteExpiredBatches(ctx context.Context, now time.Time) ([]entity.WasteEntry, error) {
+	const op = "Store.WasteExpiredBatches"
+
+	var entries []entity.WasteEntry
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		var err error
+		entries, err = wasteExpiredBatches(ctx, tx, now, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return entries, nil
+}
+
+// wasteExpiredBatches logs whatever is left of the batches that expired by
+// now as expired waste and empties them, for the given items or for every
+// item when inventoryIDs is nil.
+func wasteExpiredBatches(ctx context.Context, tx *sql.Tx, now time.Time, inventoryIDs []int64) ([]entity.WasteEntry, error) {
+	batches, err := queryBatches(ctx, tx, `
+		SELECT `+batchColumns+`
+		FROM inventory_batches b
+		JOIN inventory i ON i.id = b.inventory_id
+		WHERE b.remaining_quantity > 0 AND b.expires_at <= $1
+			AND ($2::BIGINT[] IS NULL OR b.inventory_id = ANY($2))
+		ORDER BY `+batchOrder+`
+		FOR UPDATE OF b SKIP LOCKED`, now, pq.Array(inventoryIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entity.WasteEntry
+	for _, b := range batches {
+		_, err := tx.ExecContext(ctx, "UPDATE inventory_batches SET remaining_quantity = 0 WHERE id = $1", b.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// batches should never hold more than is in stock, but stock
+		// edited before batches were tracked may disagree
+		var inStock float64
+		err = tx.QueryRowContext(ctx, "SELECT quantity FROM inventory WHERE id = $1 FOR UPDATE", b.InventoryID).Scan(&inStock)
+		if err != nil {
+			return nil, err
+		}
+		entry := entity.WasteEntry{
+			InventoryID: b.InventoryID,
+			Quantity:    min(b.RemainingQuantity, inStock),
+			Reason:      entity.WasteExpired,
+		}
+		if entry.Quantity <= 0 {
+			continue
+		}
+		if err := logWaste(ctx, tx, &entry); err != nil {
+			return nil, fmt.Errorf("batch %d: %w", b.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func queryBatches(ctx context.Context, q querier, query string, args ...any) ([]entity.InventoryBatch, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := make([]entity.InventoryBatch, 0)
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// insertBatch records received stock as a new batch. The inventory quantity
+// is the caller's business.
+func insertBatch(ctx context.Context, tx *sql.Tx, batch *entity.InventoryBatch) error {
+	batch.RemainingQuantity = batch.Quantity
+	if batch.ReceivedAt.IsZero() {
+		batch.ReceivedAt = time.Now().UTC()
+	}
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_batches
+			(inventory_id, lot_code, quantity, remaining_quantity, unit_cost, received_at, expires_at, purchase_order_id)
+		VALUES ($1, $2, $3, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		batch.InventoryID, batch.LotCode, batch.Quantity, batch.UnitCost, batch.ReceivedAt, batch.ExpiresAt, batch.PurchaseOrderID,
+	).Scan(&batch.ID)
+	if err != nil {
+		return err
+	}
+	return tx.QueryRowContext(ctx, "SELECT item_name, unit FROM inventory WHERE id = $1", batch.InventoryID).
+		Scan(&batch.ItemName, &batch.Unit)
+}
+
+// drawBatches takes quantity out of an item's batches, first expiring
+// first. Whatever the batches cannot cover comes out of untracked stock.
+// The inventory quantity is the caller's business.
+func drawBatches(ctx context.Context, tx *sql.Tx, inventoryID int64, quantity float64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT b.id, b.remaining_quantity
+		FROM inventory_batches b
+		WHERE b.inventory_id = $1 AND b.remaining_quantity > 0
+		ORDER BY `+batchOrder+`
+		FOR UPDATE`, inventoryID)
+	if err != nil {
+		return err
+	}
+	type open struct {
+		id        int64
+		remaining float64
+	}
+	var batches []open
+	for rows.Next() {
+		var b open
+		if err := rows.Scan(&b.id, &b.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		batches = append(batches, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, b := range batches {
+		if quantity <= 0 {
+			break
+		}
+		used := min(quantity, b.remaining)
+		_, err := tx.ExecContext(ctx,
+			"UPDATE inventory_batches SET remaining_quantity = remaining_quantity - $2 WHERE id = $1", b.id, used)
+		if err != nil {
+			return err
+		}
+		quantity = math.Round((quantity-used)*100) / 100
+	}
+	return nil
+}
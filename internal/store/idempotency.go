@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frappuccino-alem/internal/entity"
+)
+
+type IdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewIdempotencyStore(db *sql.DB) *IdempotencyStore {
+	return &IdempotencyStore{db}
+}
+
+// Reserve claims rec.Key for a new request. If the key is already taken by an
+// unexpired record, that record is returned instead and reserved is false.
+func (s *IdempotencyStore) Reserve(ctx context.Context, rec entity.IdempotencyRecord) (entity.IdempotencyRecord, bool, error) {
+	const op = "Store.Idempotency.Reserve"
+
+	var existing entity.IdempotencyRecord
+	reserved := false
+	err := runInTx(s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM idempotency_keys
+			WHERE key = $1 AND method = $2 AND path = $3 AND expires_at <= NOW()`,
+			rec.Key, rec.Method, rec.Path)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (key, method, path, request_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (key, method, path) DO NOTHING`,
+			rec.Key, rec.Method, rec.Path, rec.RequestHash, rec.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 1 {
+			reserved = true
+			return nil
+		}
+
+		var status sql.NullInt64
+		var contentType sql.NullString
+		err = tx.QueryRowContext(ctx, `
+			SELECT key, method, path, request_hash, status_code, content_type,
+				COALESCE(response_body, ''), created_at, expires_at
+			FROM idempotency_keys
+			WHERE key = $1 AND method = $2 AND path = $3`,
+			rec.Key, rec.Method, rec.Path,
+		).Scan(
+			&existing.Key,
+			&existing.Method,
+			&existing.Path,
+			&existing.RequestHash,
+			&status,
+			&contentType,
+			&existing.Body,
+			&existing.CreatedAt,
+			&existing.ExpiresAt,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrConflict
+			}
+			return err
+		}
+		existing.StatusCode = int(status.Int64)
+		existing.ContentType = contentType.String
+		return nil
+	})
+	if err != nil {
+		return entity.IdempotencyRecord{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if reserved {
+		return rec, true, nil
+	}
+	return existing, false, nil
+}
+
+// Complete stores the response produced for a reserved key.
+func (s *IdempotencyStore) Complete(ctx context.Context, rec entity.IdempotencyRecord) error {
+	const op = "Store.Idempotency.Complete"
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3
+		WHERE key = $4 AND method = $5 AND path = $6`,
+		rec.StatusCode, rec.ContentType, rec.Body, rec.Key, rec.Method, rec.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Release drops a reservation so the client may retry the request.
+func (s *IdempotencyStore) Release(ctx context.Context, key, method, path string) error {
+	const op = "Store.Idempotency.Release"
+
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE key = $1 AND method = $2 AND path = $3",
+		key, method, path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "Store.Idempotency.DeleteExpired"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.RowsAffected()
+}
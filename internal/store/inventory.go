@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
@@ -18,6 +19,13 @@ type InventoryRepository interface {
 	GetInventoryItemById(ctx context.Context, id int64) (entity.InventoryItem, error)
 	DeleteInventoryItemById(ctx context.Context, id int64) (int64, error)
 	UpdateByID(ctx context.Context, id int64, updateFn func(item *entity.InventoryItem) (bool, error)) error
+	ImportInventoryItems(ctx context.Context, items []entity.InventoryItem) (entity.ImportResult, error)
+	ExportInventoryItems(ctx context.Context, fn func(item entity.InventoryItem) error) error
+	LogWaste(ctx context.Context, entry entity.WasteEntry) (entity.WasteEntry, error)
+	CreateBatch(ctx context.Context, batch entity.InventoryBatch) (entity.InventoryBatch, error)
+	GetBatches(ctx context.Context, inventoryID int64) ([]entity.InventoryBatch, error)
+	GetExpiringBatches(ctx context.Context, before time.Time) ([]entity.InventoryBatch, error)
+	WasteExpiredBatches(ctx context.Context, now time.Time) ([]entity.WasteEntry, error)
 }
 
 type inventoryRepository struct {
@@ -29,11 +37,21 @@ func NewInventoryStore(db *sql.DB) *inventoryRepository {
 }
 
 var (
-	ErrNotFound     = errors.New("not found")
-	ErrConflict     = errors.New("conflict")
-	ErrInvalidInput = errors.New("invalid input")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrPreconditionFailed = errors.New("precondition failed")
 )
 
+const inventoryColumns = "id, item_name, quantity, unit, price, reorder_level, created_at, updated_at"
+
+// RowErrors reports the rows of a bulk import that could not be applied.
+// It matches ErrInvalidInput with errors.Is.
+type RowErrors []entity.ImportRowError
+
+func (e RowErrors) Error() string { return fmt.Sprintf("%d rows rejected", len(e)) }
+func (e RowErrors) Unwrap() error { return ErrInvalidInput }
+
 func (r *inventoryRepository) CreateInventoryItem(ctx context.Context, item entity.InventoryItem) (int64, error) {
 	const op = "Store.CreateInventoryItem"
 
@@ -44,10 +62,15 @@ func (r *inventoryRepository) CreateInventoryItem(ctx context.Context, item enti
 		Price:    item.Price,
 	}
 	var id int64
-	row := r.db.QueryRowContext(ctx,
-		"INSERT INTO inventory (item_name,quantity,unit,price) VALUES ($1,$2,$3,$4) RETURNING id",
-		ItemModel.ItemName, ItemModel.Quantity, ItemModel.Unit, ItemModel.Price)
-	err := row.Scan(&id)
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			"INSERT INTO inventory (item_name,quantity,unit,price,reorder_level) VALUES ($1,$2,$3,$4,$5) RETURNING id",
+			ItemModel.ItemName, ItemModel.Quantity, ItemModel.Unit, ItemModel.Price, item.ReorderLevel)
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		return insertAdjustment(ctx, tx, id, ItemModel.Quantity, ItemModel.Price)
+	})
 	if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
@@ -57,7 +80,7 @@ func (r *inventoryRepository) CreateInventoryItem(ctx context.Context, item enti
 func (r *inventoryRepository) GetAllInventoryItems(ctx context.Context, pagination *dto.Pagination) ([]entity.InventoryItem, error) {
 	const op = "Store.GetAllInventoryItems"
 	var items []entity.InventoryItem
-	query := "SELECT * FROM inventory"
+	query := "SELECT " + inventoryColumns + " FROM inventory"
 
 	if pagination.SortBy != "" {
 		query += fmt.Sprintf(" ORDER BY %s", pagination.SortBy)
@@ -74,7 +97,7 @@ func (r *inventoryRepository) GetAllInventoryItems(ctx context.Context, paginati
 
 	for rows.Next() {
 		var item entity.InventoryItem
-		err := rows.Scan(&item.ID, &item.ItemName, &item.Quantity, &item.Unit, &item.Price, &item.CreatedAt, &item.UpdatedAt)
+		err := rows.Scan(&item.ID, &item.ItemName, &item.Quantity, &item.Unit, &item.Price, &item.ReorderLevel, &item.CreatedAt, &item.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -103,12 +126,13 @@ func (r *inventoryRepository) GetInventoryItemById(ctx context.Context, id int64
 	var item entity.InventoryItem
 
 	err := r.db.QueryRowContext(ctx,
-		"SELECT * FROM inventory WHERE id = $1", id).Scan(
+		"SELECT "+inventoryColumns+" FROM inventory WHERE id = $1", id).Scan(
 		&item.ID,
 		&item.ItemName,
 		&item.Quantity,
 		&item.Unit,
 		&item.Price,
+		&item.ReorderLevel,
 		&item.CreatedAt,
 		&item.UpdatedAt,
 	)
@@ -126,6 +150,9 @@ func (r *inventoryRepository) DeleteInventoryItemById(ctx context.Context, id in
 	const op = "Store.DeleteInventoryItemById"
 
 	res, err := r.db.ExecContext(ctx, "DELETE FROM inventory WHERE id = $1", id)
+	if isForeignKeyViolation(err, "purchase_order_lines_inventory_id_fkey") {
+		return -1, fmt.Errorf("%s: %w: item is on a purchase order", op, ErrConflict)
+	}
 	if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
@@ -141,24 +168,32 @@ func (r *inventoryRepository) DeleteInventoryItemById(ctx context.Context, id in
 func (r *inventoryRepository) UpdateByID(ctx context.Context, id int64, updateFn func(item *entity.InventoryItem) (bool, error)) error {
 	const op = "Store.UpdateInventoryItemById"
 	return runInTx(r.db, func(tx *sql.Tx) error {
-		row := tx.QueryRowContext(ctx, "SELECT item_name, quantity, unit, price FROM inventory WHERE id = $1 FOR UPDATE", id)
+		row := tx.QueryRowContext(ctx, "SELECT item_name, quantity, unit, price, reorder_level, updated_at FROM inventory WHERE id = $1 FOR UPDATE", id)
 
 		var itemName string
 		var quantity float64
 		var unit string
 		var price float64
-		err := row.Scan(&itemName, &quantity, &unit, &price)
+		var reorderLevel float64
+		var updatedAt time.Time
+		err := row.Scan(&itemName, &quantity, &unit, &price, &reorderLevel, &updatedAt)
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
 			return err
 		}
 
 		item := &entity.InventoryItem{
-			ID:       id,
-			ItemName: itemName,
-			Quantity: quantity,
-			Unit:     unit,
-			Price:    price,
+			ID:           id,
+			ItemName:     itemName,
+			Quantity:     quantity,
+			Unit:         unit,
+			Price:        price,
+			ReorderLevel: reorderLevel,
+			UpdatedAt:    updatedAt,
 		}
+		wasLow := item.IsLow()
 
 		updated, err := updateFn(item)
 		if err != nil {
@@ -169,15 +204,117 @@ func (r *inventoryRepository) UpdateByID(ctx context.Context, id int64, updateFn
 			return nil
 		}
 
+		if item.Quantity < quantity {
+			if err := drawBatches(ctx, tx, id, quantity-item.Quantity); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if err := insertAdjustment(ctx, tx, id, item.Quantity-quantity, item.Price); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
 		_, err = tx.ExecContext(ctx,
-			"UPDATE inventory SET item_name = $1, quantity = $2, unit = $3, price = $4, updated_at = $5 WHERE id = $6",
-			item.ItemName, item.Quantity, item.Unit, item.Price, item.UpdatedAt, item.ID)
+			"UPDATE inventory SET item_name = $1, quantity = $2, unit = $3, price = $4, reorder_level = $5, updated_at = $6 WHERE id = $7",
+			item.ItemName, item.Quantity, item.Unit, item.Price, item.ReorderLevel, item.UpdatedAt, item.ID)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
+		if !wasLow && item.IsLow() {
+			if err := insertLowStockEvent(ctx, tx, *item); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ImportInventoryItems inserts or updates every item in one transaction,
+// matching existing rows by case-insensitive name.
+func (r *inventoryRepository) ImportInventoryItems(ctx context.Context, items []entity.InventoryItem) (entity.ImportResult, error) {
+	const op = "Store.ImportInventoryItems"
+	var result entity.ImportResult
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		for _, item := range items {
+			var existing entity.InventoryItem
+			err := tx.QueryRowContext(ctx,
+				"SELECT id, quantity, reorder_level FROM inventory WHERE LOWER(item_name) = LOWER($1) ORDER BY id LIMIT 1 FOR UPDATE",
+				item.ItemName).Scan(&existing.ID, &existing.Quantity, &existing.ReorderLevel)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				var id int64
+				err = tx.QueryRowContext(ctx,
+					"INSERT INTO inventory (item_name, quantity, unit, price) VALUES ($1, $2, $3, $4) RETURNING id",
+					item.ItemName, item.Quantity, item.Unit, item.Price).Scan(&id)
+				if err == nil {
+					err = insertAdjustment(ctx, tx, id, item.Quantity, item.Price)
+				}
+				if err != nil {
+					return fmt.Errorf("insert %q: %w", item.ItemName, err)
+				}
+				result.Created++
+			case err != nil:
+				return err
+			default:
+				_, err = tx.ExecContext(ctx,
+					"UPDATE inventory SET item_name = $1, quantity = $2, unit = $3, price = $4, updated_at = NOW() WHERE id = $5",
+					item.ItemName, item.Quantity, item.Unit, item.Price, existing.ID)
+				if err != nil {
+					return fmt.Errorf("update %q: %w", item.ItemName, err)
+				}
+				if item.Quantity < existing.Quantity {
+					if err := drawBatches(ctx, tx, existing.ID, existing.Quantity-item.Quantity); err != nil {
+						return fmt.Errorf("update %q: %w", item.ItemName, err)
+					}
+				}
+				if err := insertAdjustment(ctx, tx, existing.ID, item.Quantity-existing.Quantity, item.Price); err != nil {
+					return fmt.Errorf("update %q: %w", item.ItemName, err)
+				}
+				result.Updated++
+
+				updated := item
+				updated.ID, updated.ReorderLevel = existing.ID, existing.ReorderLevel
+				if !existing.IsLow() && updated.IsLow() {
+					if err := insertLowStockEvent(ctx, tx, updated); err != nil {
+						return err
+					}
+				}
+			}
+		}
 		return nil
 	})
+	if err != nil {
+		return entity.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+// ExportInventoryItems streams every inventory item to fn in id order.
+func (r *inventoryRepository) ExportInventoryItems(ctx context.Context, fn func(item entity.InventoryItem) error) error {
+	const op = "Store.ExportInventoryItems"
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+inventoryColumns+" FROM inventory ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item entity.InventoryItem
+		err := rows.Scan(&item.ID, &item.ItemName, &item.Quantity, &item.Unit, &item.Price, &item.ReorderLevel, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
 
 func runInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
@@ -201,3 +338,91 @@ func runInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 
 	return err
 }
+
+// ReasonWaste marks ledger entries of stock that was thrown or given away.
+const ReasonWaste = "waste"
+
+// ReasonAdjustment marks ledger entries of stock that was set directly: the
+// quantity an item is created or imported with and later quantity changes
+// through PUT, PATCH or an import.
+const ReasonAdjustment = "adjustment"
+
+// insertAdjustment books a direct change of stock in the ledger. Stock that
+// comes in is costed at the price the item has at that moment; stock that
+// goes out is costed from the ledger like any other issue. A zero change is
+// not booked.
+func insertAdjustment(ctx context.Context, tx *sql.Tx, inventoryID int64, change, price float64) error {
+	if change == 0 {
+		return nil
+	}
+	var unitCost sql.NullFloat64
+	if change > 0 {
+		unitCost = sql.NullFloat64{Float64: price, Valid: true}
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO inventory_transactions (inventory_id, quantity_change, reason, unit_cost) VALUES ($1, $2, $3, $4)",
+		inventoryID, change, ReasonAdjustment, unitCost)
+	return err
+}
+
+// LogWaste takes wasted stock out of the inventory, first expiring batch
+// first, and books it in the ledger. Wasting more than is in stock is a
+// conflict.
+func (r *inventoryRepository) LogWaste(ctx context.Context, entry entity.WasteEntry) (entity.WasteEntry, error) {
+	const op = "Store.LogWaste"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		if err := logWaste(ctx, tx, &entry); err != nil {
+			return err
+		}
+		return drawBatches(ctx, tx, entry.InventoryID, entry.Quantity)
+	})
+	if err != nil {
+		return entity.WasteEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return entry, nil
+}
+
+func logWaste(ctx context.Context, tx *sql.Tx, entry *entity.WasteEntry) error {
+	item := entity.InventoryItem{ID: entry.InventoryID}
+	err := tx.QueryRowContext(ctx,
+		"SELECT item_name, quantity, unit, reorder_level FROM inventory WHERE id = $1 FOR UPDATE", entry.InventoryID,
+	).Scan(&item.ItemName, &item.Quantity, &item.Unit, &item.ReorderLevel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if item.Quantity < entry.Quantity {
+		return fmt.Errorf("%w: only %v %s of %s in stock", ErrConflict, item.Quantity, item.Unit, item.ItemName)
+	}
+	entry.ItemName, entry.Unit = item.ItemName, item.Unit
+
+	wasLow := item.IsLow()
+	item.Quantity -= entry.Quantity
+	_, err = tx.ExecContext(ctx,
+		"UPDATE inventory SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2",
+		entry.Quantity, entry.InventoryID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO inventory_transactions (inventory_id, quantity_change, reason, waste_reason, staff_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		entry.InventoryID, -entry.Quantity, ReasonWaste, entry.Reason, entry.StaffID,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if isForeignKeyViolation(err, "inventory_transactions_staff_id_fkey") {
+		return fmt.Errorf("%w: staff member %d does not exist", ErrInvalidInput, *entry.StaffID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !wasLow && item.IsLow() {
+		return insertLowStockEvent(ctx, tx, item)
+	}
+	return nil
+}
@@ -3,9 +3,11 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
@@ -22,6 +24,8 @@ type MenuRepository interface {
 	GetMenuItemById(ctx context.Context, id int64) (entity.MenuItem, error)
 	DeleteMenuItemById(ctx context.Context, id int64) error
 	UpdateByID(ctx context.Context, id int64, updateFn func(item *entity.MenuItem) (bool, error)) error
+	ImportMenuItems(ctx context.Context, items []entity.MenuItem) (entity.ImportResult, error)
+	ExportMenuItems(ctx context.Context, fn func(item entity.MenuItem) error) error
 }
 
 type menuRepository struct {
@@ -51,22 +55,8 @@ func (s *menuRepository) CreateMenuItem(ctx context.Context, item entity.MenuIte
 		}
 
 		// Insert ingredients if present
-		if len(item.Ingredients) > 0 {
-			valueStrings := make([]string, 0, len(item.Ingredients))
-			valueArgs := make([]interface{}, 0, len(item.Ingredients)*3)
-
-			for i, ing := range item.Ingredients {
-				valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d)", i*3+1, i*3+2, i*3+3))
-				valueArgs = append(valueArgs, id, ing.ItemID, ing.Quantity)
-			}
-
-			_, err = tx.ExecContext(ctx,
-				fmt.Sprintf(`INSERT INTO menu_item_ingredients (menu_item_id, ingredient_id, quantity_used)  
-					VALUES %s`, strings.Join(valueStrings, ",")),
-				valueArgs...)
-			if err != nil {
-				return fmt.Errorf("insert ingredients: %w", err)
-			}
+		if err := insertIngredients(ctx, tx, id, item.Ingredients); err != nil {
+			return fmt.Errorf("insert ingredients: %w", err)
 		}
 
 		return nil
@@ -78,6 +68,26 @@ func (s *menuRepository) CreateMenuItem(ctx context.Context, item entity.MenuIte
 	return id, nil
 }
 
+func insertIngredients(ctx context.Context, tx *sql.Tx, menuItemID int64, ingredients []entity.MenuIngredient) error {
+	if len(ingredients) == 0 {
+		return nil
+	}
+
+	valueStrings := make([]string, 0, len(ingredients))
+	valueArgs := make([]interface{}, 0, len(ingredients)*3)
+
+	for i, ing := range ingredients {
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d)", i*3+1, i*3+2, i*3+3))
+		valueArgs = append(valueArgs, menuItemID, ing.ItemID, ing.Quantity)
+	}
+
+	_, err := tx.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO menu_item_ingredients (menu_item_id, ingredient_id, quantity_used)
+			VALUES %s`, strings.Join(valueStrings, ",")),
+		valueArgs...)
+	return err
+}
+
 func (s *menuRepository) GetAllMenuItems(ctx context.Context, pagination *dto.Pagination) ([]entity.MenuItem, error) {
 	const op = "Store.GetAllMenuItems"
 
@@ -126,7 +136,7 @@ func (s *menuRepository) GetAllMenuItems(ctx context.Context, pagination *dto.Pa
 
 	entities := make([]entity.MenuItem, 0, len(modelItems))
 	for _, model := range modelItems {
-		ingredients, err := s.getIngredientsForMenuItem(ctx, model.ID)
+		ingredients, err := getIngredientsForMenuItem(ctx, s.db, model.ID)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -136,7 +146,12 @@ func (s *menuRepository) GetAllMenuItems(ctx context.Context, pagination *dto.Pa
 	return entities, nil
 }
 
-func (s *menuRepository) getIngredientsForMenuItem(ctx context.Context, menuItemID int64) ([]entity.MenuIngredient, error) {
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getIngredientsForMenuItem(ctx context.Context, q querier, menuItemID int64) ([]entity.MenuIngredient, error) {
 	const op = "Store.getIngredientsForMenuItem"
 
 	query := `
@@ -151,7 +166,7 @@ func (s *menuRepository) getIngredientsForMenuItem(ctx context.Context, menuItem
         WHERE mi.menu_item_id = $1
     `
 
-	rows, err := s.db.QueryContext(ctx, query, menuItemID)
+	rows, err := q.QueryContext(ctx, query, menuItemID)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -221,7 +236,7 @@ func (s *menuRepository) GetMenuItemById(ctx context.Context, id int64) (entity.
 		return entity.MenuItem{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	ingredients, err := s.getIngredientsForMenuItem(ctx, model.ID)
+	ingredients, err := getIngredientsForMenuItem(ctx, s.db, model.ID)
 	if err != nil {
 		return entity.MenuItem{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -240,7 +255,8 @@ func (r *menuRepository) UpdateByID(ctx context.Context, id int64, updateFn func
                 price, 
                 COALESCE(categories, '{}') AS categories,
                 COALESCE(allergens, '{}') AS allergens,
-                COALESCE(metadata, '{}') AS metadata
+                COALESCE(metadata, '{}') AS metadata,
+                updated_at
             FROM menu_items 
             WHERE id = $1 FOR UPDATE`, id)
 
@@ -251,6 +267,7 @@ func (r *menuRepository) UpdateByID(ctx context.Context, id int64, updateFn func
 			categories  pq.StringArray
 			allergens   pq.StringArray
 			metadata    entity.JSONB
+			updatedAt   time.Time
 		)
 
 		err := row.Scan(
@@ -260,8 +277,12 @@ func (r *menuRepository) UpdateByID(ctx context.Context, id int64, updateFn func
 			&categories,
 			&allergens,
 			&metadata,
+			&updatedAt,
 		)
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
@@ -273,8 +294,15 @@ func (r *menuRepository) UpdateByID(ctx context.Context, id int64, updateFn func
 			Categories:  categories, // Convert to regular slice
 			Allergens:   allergens,
 			Metadata:    metadata,
+			UpdatedAt:   updatedAt,
 		}
 
+		item.Ingredients, err = getIngredientsForMenuItem(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		originalIngredients := item.Ingredients
+
 		updated, err := updateFn(item)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
@@ -307,8 +335,204 @@ func (r *menuRepository) UpdateByID(ctx context.Context, id int64, updateFn func
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
+		if sameIngredients(originalIngredients, item.Ingredients) {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM menu_item_ingredients WHERE menu_item_id = $1", id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := insertIngredients(ctx, tx, id, item.Ingredients); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil
+	})
+}
+
+// ImportMenuItems inserts or updates every menu item in one transaction,
+// matching existing items by case-insensitive name. Ingredients are given by
+// inventory item name; unknown names reject their rows with RowErrors.
+func (s *menuRepository) ImportMenuItems(ctx context.Context, items []entity.MenuItem) (entity.ImportResult, error) {
+	const op = "Store.ImportMenuItems"
+	var result entity.ImportResult
+
+	err := runInTx(s.db, func(tx *sql.Tx) error {
+		names := make([]string, 0)
+		for _, item := range items {
+			for _, ing := range item.Ingredients {
+				names = append(names, strings.ToLower(ing.Name))
+			}
+		}
+
+		rows, err := tx.QueryContext(ctx,
+			"SELECT DISTINCT ON (LOWER(item_name)) id, LOWER(item_name) FROM inventory WHERE LOWER(item_name) = ANY($1) ORDER BY LOWER(item_name), id",
+			pq.Array(names))
+		if err != nil {
+			return err
+		}
+		inventoryIDs := make(map[string]int64)
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			inventoryIDs[name] = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		var rowErrs RowErrors
+		for i := range items {
+			for j, ing := range items[i].Ingredients {
+				id, ok := inventoryIDs[strings.ToLower(ing.Name)]
+				if !ok {
+					rowErrs = append(rowErrs, entity.ImportRowError{
+						Row:   i + 1,
+						Error: fmt.Sprintf("ingredient %q is not in the inventory", ing.Name),
+					})
+					continue
+				}
+				items[i].Ingredients[j].ItemID = id
+			}
+		}
+		if len(rowErrs) > 0 {
+			return rowErrs
+		}
+
+		for _, item := range items {
+			model := mapper.ToMenuItemModel(item)
+
+			var id int64
+			err := tx.QueryRowContext(ctx,
+				"SELECT id FROM menu_items WHERE LOWER(name) = LOWER($1) ORDER BY id LIMIT 1 FOR UPDATE",
+				item.Name).Scan(&id)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				err = tx.QueryRowContext(ctx,
+					`INSERT INTO menu_items (name, description, price, categories, allergens, metadata)
+					VALUES ($1, $2, $3, $4, $5, $6)
+					RETURNING id`,
+					model.Name, model.Description, model.Price,
+					model.Categories, model.Allergens, model.Metadata,
+				).Scan(&id)
+				if err != nil {
+					return fmt.Errorf("insert %q: %w", item.Name, err)
+				}
+				result.Created++
+			case err != nil:
+				return err
+			default:
+				_, err = tx.ExecContext(ctx,
+					`UPDATE menu_items SET name = $1, description = $2, price = $3,
+						categories = $4, allergens = $5, metadata = $6, updated_at = NOW()
+					WHERE id = $7`,
+					model.Name, model.Description, model.Price,
+					model.Categories, model.Allergens, model.Metadata, id)
+				if err != nil {
+					return fmt.Errorf("update %q: %w", item.Name, err)
+				}
+				if _, err := tx.ExecContext(ctx, "DELETE FROM menu_item_ingredients WHERE menu_item_id = $1", id); err != nil {
+					return err
+				}
+				result.Updated++
+			}
+
+			if err := insertIngredients(ctx, tx, id, item.Ingredients); err != nil {
+				return fmt.Errorf("insert ingredients for %q: %w", item.Name, err)
+			}
+		}
 		return nil
 	})
+	if err != nil {
+		return entity.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+// ExportMenuItems streams every menu item with its ingredients to fn in id order.
+func (s *menuRepository) ExportMenuItems(ctx context.Context, fn func(item entity.MenuItem) error) error {
+	const op = "Store.ExportMenuItems"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.name, COALESCE(m.description, ''), m.price,
+			COALESCE(m.categories, '{}'), COALESCE(m.allergens, '{}'), COALESCE(m.metadata, '{}'),
+			m.created_at, m.updated_at,
+			COALESCE(json_agg(json_build_object(
+				'item_id', i.id, 'name', i.item_name, 'quantity', mi.quantity_used,
+				'unit', i.unit, 'price', i.price
+			) ORDER BY i.id) FILTER (WHERE i.id IS NOT NULL), '[]')
+		FROM menu_items m
+		LEFT JOIN menu_item_ingredients mi ON mi.menu_item_id = m.id
+		LEFT JOIN inventory i ON i.id = mi.ingredient_id
+		GROUP BY m.id
+		ORDER BY m.id`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var model models.MenuItem
+		var ingredientsJSON []byte
+		err := rows.Scan(
+			&model.ID,
+			&model.Name,
+			&model.Description,
+			&model.Price,
+			&model.Categories,
+			&model.Allergens,
+			&model.Metadata,
+			&model.CreatedAt,
+			&model.UpdatedAt,
+			&ingredientsJSON,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		var ingredients []struct {
+			ItemID   int64   `json:"item_id"`
+			Name     string  `json:"name"`
+			Quantity float64 `json:"quantity"`
+			Unit     string  `json:"unit"`
+			Price    float64 `json:"price"`
+		}
+		if err := json.Unmarshal(ingredientsJSON, &ingredients); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		menuIngredients := make([]entity.MenuIngredient, 0, len(ingredients))
+		for _, ing := range ingredients {
+			menuIngredients = append(menuIngredients, entity.MenuIngredient(ing))
+		}
+
+		if err := fn(mapper.ToMenuItemEntity(model, menuIngredients)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func sameIngredients(a, b []entity.MenuIngredient) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	quantities := make(map[int64]float64, len(a))
+	for _, ing := range a {
+		quantities[ing.ItemID] = ing.Quantity
+	}
+	for _, ing := range b {
+		if q, ok := quantities[ing.ItemID]; !ok || q != ing.Quantity {
+			return false
+		}
+	}
+	return true
 }
 
 // func (s *menuRepository) UpdateByID(ctx context.Context, id int64, item entity.MenuItem) error {
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"frappuccino-alem/internal/config"
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// OrderEventsChannel is the NOTIFY channel the orders triggers publish to.
+const OrderEventsChannel = "order_events"
+
+// listenerPingInterval keeps idle LISTEN connections from being dropped by
+// proxies and lets a dead connection be noticed without waiting for traffic.
+const listenerPingInterval = 90 * time.Second
+
+type orderNotification struct {
+	Type           string    `json:"type"`
+	OrderID        int64     `json:"order_id"`
+	Status         string    `json:"status"`
+	PreviousStatus *string   `json:"previous_status"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// OrderEventListener receives order events over a dedicated LISTEN
+// connection, so every app instance sees changes made by any other one.
+type OrderEventListener struct {
+	cfg    config.DataBase
+	logger *slog.Logger
+}
+
+func NewOrderEventListener(cfg config.DataBase, logger *slog.Logger) *OrderEventListener {
+	return &OrderEventListener{cfg, logger}
+}
+
+// Listen blocks until ctx is cancelled and calls fn for every event. The
+// connection is re-established automatically; events raised while it is
+// down are lost.
+func (l *OrderEventListener) Listen(ctx context.Context, fn func(entity.OrderEvent)) error {
+	const op = "store.OrderEventListener.Listen"
+
+	listener := pq.NewListener(l.cfg.MakeConnectionString(), l.cfg.ConnectBackoff, l.cfg.MaxBackoff,
+		func(ev pq.ListenerEventType, err error) {
+			switch ev {
+			case pq.ListenerEventDisconnected:
+				l.logger.Warn("order event listener disconnected", "error", err)
+			case pq.ListenerEventConnectionAttemptFailed:
+				l.logger.Warn("order event listener failed to reconnect", "error", err)
+			case pq.ListenerEventReconnected:
+				l.logger.Info("order event listener reconnected")
+			}
+		})
+	defer listener.Close()
+
+	if err := listener.Listen(OrderEventsChannel); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// nil is sent after a reconnect
+			if n == nil {
+				continue
+			}
+			event, err := parseOrderNotification(n.Extra)
+			if err != nil {
+				l.logger.Error("malformed order event", "payload", n.Extra, "error", err.Error())
+				continue
+			}
+			fn(event)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func parseOrderNotification(payload string) (entity.OrderEvent, error) {
+	var n orderNotification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return entity.OrderEvent{}, err
+	}
+	event := entity.OrderEvent{
+		Type:           n.Type,
+		OrderID:        n.OrderID,
+		Status:         entity.ParseStatus(n.Status),
+		PreviousStatus: entity.OrderStatus(-1),
+		OccurredAt:     n.OccurredAt,
+	}
+	if n.PreviousStatus != nil {
+		event.PreviousStatus = entity.ParseStatus(*n.PreviousStatus)
+	}
+	return event, nil
+}
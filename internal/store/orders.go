@@ -3,12 +3,16 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"frappuccino-alem/internal/entity"
 	"frappuccino-alem/internal/handlers/dto"
 	"frappuccino-alem/models"
 	"frappuccino-alem/models/mapper"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type OrderStore struct {
@@ -26,11 +30,14 @@ func (r *OrderStore) CreateOrder(ctx context.Context, order entity.Order) (int64
 		modelOrder := mapper.ToOrderModel(order)
 
 		err := tx.QueryRowContext(ctx,
-			`INSERT INTO orders (customer_name, status, total_amount, payment_method, special_instructions)
-			VALUES ($1, $2, $3, $4, $5)
+			`INSERT INTO orders (customer_name, staff_id, status, total_amount, payment_method, special_instructions)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			RETURNING id`,
-			modelOrder.CustomerName, modelOrder.Status, modelOrder.TotalAmount, modelOrder.PaymentMethod, modelOrder.SpecialInstructions,
+			modelOrder.CustomerName, modelOrder.StaffID, modelOrder.Status, modelOrder.TotalAmount, modelOrder.PaymentMethod, modelOrder.SpecialInstructions,
 		).Scan(&id)
+		if isForeignKeyViolation(err, "orders_staff_id_fkey") {
+			return fmt.Errorf("%w: staff member %d does not exist", ErrInvalidInput, *order.StaffID)
+		}
 		if err != nil {
 			return fmt.Errorf("insert order: %w", err)
 		}
@@ -66,7 +73,7 @@ func (r *OrderStore) GetAllOrders(ctx context.Context, pagination *dto.Paginatio
 	const op = "Store.GetAllOrders"
 
 	query := `
-		SELECT id, customer_name, payment_method, total_amount, status, created_at, updated_at
+		SELECT id, customer_name, staff_id, payment_method, total_amount, status, created_at, updated_at
 		FROM orders o
 	`
 
@@ -89,6 +96,7 @@ func (r *OrderStore) GetAllOrders(ctx context.Context, pagination *dto.Paginatio
 		err := rows.Scan(
 			&model.ID,
 			&model.CustomerName,
+			&model.StaffID,
 			&model.PaymentMethod,
 			&model.TotalAmount,
 			&model.Status,
@@ -156,11 +164,124 @@ func (r *OrderStore) getMenuItemsForOrder(ctx context.Context, orderID int) ([]e
 
 func (r *OrderStore) GetOrderById(ctx context.Context, orderId string) (entity.Order, error) {
 	const op = "Store.GetOrderById"
-	var order entity.Order
 
-	// logic here ...
+	var model models.Order
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, customer_name, staff_id, payment_method, total_amount, status,
+			COALESCE(special_instructions, '{}'), created_at, updated_at
+		FROM orders
+		WHERE id = $1`, orderId,
+	).Scan(
+		&model.ID,
+		&model.CustomerName,
+		&model.StaffID,
+		&model.PaymentMethod,
+		&model.TotalAmount,
+		&model.Status,
+		&model.SpecialInstructions,
+		&model.CreatedAt,
+		&model.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.Order{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, err := r.getMenuItemsForOrder(ctx, model.ID)
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return mapper.ToOrderEntity(model, items), nil
+}
+
+// GetKitchenQueue returns pending and processing orders, oldest first.
+func (r *OrderStore) GetKitchenQueue(ctx context.Context) ([]entity.Order, error) {
+	const op = "Store.GetKitchenQueue"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, customer_name, staff_id, payment_method, total_amount, status,
+			COALESCE(special_instructions, '{}'), created_at, updated_at
+		FROM orders
+		WHERE status IN ('pending', 'processing')
+		ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var modelItems []models.Order
+	for rows.Next() {
+		var model models.Order
+		err := rows.Scan(
+			&model.ID,
+			&model.CustomerName,
+			&model.StaffID,
+			&model.PaymentMethod,
+			&model.TotalAmount,
+			&model.Status,
+			&model.SpecialInstructions,
+			&model.CreatedAt,
+			&model.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		modelItems = append(modelItems, model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	return order, nil
+	ids := make([]int64, 0, len(modelItems))
+	for _, model := range modelItems {
+		ids = append(ids, int64(model.ID))
+	}
+	items, err := r.getMenuItemsForOrders(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	orders := make([]entity.Order, 0, len(modelItems))
+	for _, model := range modelItems {
+		orders = append(orders, mapper.ToOrderEntity(model, items[model.ID]))
+	}
+	return orders, nil
+}
+
+// getMenuItemsForOrders loads the items of several orders in one query,
+// keyed by order id.
+func (r *OrderStore) getMenuItemsForOrders(ctx context.Context, orderIDs []int64) (map[int][]entity.OrderItem, error) {
+	const op = "Store.getMenuItemsForOrders"
+
+	items := make(map[int][]entity.OrderItem, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT oi.order_id, mi.id, mi.name, mi.price, oi.quantity
+		FROM order_items oi
+		JOIN menu_items mi ON mi.id = oi.menu_item_id
+		WHERE oi.order_id = ANY($1)
+		ORDER BY oi.order_id, oi.id`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var item entity.OrderItem
+		if err := rows.Scan(&orderID, &item.ID, &item.Name, &item.Price, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items[orderID] = append(items[orderID], item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
 }
 
 func (r *OrderStore) GetTotalOrdersCount(ctx context.Context) (int, error) {
@@ -190,14 +311,197 @@ func (r *OrderStore) DeleteOrderById(ctx context.Context, orderId string) error
 	return nil
 }
 
+// CloseOrderById completes an open order: the ingredients of its items are
+// deducted from inventory, and the order.completed event plus any
+// inventory.low_stock events are written to the outbox in the same transaction.
 func (r *OrderStore) CloseOrderById(ctx context.Context, orderId string) error {
 	const op = "Store.CloseOrderById"
 
-	// logic here ...
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		var model models.Order
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, customer_name, payment_method, total_amount, status
+			FROM orders
+			WHERE id = $1
+			FOR UPDATE`, orderId,
+		).Scan(&model.ID, &model.CustomerName, &model.PaymentMethod, &model.TotalAmount, &model.Status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		status := entity.ParseStatus(model.Status)
+		if status != entity.OrderPending && status != entity.OrderProcessing {
+			return fmt.Errorf("%w: order is already %s", ErrConflict, status)
+		}
 
+		if err := consumeOrderIngredients(ctx, tx, int64(model.ID)); err != nil {
+			return err
+		}
+
+		var completedAt time.Time
+		err = tx.QueryRowContext(ctx,
+			"UPDATE orders SET status = 'completed', updated_at = NOW() WHERE id = $1 RETURNING updated_at",
+			model.ID).Scan(&completedAt)
+		if err != nil {
+			return fmt.Errorf("update order: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO order_status_history (order_id, status) VALUES ($1, 'completed')", model.ID)
+		if err != nil {
+			return fmt.Errorf("insert status history: %w", err)
+		}
+
+		items, err := getOrderItemsForEvent(ctx, tx, int64(model.ID))
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, entity.EventOrderCompleted, orderCompletedPayload{
+			OrderID:       int64(model.ID),
+			CustomerName:  model.CustomerName,
+			PaymentMethod: model.PaymentMethod,
+			TotalAmount:   model.TotalAmount,
+			Items:         items,
+			CompletedAt:   completedAt,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
+type orderCompletedPayload struct {
+	OrderID       int64                   `json:"order_id"`
+	CustomerName  string                  `json:"customer_name"`
+	PaymentMethod string                  `json:"payment_method"`
+	TotalAmount   float64                 `json:"total_amount"`
+	Items         []orderItemEventPayload `json:"items"`
+	CompletedAt   time.Time               `json:"completed_at"`
+}
+
+type orderItemEventPayload struct {
+	MenuItemID int64  `json:"menu_item_id"`
+	Name       string `json:"name"`
+	Quantity   int64  `json:"quantity"`
+	// LineTotal is what the line cost when ordered (price_at_order).
+	LineTotal float64 `json:"line_total"`
+}
+
+// consumeOrderIngredients deducts what the order's items use from inventory
+// and logs each deduction. It fails with ErrConflict if anything runs short.
+func consumeOrderIngredients(ctx context.Context, tx *sql.Tx, orderID int64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT i.id, i.item_name, i.quantity, i.unit, i.reorder_level, u.used
+		FROM inventory i
+		JOIN (
+			SELECT mii.ingredient_id, SUM(mii.quantity_used * oi.quantity) AS used
+			FROM order_items oi
+			JOIN menu_item_ingredients mii ON mii.menu_item_id = oi.menu_item_id
+			WHERE oi.order_id = $1
+			GROUP BY mii.ingredient_id
+		) u ON u.ingredient_id = i.id
+		ORDER BY i.id
+		FOR UPDATE OF i`, orderID)
+	if err != nil {
+		return fmt.Errorf("load ingredients: %w", err)
+	}
+	defer rows.Close()
+
+	type usage struct {
+		item entity.InventoryItem
+		used float64
+	}
+	var usages []usage
+	for rows.Next() {
+		var u usage
+		err := rows.Scan(&u.item.ID, &u.item.ItemName, &u.item.Quantity, &u.item.Unit, &u.item.ReorderLevel, &u.used)
+		if err != nil {
+			return fmt.Errorf("load ingredients: %w", err)
+		}
+		usages = append(usages, u)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("load ingredients: %w", err)
+	}
+
+	// stock past its date is written off before anything is handed out, so
+	// only fresh and untracked stock can cover the order
+	ids := make([]int64, len(usages))
+	for i, u := range usages {
+		ids[i] = u.item.ID
+	}
+	wasted, err := wasteExpiredBatches(ctx, tx, time.Now(), ids)
+	if err != nil {
+		return fmt.Errorf("waste expired stock: %w", err)
+	}
+	for _, entry := range wasted {
+		for i := range usages {
+			if usages[i].item.ID == entry.InventoryID {
+				usages[i].item.Quantity -= entry.Quantity
+			}
+		}
+	}
+
+	for _, u := range usages {
+		if u.item.Quantity < u.used {
+			return fmt.Errorf("%w: not enough %s in stock", ErrConflict, u.item.ItemName)
+		}
+	}
+
+	for _, u := range usages {
+		wasLow := u.item.IsLow()
+		u.item.Quantity -= u.used
+
+		_, err := tx.ExecContext(ctx,
+			"UPDATE inventory SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2",
+			u.used, u.item.ID)
+		if err != nil {
+			return fmt.Errorf("deduct %s: %w", u.item.ItemName, err)
+		}
+		if err := drawBatches(ctx, tx, u.item.ID, u.used); err != nil {
+			return fmt.Errorf("deduct %s: %w", u.item.ItemName, err)
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO inventory_transactions (inventory_id, quantity_change, reason) VALUES ($1, $2, $3)",
+			u.item.ID, -u.used, fmt.Sprintf("order #%d", orderID))
+		if err != nil {
+			return fmt.Errorf("log usage of %s: %w", u.item.ItemName, err)
+		}
+
+		if !wasLow && u.item.IsLow() {
+			if err := insertLowStockEvent(ctx, tx, u.item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getOrderItemsForEvent(ctx context.Context, tx *sql.Tx, orderID int64) ([]orderItemEventPayload, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT oi.menu_item_id, mi.name, oi.quantity, oi.price_at_order
+		FROM order_items oi
+		JOIN menu_items mi ON mi.id = oi.menu_item_id
+		WHERE oi.order_id = $1
+		ORDER BY oi.id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("load order items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]orderItemEventPayload, 0)
+	for rows.Next() {
+		var item orderItemEventPayload
+		if err := rows.Scan(&item.MenuItemID, &item.Name, &item.Quantity, &item.LineTotal); err != nil {
+			return nil, fmt.Errorf("load order items: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
 func (r *OrderStore) GetNumberOfOrderedItems(ctx context.Context, startDate, endDate string) (map[string]int, error) {
 	const op = "Store.GetNumberOfOrderedItems"
 
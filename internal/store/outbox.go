@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// insertOutboxEvent records an event in the outbox as part of tx, so it is
+// published if and only if the change it describes is committed.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox_events (event_type, payload) VALUES ($1, $2)",
+		eventType, data)
+	if err != nil {
+		return fmt.Errorf("insert %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+type lowStockPayload struct {
+	InventoryID  int64     `json:"inventory_id"`
+	Name         string    `json:"name"`
+	Quantity     float64   `json:"quantity"`
+	Unit         string    `json:"unit"`
+	ReorderLevel float64   `json:"reorder_level"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+func insertLowStockEvent(ctx context.Context, tx *sql.Tx, item entity.InventoryItem) error {
+	return insertOutboxEvent(ctx, tx, entity.EventInventoryLowStock, lowStockPayload{
+		InventoryID:  item.ID,
+		Name:         item.ItemName,
+		Quantity:     item.Quantity,
+		Unit:         item.Unit,
+		ReorderLevel: item.ReorderLevel,
+		OccurredAt:   time.Now().UTC(),
+	})
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"frappuccino-alem/internal/config"
+
+	"github.com/lib/pq"
+)
+
+// Connect opens the connection pool, applies the configured limits and pings
+// the database, retrying with exponential backoff so the app can start
+// before Postgres is ready to accept connections.
+func Connect(ctx context.Context, cfg config.DataBase, logger *slog.Logger) (*sql.DB, error) {
+	const op = "Store.Connect"
+
+	db, err := sql.Open("postgres", cfg.MakeConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			break
+		}
+
+		logger.Warn("database is not ready, retrying",
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+			"error", err.Error())
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		}
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+
+	db.Close()
+	return nil, fmt.Errorf("%s: database unreachable after %d attempts: %w", op, cfg.ConnectRetries+1, err)
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation of
+// the named constraint.
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == constraint
+}
@@ -0,0 +1,376 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// ReasonRestock marks ledger entries of stock received from a purchase order.
+const ReasonRestock = "restock"
+
+type PurchaseOrderStore struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderStore(db *sql.DB) *PurchaseOrderStore {
+	return &PurchaseOrderStore{db}
+}
+
+const purchaseOrderColumns = `po.id, po.supplier_id, s.name, po.status, po.notes,
+	po.expected_at, po.sent_at, po.received_at, po.created_at, po.updated_at`
+
+func scanPurchaseOrder(row interface{ Scan(...any) error }) (entity.PurchaseOrder, error) {
+	var po entity.PurchaseOrder
+	var expectedAt, sentAt, receivedAt sql.NullTime
+	err := row.Scan(&po.ID, &po.SupplierID, &po.SupplierName, &po.Status, &po.Notes,
+		&expectedAt, &sentAt, &receivedAt, &po.CreatedAt, &po.UpdatedAt)
+	if expectedAt.Valid {
+		po.ExpectedAt = &expectedAt.Time
+	}
+	if sentAt.Valid {
+		po.SentAt = &sentAt.Time
+	}
+	if receivedAt.Valid {
+		po.ReceivedAt = &receivedAt.Time
+	}
+	po.Lines = make([]entity.PurchaseOrderLine, 0)
+	return po, err
+}
+
+func (r *PurchaseOrderStore) CreatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error) {
+	const op = "Store.CreatePurchaseOrder"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO purchase_orders (supplier_id, notes, expected_at)
+			VALUES ($1, $2, $3)
+			RETURNING id`,
+			po.SupplierID, po.Notes, po.ExpectedAt,
+		).Scan(&po.ID)
+		if isForeignKeyViolation(err, "purchase_orders_supplier_id_fkey") {
+			return fmt.Errorf("%w: supplier %d does not exist", ErrInvalidInput, po.SupplierID)
+		}
+		if err != nil {
+			return err
+		}
+		return insertPurchaseOrderLines(ctx, tx, po.ID, po.Lines)
+	})
+	if err != nil {
+		return entity.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetPurchaseOrderById(ctx, po.ID)
+}
+
+// GetPurchaseOrders lists purchase orders, newest first, optionally only
+// those with the given status.
+func (r *PurchaseOrderStore) GetPurchaseOrders(ctx context.Context, status string) ([]entity.PurchaseOrder, error) {
+	const op = "Store.GetPurchaseOrders"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+purchaseOrderColumns+`
+		FROM purchase_orders po
+		JOIN suppliers s ON s.id = po.supplier_id
+		WHERE $1 = '' OR po.status::text = $1
+		ORDER BY po.id DESC`, status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	orders := make([]entity.PurchaseOrder, 0)
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orders = append(orders, po)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loadPurchaseOrderLines(ctx, r.db, orders); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return orders, nil
+}
+
+func (r *PurchaseOrderStore) GetPurchaseOrderById(ctx context.Context, id int64) (entity.PurchaseOrder, error) {
+	const op = "Store.GetPurchaseOrderById"
+
+	po, err := getPurchaseOrder(ctx, r.db, id, "")
+	if err != nil {
+		return po, fmt.Errorf("%s: %w", op, err)
+	}
+	return po, nil
+}
+
+// UpdatePurchaseOrder replaces the notes, expected date and lines of a
+// draft. Orders that were sent fail with ErrConflict.
+func (r *PurchaseOrderStore) UpdatePurchaseOrder(ctx context.Context, po entity.PurchaseOrder) (entity.PurchaseOrder, error) {
+	const op = "Store.UpdatePurchaseOrder"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		current, err := getPurchaseOrder(ctx, tx, po.ID, "FOR UPDATE OF po")
+		if err != nil {
+			return err
+		}
+		if current.Status != entity.PurchaseOrderDraft {
+			return fmt.Errorf("%w: purchase order is %s, only drafts can be changed", ErrConflict, current.Status)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE purchase_orders
+			SET notes = $2, expected_at = $3, updated_at = NOW()
+			WHERE id = $1`, po.ID, po.Notes, po.ExpectedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM purchase_order_lines WHERE purchase_order_id = $1", po.ID); err != nil {
+			return err
+		}
+		return insertPurchaseOrderLines(ctx, tx, po.ID, po.Lines)
+	})
+	if err != nil {
+		return entity.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetPurchaseOrderById(ctx, po.ID)
+}
+
+// DeletePurchaseOrder deletes a draft. Orders that were sent are kept for
+// the record and fail with ErrConflict.
+func (r *PurchaseOrderStore) DeletePurchaseOrder(ctx context.Context, id int64) error {
+	const op = "Store.DeletePurchaseOrder"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		current, err := getPurchaseOrder(ctx, tx, id, "FOR UPDATE OF po")
+		if err != nil {
+			return err
+		}
+		if current.Status != entity.PurchaseOrderDraft {
+			return fmt.Errorf("%w: purchase order is %s, only drafts can be deleted", ErrConflict, current.Status)
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM purchase_orders WHERE id = $1", id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SendPurchaseOrder marks a draft as sent to the supplier.
+func (r *PurchaseOrderStore) SendPurchaseOrder(ctx context.Context, id int64) (entity.PurchaseOrder, error) {
+	const op = "Store.SendPurchaseOrder"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		current, err := getPurchaseOrder(ctx, tx, id, "FOR UPDATE OF po")
+		if err != nil {
+			return err
+		}
+		if current.Status != entity.PurchaseOrderDraft {
+			return fmt.Errorf("%w: purchase order is already %s", ErrConflict, current.Status)
+		}
+		if len(current.Lines) == 0 {
+			return fmt.Errorf("%w: purchase order has no lines", ErrInvalidInput)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE purchase_orders
+			SET status = 'sent', sent_at = NOW(), updated_at = NOW()
+			WHERE id = $1`, id)
+		return err
+	})
+	if err != nil {
+		return entity.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetPurchaseOrderById(ctx, id)
+}
+
+// ReceivePurchaseOrder books delivered stock. Every receipt adds to the
+// inventory as a new batch, moves the item's price to the weighted average
+// of the stock on hand and the delivery, and posts a restock entry to the
+// ledger. Without receipts everything still outstanding is received at the
+// ordered cost.
+// The order becomes received once every line is complete and partially
+// received before that.
+func (r *PurchaseOrderStore) ReceivePurchaseOrder(ctx context.Context, id int64, receipts []entity.PurchaseOrderReceipt) (entity.PurchaseOrder, error) {
+	const op = "Store.ReceivePurchaseOrder"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		po, err := getPurchaseOrder(ctx, tx, id, "FOR UPDATE OF po")
+		if err != nil {
+			return err
+		}
+		if po.Status != entity.PurchaseOrderSent && po.Status != entity.PurchaseOrderPartiallyReceived {
+			return fmt.Errorf("%w: purchase order is %s, only sent orders can be received", ErrConflict, po.Status)
+		}
+
+		lines := make(map[int64]*entity.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			lines[po.Lines[i].InventoryID] = &po.Lines[i]
+		}
+		if len(receipts) == 0 {
+			for _, line := range po.Lines {
+				if line.Remaining() > 0 {
+					receipts = append(receipts, entity.PurchaseOrderReceipt{InventoryID: line.InventoryID, Quantity: line.Remaining()})
+				}
+			}
+		}
+
+		for _, receipt := range receipts {
+			line, ok := lines[receipt.InventoryID]
+			if !ok {
+				return fmt.Errorf("%w: inventory item %d is not on the purchase order", ErrInvalidInput, receipt.InventoryID)
+			}
+			if receipt.Quantity > line.Remaining() {
+				return fmt.Errorf("%w: only %g %s of %s are outstanding", ErrInvalidInput, line.Remaining(), line.Unit, line.ItemName)
+			}
+			unitCost := line.UnitCost
+			if receipt.UnitCost != nil {
+				unitCost = *receipt.UnitCost
+			}
+
+			// SET expressions all see the row as it was before the update
+			_, err := tx.ExecContext(ctx, `
+				UPDATE inventory
+				SET price = ROUND((quantity * price + $2 * $3) / (quantity + $2), 2),
+					quantity = quantity + $2,
+					updated_at = NOW()
+				WHERE id = $1`, receipt.InventoryID, receipt.Quantity, unitCost)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO inventory_transactions (inventory_id, quantity_change, reason, unit_cost, purchase_order_id)
+				VALUES ($1, $2, $3, $4, $5)`,
+				receipt.InventoryID, receipt.Quantity, ReasonRestock, unitCost, id)
+			if err != nil {
+				return err
+			}
+			err = insertBatch(ctx, tx, &entity.InventoryBatch{
+				InventoryID:     receipt.InventoryID,
+				LotCode:         receipt.LotCode,
+				Quantity:        receipt.Quantity,
+				UnitCost:        &unitCost,
+				ExpiresAt:       receipt.ExpiresAt,
+				PurchaseOrderID: &id,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `
+				UPDATE purchase_order_lines
+				SET quantity_received = quantity_received + $2
+				WHERE id = $1`, line.ID, receipt.Quantity)
+			if err != nil {
+				return err
+			}
+			line.QuantityReceived += receipt.Quantity
+		}
+
+		status := entity.PurchaseOrderReceived
+		for _, line := range po.Lines {
+			if line.Remaining() > 0 {
+				status = entity.PurchaseOrderPartiallyReceived
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE purchase_orders
+			SET status = $2,
+				received_at = CASE WHEN $2 = 'received' THEN NOW() END,
+				updated_at = NOW()
+			WHERE id = $1`, id, status)
+		return err
+	})
+	if err != nil {
+		return entity.PurchaseOrder{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetPurchaseOrderById(ctx, id)
+}
+
+// rowQuerier is satisfied by *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	querier
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// getPurchaseOrder reads a purchase order with its lines; lock is appended
+// to the query of the order row.
+func getPurchaseOrder(ctx context.Context, q rowQuerier, id int64, lock string) (entity.PurchaseOrder, error) {
+	po, err := scanPurchaseOrder(q.QueryRowContext(ctx, `
+		SELECT `+purchaseOrderColumns+`
+		FROM purchase_orders po
+		JOIN suppliers s ON s.id = po.supplier_id
+		WHERE po.id = $1 `+lock, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return po, ErrNotFound
+	}
+	if err != nil {
+		return po, err
+	}
+
+	orders := []entity.PurchaseOrder{po}
+	if err := loadPurchaseOrderLines(ctx, q, orders); err != nil {
+		return po, err
+	}
+	return orders[0], nil
+}
+
+func insertPurchaseOrderLines(ctx context.Context, tx *sql.Tx, poID int64, lines []entity.PurchaseOrderLine) error {
+	for _, line := range lines {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO purchase_order_lines (purchase_order_id, inventory_id, quantity_ordered, unit_cost)
+			VALUES ($1, $2, $3, $4)`,
+			poID, line.InventoryID, line.QuantityOrdered, line.UnitCost)
+		if isForeignKeyViolation(err, "purchase_order_lines_inventory_id_fkey") {
+			return fmt.Errorf("%w: inventory item %d does not exist", ErrInvalidInput, line.InventoryID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadPurchaseOrderLines(ctx context.Context, q querier, orders []entity.PurchaseOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(orders))
+	ids := make([]int64, 0, len(orders))
+	for i, po := range orders {
+		index[po.ID] = i
+		ids = append(ids, po.ID)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT l.purchase_order_id, l.id, l.inventory_id, i.item_name, i.unit,
+			l.quantity_ordered, l.quantity_received, l.unit_cost
+		FROM purchase_order_lines l
+		JOIN inventory i ON i.id = l.inventory_id
+		WHERE l.purchase_order_id = ANY($1)
+		ORDER BY l.purchase_order_id, l.id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var poID int64
+		var line entity.PurchaseOrderLine
+		err := rows.Scan(&poID, &line.ID, &line.InventoryID, &line.ItemName, &line.Unit,
+			&line.QuantityOrdered, &line.QuantityReceived, &line.UnitCost)
+		if err != nil {
+			return err
+		}
+		po := &orders[index[poID]]
+		po.Lines = append(po.Lines, line)
+	}
+	return rows.Err()
+}
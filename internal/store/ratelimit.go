@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+)
+
+// RateLimitStore keeps token buckets in Postgres so every instance of the
+// app draws from the same buckets. Bucket times come from the database
+// clock for the same reason.
+type RateLimitStore struct {
+	db *sql.DB
+}
+
+func NewRateLimitStore(db *sql.DB) *RateLimitStore {
+	return &RateLimitStore{db}
+}
+
+// Take spends a token from the bucket under key, creating a full bucket for
+// a key seen for the first time.
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit entity.RateLimit) (entity.RateLimitDecision, error) {
+	const op = "Store.RateLimit.Take"
+
+	var decision entity.RateLimitDecision
+	err := runInTx(s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO rate_limit_buckets (bucket_key, tokens, updated_at, full_at)
+			VALUES ($1, $2, NOW(), NOW())
+			ON CONFLICT (bucket_key) DO NOTHING`,
+			key, limit.Burst)
+		if err != nil {
+			return err
+		}
+
+		var bucket entity.RateBucket
+		var now time.Time
+		err = tx.QueryRowContext(ctx, `
+			SELECT tokens, updated_at, NOW()
+			FROM rate_limit_buckets
+			WHERE bucket_key = $1
+			FOR UPDATE`,
+			key,
+		).Scan(&bucket.Tokens, &bucket.UpdatedAt, &now)
+		if err != nil {
+			return err
+		}
+
+		bucket, decision = limit.Take(bucket, now)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE rate_limit_buckets
+			SET tokens = $2, updated_at = $3, full_at = $4
+			WHERE bucket_key = $1`,
+			key, bucket.Tokens, bucket.UpdatedAt, now.Add(decision.Reset))
+		return err
+	})
+	if err != nil {
+		return entity.RateLimitDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return decision, nil
+}
+
+// DeleteFull drops buckets that have refilled completely; a missing bucket
+// starts out full anyway.
+func (s *RateLimitStore) DeleteFull(ctx context.Context) (int64, error) {
+	const op = "Store.RateLimit.DeleteFull"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.RowsAffected()
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory. It suits a
+// single instance; behind a load balancer each instance would apply the
+// limits separately.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	nextSweep time.Time
+}
+
+type memoryBucket struct {
+	entity.RateBucket
+	fullAt time.Time
+}
+
+// memorySweepInterval is how often full buckets are dropped from memory.
+const memorySweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]memoryBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit entity.RateLimit) (entity.RateLimitDecision, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.nextSweep) {
+		for k, b := range s.buckets {
+			if !b.fullAt.After(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.nextSweep = now.Add(memorySweepInterval)
+	}
+
+	bucket, decision := limit.Take(s.buckets[key].RateBucket, now)
+	s.buckets[key] = memoryBucket{RateBucket: bucket, fullAt: now.Add(decision.Reset)}
+	return decision, nil
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"frappuccino-alem/internal/entity"
 	"time"
-
-	"github.com/lib/pq"
 )
 
 type ReportStore struct {
@@ -18,211 +16,263 @@ func NewReportStore(db *sql.DB) *ReportStore {
 	return &ReportStore{db}
 }
 
-func (r *ReportStore) GetPopularItems(ctx context.Context) ([]entity.PopularItem, error) {
-	const op = "Store.GetPopularItems"
+// reportDimension holds the SQL for one breakdown of a report.
+type reportDimension struct {
+	key, label string
+	// lineLevel dimensions are attributes of order lines, not whole orders
+	lineLevel bool
+	join      string
+}
 
-	var popularItems []entity.PopularItem
+var reportDimensions = map[string]reportDimension{
+	"": {key: "''", label: "''"},
+	entity.BreakdownPaymentMethod: {
+		key:   "o.payment_method::text",
+		label: "o.payment_method::text",
+	},
+	entity.BreakdownStaff: {
+		key:   "COALESCE(o.staff_id::text, 'unassigned')",
+		label: "COALESCE(s.name, 'Unassigned')",
+		join:  "LEFT JOIN staff s ON s.id = o.staff_id",
+	},
+	entity.BreakdownMenuItem: {
+		key:       "mi.id::text",
+		label:     "mi.name",
+		lineLevel: true,
+	},
+	entity.BreakdownCategory: {
+		key:       "c.category",
+		label:     "c.category",
+		lineLevel: true,
+		join: `CROSS JOIN LATERAL unnest(
+			CASE WHEN cardinality(mi.categories) > 0 THEN mi.categories ELSE ARRAY['uncategorized'] END
+		) AS c(category)`,
+	},
+}
 
-	query := `
-		SELECT 
-			order_items.menu_item_id, 
-			menu_items.name AS item, 
-			COUNT(*) AS order_count
-		FROM order_items
-		JOIN orders ON order_items.order_id = orders.id
-		JOIN menu_items ON order_items.menu_item_id = menu_items.id
-		WHERE orders.status = 'completed'
-		GROUP BY order_items.menu_item_id, menu_items.name
-		ORDER BY order_count DESC
-		LIMIT 10
-	`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+// reportBucket returns the expression that puts an order in its period.
+// Periods are computed in UTC.
+func reportBucket(groupBy string) (string, error) {
+	switch groupBy {
+	case "":
+		return "NULL::timestamp", nil
+	case entity.GroupByHour, entity.GroupByDay, entity.GroupByWeek, entity.GroupByMonth:
+		return fmt.Sprintf("date_trunc('%s', o.created_at AT TIME ZONE 'UTC')", groupBy), nil
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var item entity.PopularItem
-		if err := rows.Scan(&item.ProductId, &item.ProductName, &item.Sold); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		popularItems = append(popularItems, item)
+	return "", fmt.Errorf("%w: unknown group_by %q", ErrInvalidInput, groupBy)
+}
+
+func reportParts(filter entity.ReportFilter) (bucket string, dim reportDimension, err error) {
+	bucket, err = reportBucket(filter.GroupBy)
+	if err != nil {
+		return "", dim, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	dim, ok := reportDimensions[filter.Breakdown]
+	if !ok {
+		return "", dim, fmt.Errorf("%w: unknown breakdown %q", ErrInvalidInput, filter.Breakdown)
 	}
-
-	return popularItems, nil
+	return bucket, dim, nil
 }
 
-func (r *ReportStore) GetTotalSales(ctx context.Context) (float64, error) {
-	const op = "Store.GetPopularItems"
+// reportRange is the WHERE clause shared by the sales reports; $1 and $2
+// are the bounds from reportArgs.
+const reportRange = `o.status = 'completed'
+		AND ($1::timestamptz IS NULL OR o.created_at >= $1)
+		AND o.created_at < $2`
 
-	var totalSales float64
-	query := `SELECT COALESCE(SUM(total_amount), 0) FROM orders WHERE status = 'completed'`
-	if err := r.db.QueryRowContext(ctx, query).Scan(&totalSales); err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+func reportArgs(filter entity.ReportFilter) []any {
+	var from sql.NullTime
+	if !filter.From.IsZero() {
+		from = sql.NullTime{Time: filter.From, Valid: true}
 	}
+	return []any{from, filter.To}
+}
 
-	return totalSales, nil
+// reportOrder sorts the bucket (1) and key (2) columns of an aggregation.
+func reportOrder(filter entity.ReportFilter) string {
+	if filter.KeyFirst {
+		return "2, 1"
+	}
+	return "1, 2"
 }
 
-func (s *ReportStore) SearchMenuItems(ctx context.Context, query string, minPrice, maxPrice float64) ([]entity.SearchMenuItem, error) {
-	const op = "ReportStore.SearchMenuItems"
-
-	q := `
-         SELECT id, name, description, price,
-               ts_rank(search_vector, websearch_to_tsquery('english', $1)) AS relevance
-        FROM menu_items
-        WHERE search_vector @@ websearch_to_tsquery('english', $1)
-        AND (CAST($2 AS DECIMAL) = 0 OR price >= CAST($2 AS DECIMAL))
-        AND (CAST($3 AS DECIMAL) = 0 OR price <= CAST($3 AS DECIMAL))
-        ORDER BY relevance DESC
-    `
+// GetPopularItems ranks menu items by quantity sold, streaming the top
+// filter.Limit items of every period and breakdown key to fn in bucket, key
+// and rank order.
+func (r *ReportStore) GetPopularItems(ctx context.Context, filter entity.ReportFilter, fn func(entity.PopularItemRow) error) error {
+	const op = "Store.GetPopularItems"
 
-	rows, err := s.db.QueryContext(ctx, q,
-		query,
-		minPrice,
-		maxPrice,
-	)
+	bucket, dim, err := reportParts(filter)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-	defer rows.Close()
-
-	var items []entity.SearchMenuItem
-	for rows.Next() {
-		var item entity.SearchMenuItem
-		if err := rows.Scan(
-			&item.ID,
-			&item.Name,
-			&item.Description,
-			&item.Price,
-			&item.Relevance,
-		); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		items = append(items, item)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return items, nil
-}
-
-func (s *ReportStore) SearchOrders(ctx context.Context, query string, minPrice, maxPrice float64) ([]entity.SearchOrder, error) {
-	const op = "ReportStore.SearchOrders"
-
-	q := `
-	SELECT 
-		o.id, 
-		o.customer_name, 
-		o.total_amount,
-		ts_rank(o.search_vector, websearch_to_tsquery('english', $1)) AS relevance,
-		array_agg(mi.name) AS items
-	FROM orders o
-	JOIN order_items oi ON o.id = oi.order_id
-	JOIN menu_items mi ON oi.menu_item_id = mi.id
-	WHERE 
-		o.search_vector @@ websearch_to_tsquery('english', $1)
-		AND (CAST($2 AS DECIMAL) = 0 OR o.total_amount >= CAST($2 AS DECIMAL))
-		AND (CAST($3 AS DECIMAL) = 0 OR o.total_amount <= CAST($3 AS DECIMAL))
-	GROUP BY o.id
-	ORDER BY relevance DESC
-	`
-
-	rows, err := s.db.QueryContext(ctx, q,
-		query,
-		minPrice,
-		maxPrice,
-	)
+	query := fmt.Sprintf(`
+		SELECT bucket, key, label, menu_item_id, name, quantity, revenue
+		FROM (
+			SELECT
+				%[1]s AS bucket,
+				%[2]s AS key,
+				%[3]s AS label,
+				mi.id AS menu_item_id,
+				mi.name,
+				SUM(oi.quantity) AS quantity,
+				SUM(oi.price_at_order) AS revenue,
+				ROW_NUMBER() OVER (
+					PARTITION BY %[1]s, %[2]s
+					ORDER BY SUM(oi.quantity) DESC, mi.name
+				) AS rank
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			JOIN menu_items mi ON mi.id = oi.menu_item_id
+			%[4]s
+			WHERE %[5]s
+			GROUP BY 1, 2, 3, mi.id, mi.name
+		) ranked
+		WHERE rank <= $3
+		ORDER BY bucket, key, rank`,
+		bucket, dim.key, dim.label, dim.join, reportRange)
+
+	rows, err := r.db.QueryContext(ctx, query, append(reportArgs(filter), filter.Limit)...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var orders []entity.SearchOrder
 	for rows.Next() {
-		var order entity.SearchOrder
-		var items pq.StringArray
-		if err := rows.Scan(
-			&order.ID,
-			&order.CustomerName,
-			&order.Total,
-			&order.Relevance,
-			&items,
-		); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		var row entity.PopularItemRow
+		var bucketTime sql.NullTime
+		if err := rows.Scan(&bucketTime, &row.Key, &row.Label,
+			&row.Item.ProductId, &row.Item.ProductName, &row.Item.Sold, &row.Item.Revenue); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
-		order.Items = items
-		orders = append(orders, order)
+		if bucketTime.Valid {
+			row.Bucket = bucketTime.Time.UTC()
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return orders, nil
+	return nil
 }
 
-func (s *ReportStore) GetTotalItemsByDay(ctx context.Context, month int, year int) (map[int]int, error) {
-	const op = "ReportStore.GetTotalItemsByDay"
+// GetTotalSales sums completed orders per period and breakdown key,
+// streaming the sums to fn in the order filter asks for. Periods without
+// sales are skipped.
+func (r *ReportStore) GetTotalSales(ctx context.Context, filter entity.ReportFilter, fn func(entity.SalesRow) error) error {
+	const op = "Store.GetTotalSales"
 
-	query := `
-        SELECT EXTRACT(DAY FROM created_at)::integer AS day, 
-               COUNT(id) AS order_count
-        FROM orders
-        WHERE EXTRACT(MONTH FROM created_at) = $1 
-          AND EXTRACT(YEAR FROM created_at) = $2
-        GROUP BY day
-        ORDER BY day
-    `
+	bucket, dim, err := reportParts(filter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// line-level dimensions split orders, so revenue comes from the lines
+	revenue, source := "o.total_amount", "orders o"
+	if dim.lineLevel {
+		revenue = "oi.price_at_order"
+		source = `orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			JOIN menu_items mi ON mi.id = oi.menu_item_id`
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, month, year)
+	query := fmt.Sprintf(`
+		SELECT %s AS bucket, %s AS key, %s AS label,
+			COALESCE(SUM(%s), 0), COUNT(DISTINCT o.id)
+		FROM %s
+		%s
+		WHERE %s
+		GROUP BY 1, 2, 3
+		ORDER BY %s`,
+		bucket, dim.key, dim.label, revenue, source, dim.join, reportRange, reportOrder(filter))
+
+	rows, err := r.db.QueryContext(ctx, query, reportArgs(filter)...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	results := make(map[int]int)
 	for rows.Next() {
-		var day int
-		var count int
-		if err := rows.Scan(&day, &count); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		var row entity.SalesRow
+		var bucketTime sql.NullTime
+		if err := rows.Scan(&bucketTime, &row.Key, &row.Label, &row.Revenue, &row.OrderCount); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if bucketTime.Valid {
+			row.Bucket = bucketTime.Time.UTC()
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
-		results[day] = count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return results, nil
+	return nil
 }
 
-func (s *ReportStore) GetTotalItemsByMonth(ctx context.Context, year int) (map[int]int, error) {
-	const op = "ReportStore.GetTotalItemsByMonth"
+// GetItemsByPeriod counts the items of all orders that were not cancelled,
+// per period and, with the menu_item breakdown, per menu item. Counts are
+// streamed to fn in the order filter asks for; periods without sales are
+// skipped.
+func (s *ReportStore) GetItemsByPeriod(ctx context.Context, filter entity.ReportFilter, fn func(entity.ItemsRow) error) error {
+	const op = "ReportStore.GetItemsByPeriod"
 
-	query := `
-        SELECT EXTRACT(MONTH FROM created_at)::integer AS month, 
-               COUNT(id) AS order_count
-        FROM orders
-        WHERE EXTRACT(YEAR FROM created_at) = $1
-        GROUP BY month
-        ORDER BY month
-    `
+	bucket, dim, err := reportParts(filter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, year)
+	query := fmt.Sprintf(`
+		SELECT %s AS bucket, %s AS key, %s AS label,
+			SUM(oi.quantity), SUM(oi.price_at_order)
+		FROM orders o
+		JOIN order_items oi ON oi.order_id = o.id
+		JOIN menu_items mi ON mi.id = oi.menu_item_id
+		%s
+		WHERE o.status <> 'cancelled'
+			AND ($1::timestamptz IS NULL OR o.created_at >= $1)
+			AND o.created_at < $2
+		GROUP BY 1, 2, 3
+		ORDER BY %s`,
+		bucket, dim.key, dim.label, dim.join, reportOrder(filter))
+
+	rows, err := s.db.QueryContext(ctx, query, reportArgs(filter)...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	results := make(map[int]int)
 	for rows.Next() {
-		var month int
-		var count int
-		if err := rows.Scan(&month, &count); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		var row entity.ItemsRow
+		var bucketTime sql.NullTime
+		if err := rows.Scan(&bucketTime, &row.Key, &row.Label, &row.ItemCount, &row.Revenue); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if bucketTime.Valid {
+			row.Bucket = bucketTime.Time.UTC()
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
-		results[month] = count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return results, nil
+	return nil
 }
 
-func (r *ReportStore) GetOrderedItemsReport(ctx context.Context, startDate, endDate time.Time) (entity.NumberOfOrderedItemsByPeriod, error) {
+// GetOrderedItems streams how many of every menu item were ordered between
+// startDate and endDate to fn, by name.
+func (r *ReportStore) GetOrderedItems(ctx context.Context, startDate, endDate time.Time, fn func(name string, quantity int) error) error {
+	const op = "Store.GetOrderedItems"
+
 	query := `
         SELECT 
             mi.name AS item_name,
@@ -237,26 +287,141 @@ func (r *ReportStore) GetOrderedItemsReport(ctx context.Context, startDate, endD
                 (o.created_at <= $2 OR $2 IS NULL)
         ) oi ON mi.id = oi.menu_item_id
         GROUP BY mi.name
+        ORDER BY mi.name
     `
 
 	rows, err := r.db.QueryContext(ctx, query, startDate, endDate)
 	if err != nil {
-		return entity.NumberOfOrderedItemsByPeriod{}, err
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	report := entity.NumberOfOrderedItemsByPeriod{
-		OrderedItems: make(map[string]int),
-	}
-
 	for rows.Next() {
 		var name string
 		var quantity int
 		if err := rows.Scan(&name, &quantity); err != nil {
-			return entity.NumberOfOrderedItemsByPeriod{}, err
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(name, quantity); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetInventoryUsage returns, for every inventory item, how much left stock in
+// [from, to) according to outgoing ledger entries and according to completed
+// orders times the recipes of their menu items. Waste and adjustments are
+// not usage and are left out of the ledger figures, as in cost of goods
+// sold.
+func (r *ReportStore) GetInventoryUsage(ctx context.Context, from, to time.Time) ([]entity.InventoryUsage, error) {
+	const op = "Store.GetInventoryUsage"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT i.id, i.item_name, i.unit, i.quantity, i.price, i.reorder_level,
+			COALESCE(l.used, 0), COALESCE(l.entries, 0), COALESCE(o.used, 0)
+		FROM inventory i
+		LEFT JOIN (
+			SELECT inventory_id, -SUM(quantity_change) AS used, COUNT(*) AS entries
+			FROM inventory_transactions
+			WHERE quantity_change < 0 AND reason NOT IN ($3, $4) AND created_at >= $1 AND created_at < $2
+			GROUP BY inventory_id
+		) l ON l.inventory_id = i.id
+		LEFT JOIN (
+			SELECT mii.ingredient_id, SUM(oi.quantity * mii.quantity_used) AS used
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			JOIN menu_item_ingredients mii ON mii.menu_item_id = oi.menu_item_id
+			WHERE o.status = 'completed' AND o.created_at >= $1 AND o.created_at < $2
+			GROUP BY mii.ingredient_id
+		) o ON o.ingredient_id = i.id
+		ORDER BY i.id`, from, to, ReasonWaste, ReasonAdjustment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var usage []entity.InventoryUsage
+	for rows.Next() {
+		var u entity.InventoryUsage
+		if err := rows.Scan(&u.InventoryID, &u.Name, &u.Unit, &u.Quantity, &u.Price, &u.ReorderLevel,
+			&u.LedgerUsage, &u.LedgerEntries, &u.OrderUsage); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		report.OrderedItems[name] = quantity
+		usage = append(usage, u)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return usage, nil
+}
 
-	return report, nil
+// GetCostedItems returns every inventory item with the balance of its whole
+// ledger.
+func (r *ReportStore) GetCostedItems(ctx context.Context) ([]entity.CostedItem, error) {
+	const op = "Store.GetCostedItems"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT i.id, i.item_name, i.unit, i.quantity, i.price, COALESCE(SUM(t.quantity_change), 0), i.created_at
+		FROM inventory i
+		LEFT JOIN inventory_transactions t ON t.inventory_id = i.id
+		GROUP BY i.id
+		ORDER BY i.id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var items []entity.CostedItem
+	for rows.Next() {
+		var item entity.CostedItem
+		if err := rows.Scan(&item.InventoryID, &item.Name, &item.Unit, &item.Quantity, &item.Price,
+			&item.LedgerBalance, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+// GetInventoryLedger streams every inventory transaction before to, oldest
+// first.
+func (r *ReportStore) GetInventoryLedger(ctx context.Context, to time.Time, fn func(entity.LedgerEntry) error) error {
+	const op = "Store.GetInventoryLedger"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.inventory_id, t.quantity_change, t.reason, t.unit_cost,
+			COALESCE(t.waste_reason::text, ''), t.staff_id, COALESCE(s.name, ''), t.created_at
+		FROM inventory_transactions t
+		LEFT JOIN staff s ON s.id = t.staff_id
+		WHERE t.created_at < $1
+		ORDER BY t.created_at, t.id`, to)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e entity.LedgerEntry
+		if err := rows.Scan(&e.InventoryID, &e.QuantityChange, &e.Reason, &e.UnitCost,
+			&e.WasteReason, &e.StaffID, &e.StaffName, &e.CreatedAt); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
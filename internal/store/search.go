@@ -0,0 +1,345 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// searchSimilarity is the pg_trgm word similarity from which a text counts
+// as a fuzzy match of the search.
+const searchSimilarity = 0.4
+
+// searchHeadline formats ts_headline snippets. Snippets are HTML, so the
+// text goes through escapeHTML first and the marks are the only markup.
+const searchHeadline = "StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5, MaxFragments=2"
+
+// escapeHTML wraps a SQL text expression so that the text reads as HTML
+// text rather than markup.
+func escapeHTML(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// searchQueryCTE parses the search text ($1) once: the websearch query for
+// whole words, or'ed with a prefix query ($2) so that partial words match.
+const searchQueryCTE = `
+	WITH q AS (
+		SELECT websearch_to_tsquery('english', $1) || to_tsquery('english', $2) AS query
+	)`
+
+// prefixTSQuery turns free text into a to_tsquery expression matching every
+// word as a prefix, such as "frap:* & moch:*". Everything but letters and
+// digits is dropped, so the result is always valid syntax.
+func prefixTSQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// likePrefix escapes text for use as the start of a LIKE pattern.
+func likePrefix(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// searchArgs are the parameters every search query starts with: the text
+// ($1, $2), the fuzzy match threshold ($3), the price range ($4, $5, NULL
+// when open) and the page ($6, $7). A zero Limit passes NULL, which
+// postgres reads as no limit.
+func searchArgs(query entity.SearchQuery) []any {
+	var limit sql.NullInt64
+	if query.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(query.Limit), Valid: true}
+	}
+	return []any{query.Text, prefixTSQuery(query.Text), searchSimilarity,
+		query.MinPrice, query.MaxPrice, limit, query.Offset}
+}
+
+// orderFilterArgs follow searchArgs in queries that narrow orders: the
+// created_at range ($8, $9) and statuses ($10).
+func orderFilterArgs(query entity.SearchQuery) []any {
+	var from, to sql.NullTime
+	if !query.From.IsZero() {
+		from = sql.NullTime{Time: query.From, Valid: true}
+	}
+	if !query.To.IsZero() {
+		to = sql.NullTime{Time: query.To, Valid: true}
+	}
+	return []any{from, to, pq.StringArray(query.Statuses)}
+}
+
+// orderFilter is the condition orderFilterArgs fill in, on orders aliased o.
+const orderFilter = `($8::TIMESTAMPTZ IS NULL OR o.created_at >= $8)
+			AND ($9::TIMESTAMPTZ IS NULL OR o.created_at < $9)
+			AND (COALESCE(cardinality($10::TEXT[]), 0) = 0 OR o.status::TEXT = ANY($10))`
+
+// searchTotal counts the matches of a search whose page came back empty.
+// The total rides along on every row of a page, so a page past the end
+// cannot tell it; the first match is fetched again for it.
+func searchTotal[T any](ctx context.Context, query entity.SearchQuery,
+	search func(context.Context, entity.SearchQuery, func(T) error) (int, error)) (int, error) {
+	query.Limit, query.Offset = 1, 0
+	return search(ctx, query, func(T) error { return nil })
+}
+
+// SearchMenuItems streams a page of the menu items matching the query to fn,
+// most relevant first, and returns how many match in all.
+func (s *ReportStore) SearchMenuItems(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchMenuItem) error) (int, error) {
+	const op = "ReportStore.SearchMenuItems"
+
+	rows, err := s.db.QueryContext(ctx, searchQueryCTE+`
+		SELECT m.id, m.name, COALESCE(m.description, ''), m.price,
+			ts_rank(m.search_vector, q.query) + 0.5 * word_similarity($1, m.name) AS relevance,
+			ts_headline('english', `+escapeHTML("m.name || ': ' || COALESCE(m.description, '')")+`, q.query, '`+searchHeadline+`'),
+			COUNT(*) OVER ()
+		FROM menu_items m, q
+		WHERE (m.search_vector @@ q.query OR word_similarity($1, m.name) >= $3)
+			AND ($4::DECIMAL IS NULL OR m.price >= $4)
+			AND ($5::DECIMAL IS NULL OR m.price <= $5)
+		ORDER BY relevance DESC, m.id
+		LIMIT $6 OFFSET $7`,
+		searchArgs(query)...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var item entity.SearchMenuItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.Relevance, &item.Snippet, &total); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(item); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if total == 0 && query.Offset > 0 {
+		return searchTotal(ctx, query, s.SearchMenuItems)
+	}
+	return total, nil
+}
+
+// SearchOrders streams a page of the orders matching the query by customer
+// or menu item to fn, most relevant first, and returns how many match in all.
+func (s *ReportStore) SearchOrders(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchOrder) error) (int, error) {
+	const op = "ReportStore.SearchOrders"
+
+	rows, err := s.db.QueryContext(ctx, searchQueryCTE+`
+		SELECT o.id, o.customer_name, o.total_amount, o.status, o.created_at,
+			ts_rank(o.search_vector, q.query)
+				+ 0.5 * GREATEST(word_similarity($1, o.customer_name), word_similarity($1, i.names)) AS relevance,
+			ts_headline('english', `+escapeHTML("o.customer_name || ': ' || i.names")+`, q.query, '`+searchHeadline+`'),
+			i.items,
+			COUNT(*) OVER ()
+		FROM orders o
+		CROSS JOIN q
+		JOIN LATERAL (
+			SELECT array_agg(mi.name ORDER BY oi.id) AS items, string_agg(mi.name, ', ' ORDER BY oi.id) AS names
+			FROM order_items oi
+			JOIN menu_items mi ON mi.id = oi.menu_item_id
+			WHERE oi.order_id = o.id
+		) i ON i.items IS NOT NULL
+		WHERE (o.search_vector @@ q.query
+				OR word_similarity($1, o.customer_name) >= $3
+				OR word_similarity($1, i.names) >= $3)
+			AND ($4::DECIMAL IS NULL OR o.total_amount >= $4)
+			AND ($5::DECIMAL IS NULL OR o.total_amount <= $5)
+			AND `+orderFilter+`
+		ORDER BY relevance DESC, o.id DESC
+		LIMIT $6 OFFSET $7`,
+		append(searchArgs(query), orderFilterArgs(query)...)...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var order entity.SearchOrder
+		var items pq.StringArray
+		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Total, &order.Status, &order.CreatedAt,
+			&order.Relevance, &order.Snippet, &items, &total); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		order.Items = items
+		if err := fn(order); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if total == 0 && query.Offset > 0 {
+		return searchTotal(ctx, query, s.SearchOrders)
+	}
+	return total, nil
+}
+
+// SearchInventory streams a page of the inventory items whose name matches
+// the query to fn, most relevant first, and returns how many match in all.
+func (s *ReportStore) SearchInventory(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchInventoryItem) error) (int, error) {
+	const op = "ReportStore.SearchInventory"
+
+	rows, err := s.db.QueryContext(ctx, searchQueryCTE+`
+		SELECT i.id, i.item_name, i.unit, i.quantity, i.price,
+			ts_rank(to_tsvector('english', i.item_name), q.query) + 0.5 * word_similarity($1, i.item_name) AS relevance,
+			ts_headline('english', `+escapeHTML("i.item_name")+`, q.query, '`+searchHeadline+`'),
+			COUNT(*) OVER ()
+		FROM inventory i, q
+		WHERE (to_tsvector('english', i.item_name) @@ q.query OR word_similarity($1, i.item_name) >= $3)
+			AND ($4::DECIMAL IS NULL OR i.price >= $4)
+			AND ($5::DECIMAL IS NULL OR i.price <= $5)
+		ORDER BY relevance DESC, i.id
+		LIMIT $6 OFFSET $7`,
+		searchArgs(query)...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var item entity.SearchInventoryItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.Unit, &item.Quantity, &item.Price, &item.Relevance, &item.Snippet, &total); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(item); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if total == 0 && query.Offset > 0 {
+		return searchTotal(ctx, query, s.SearchInventory)
+	}
+	return total, nil
+}
+
+// SearchCustomers streams a page of the customer names matching the query
+// to fn, most relevant first, and returns how many match in all. Only
+// orders passing the price, date and status filters count towards a
+// customer.
+func (s *ReportStore) SearchCustomers(ctx context.Context, query entity.SearchQuery, fn func(entity.SearchCustomer) error) (int, error) {
+	const op = "ReportStore.SearchCustomers"
+
+	rows, err := s.db.QueryContext(ctx, searchQueryCTE+`
+		SELECT o.customer_name, COUNT(*), SUM(o.total_amount), MAX(o.created_at),
+			ts_rank(to_tsvector('english', o.customer_name), q.query)
+				+ 0.5 * word_similarity($1, o.customer_name) AS relevance,
+			ts_headline('english', `+escapeHTML("o.customer_name")+`, q.query, '`+searchHeadline+`'),
+			COUNT(*) OVER ()
+		FROM orders o, q
+		WHERE (to_tsvector('english', o.customer_name) @@ q.query OR word_similarity($1, o.customer_name) >= $3)
+			AND ($4::DECIMAL IS NULL OR o.total_amount >= $4)
+			AND ($5::DECIMAL IS NULL OR o.total_amount <= $5)
+			AND `+orderFilter+`
+		GROUP BY o.customer_name, q.query
+		ORDER BY relevance DESC, o.customer_name
+		LIMIT $6 OFFSET $7`,
+		append(searchArgs(query), orderFilterArgs(query)...)...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var c entity.SearchCustomer
+		if err := rows.Scan(&c.Name, &c.OrderCount, &c.TotalSpent, &c.LastOrderAt, &c.Relevance, &c.Snippet, &total); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(c); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if total == 0 && query.Offset > 0 {
+		return searchTotal(ctx, query, s.SearchCustomers)
+	}
+	return total, nil
+}
+
+// Suggest completes a search for menu item and customer names. Names that
+// start with the text rank first, then names with a word starting with it,
+// then names that are merely similar.
+func (s *ReportStore) Suggest(ctx context.Context, text string, limit int) ([]entity.SearchSuggestion, error) {
+	const op = "ReportStore.Suggest"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT type, id, text, score
+		FROM (
+			SELECT $5::TEXT AS type, m.id::BIGINT AS id, m.name AS text,
+				CASE WHEN m.name ILIKE $1 || '%' THEN 2 WHEN m.name ILIKE '% ' || $1 || '%' THEN 1 ELSE 0 END
+					+ word_similarity($2, m.name) AS score
+			FROM menu_items m
+			WHERE m.name ILIKE $1 || '%' OR m.name ILIKE '% ' || $1 || '%' OR word_similarity($2, m.name) >= $3
+			UNION ALL
+			SELECT $6::TEXT, NULL, o.customer_name,
+				CASE WHEN o.customer_name ILIKE $1 || '%' THEN 2 WHEN o.customer_name ILIKE '% ' || $1 || '%' THEN 1 ELSE 0 END
+					+ word_similarity($2, o.customer_name)
+			FROM orders o
+			WHERE o.customer_name ILIKE $1 || '%' OR o.customer_name ILIKE '% ' || $1 || '%'
+				OR word_similarity($2, o.customer_name) >= $3
+			GROUP BY o.customer_name
+		) s
+		ORDER BY score DESC, text
+		LIMIT $4`,
+		likePrefix(text), text, searchSimilarity, limit, entity.SuggestionMenuItem, entity.SuggestionCustomer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	suggestions := make([]entity.SearchSuggestion, 0)
+	for rows.Next() {
+		var sg entity.SearchSuggestion
+		if err := rows.Scan(&sg.Type, &sg.ID, &sg.Text, &sg.Score); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		suggestions = append(suggestions, sg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return suggestions, nil
+}
+
+// ReindexOrders recomputes the search vector of every order and returns how
+// many orders it went through. The triggers keep the vectors current; this
+// repairs them after the search document changes or data is loaded around
+// the triggers.
+func (s *ReportStore) ReindexOrders(ctx context.Context) (int64, error) {
+	const op = "ReportStore.ReindexOrders"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE orders SET search_vector = order_search_vector(id, customer_name)")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+type SupplierStore struct {
+	db *sql.DB
+}
+
+func NewSupplierStore(db *sql.DB) *SupplierStore {
+	return &SupplierStore{db}
+}
+
+const supplierColumns = "id, name, contact_name, email, phone, address, created_at, updated_at"
+
+func scanSupplier(row interface{ Scan(...any) error }) (entity.Supplier, error) {
+	var s entity.Supplier
+	err := row.Scan(&s.ID, &s.Name, &s.ContactName, &s.Email, &s.Phone, &s.Address, &s.CreatedAt, &s.UpdatedAt)
+	s.Items = make([]entity.SupplierItem, 0)
+	return s, err
+}
+
+func (r *SupplierStore) CreateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error) {
+	const op = "Store.CreateSupplier"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO suppliers (name, contact_name, email, phone, address)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at, updated_at`,
+			supplier.Name, supplier.ContactName, supplier.Email, supplier.Phone, supplier.Address,
+		).Scan(&supplier.ID, &supplier.CreatedAt, &supplier.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return insertSupplierItems(ctx, tx, supplier.ID, supplier.Items)
+	})
+	if err != nil {
+		return entity.Supplier{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetSupplierById(ctx, supplier.ID)
+}
+
+func (r *SupplierStore) GetSuppliers(ctx context.Context) ([]entity.Supplier, error) {
+	const op = "Store.GetSuppliers"
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+supplierColumns+" FROM suppliers ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	suppliers := make([]entity.Supplier, 0)
+	for rows.Next() {
+		s, err := scanSupplier(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		suppliers = append(suppliers, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loadSupplierItems(ctx, r.db, suppliers); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return suppliers, nil
+}
+
+func (r *SupplierStore) GetSupplierById(ctx context.Context, id int64) (entity.Supplier, error) {
+	const op = "Store.GetSupplierById"
+
+	s, err := scanSupplier(r.db.QueryRowContext(ctx, "SELECT "+supplierColumns+" FROM suppliers WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return s, fmt.Errorf("%s: %w", op, err)
+	}
+
+	suppliers := []entity.Supplier{s}
+	if err := loadSupplierItems(ctx, r.db, suppliers); err != nil {
+		return s, fmt.Errorf("%s: %w", op, err)
+	}
+	return suppliers[0], nil
+}
+
+// UpdateSupplier replaces a supplier's details and price list.
+func (r *SupplierStore) UpdateSupplier(ctx context.Context, supplier entity.Supplier) (entity.Supplier, error) {
+	const op = "Store.UpdateSupplier"
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE suppliers
+			SET name = $2, contact_name = $3, email = $4, phone = $5, address = $6, updated_at = NOW()
+			WHERE id = $1`,
+			supplier.ID, supplier.Name, supplier.ContactName, supplier.Email, supplier.Phone, supplier.Address)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM supplier_items WHERE supplier_id = $1", supplier.ID); err != nil {
+			return err
+		}
+		return insertSupplierItems(ctx, tx, supplier.ID, supplier.Items)
+	})
+	if err != nil {
+		return entity.Supplier{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return r.GetSupplierById(ctx, supplier.ID)
+}
+
+// DeleteSupplier fails with ErrConflict while purchase orders refer to the
+// supplier.
+func (r *SupplierStore) DeleteSupplier(ctx context.Context, id int64) error {
+	const op = "Store.DeleteSupplier"
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM suppliers WHERE id = $1", id)
+	if isForeignKeyViolation(err, "purchase_orders_supplier_id_fkey") {
+		return fmt.Errorf("%s: %w: supplier has purchase orders", op, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+func insertSupplierItems(ctx context.Context, tx *sql.Tx, supplierID int64, items []entity.SupplierItem) error {
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO supplier_items (supplier_id, inventory_id, unit_price, lead_time_days)
+			VALUES ($1, $2, $3, $4)`,
+			supplierID, item.InventoryID, item.UnitPrice, item.LeadTimeDays)
+		if isForeignKeyViolation(err, "supplier_items_inventory_id_fkey") {
+			return fmt.Errorf("%w: inventory item %d does not exist", ErrInvalidInput, item.InventoryID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSupplierItems fills in the price lists of suppliers.
+func loadSupplierItems(ctx context.Context, q querier, suppliers []entity.Supplier) error {
+	if len(suppliers) == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(suppliers))
+	ids := make([]int64, 0, len(suppliers))
+	for i, s := range suppliers {
+		index[s.ID] = i
+		ids = append(ids, s.ID)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT si.supplier_id, si.inventory_id, i.item_name, i.unit, si.unit_price, si.lead_time_days
+		FROM supplier_items si
+		JOIN inventory i ON i.id = si.inventory_id
+		WHERE si.supplier_id = ANY($1)
+		ORDER BY si.supplier_id, i.item_name`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var supplierID int64
+		var item entity.SupplierItem
+		if err := rows.Scan(&supplierID, &item.InventoryID, &item.ItemName, &item.Unit, &item.UnitPrice, &item.LeadTimeDays); err != nil {
+			return err
+		}
+		s := &suppliers[index[supplierID]]
+		s.Items = append(s.Items, item)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,291 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"frappuccino-alem/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func NewWebhookStore(db *sql.DB) *WebhookStore {
+	return &WebhookStore{db}
+}
+
+func (r *WebhookStore) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error) {
+	const op = "Store.CreateSubscription"
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (url, event_types, secret, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		sub.URL, pq.Array(sub.EventTypes), sub.Secret, sub.Active,
+	).Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return entity.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return sub, nil
+}
+
+func (r *WebhookStore) GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	const op = "Store.GetSubscriptions"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, url, event_types, secret, active, created_at, updated_at
+		FROM webhook_subscriptions
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	subs := make([]entity.WebhookSubscription, 0)
+	for rows.Next() {
+		var sub entity.WebhookSubscription
+		err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return subs, nil
+}
+
+func (r *WebhookStore) GetSubscriptionById(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	const op = "Store.GetSubscriptionById"
+
+	var sub entity.WebhookSubscription
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, url, event_types, secret, active, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE id = $1`, id,
+	).Scan(&sub.ID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sub, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return sub, fmt.Errorf("%s: %w", op, err)
+	}
+	return sub, nil
+}
+
+func (r *WebhookStore) DeleteSubscription(ctx context.Context, id int64) error {
+	const op = "Store.DeleteSubscription"
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// GetDeliveries returns the most recent deliveries of a subscription together
+// with their attempt log, newest first.
+func (r *WebhookStore) GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]entity.WebhookDelivery, error) {
+	const op = "Store.GetDeliveries"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT d.id, d.subscription_id, d.event_id, e.event_type, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, COALESCE(d.last_error, ''), d.delivered_at, d.created_at
+		FROM webhook_deliveries d
+		JOIN outbox_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2`, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]entity.WebhookDelivery, 0)
+	index := make(map[int64]int)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var d entity.WebhookDelivery
+		var statusCode sql.NullInt64
+		var deliveredAt sql.NullTime
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &statusCode, &d.LastError, &deliveredAt, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			d.LastStatusCode = &code
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		d.Log = make([]entity.WebhookAttempt, 0)
+		index[d.ID] = len(deliveries)
+		ids = append(ids, d.ID)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	attemptRows, err := r.db.QueryContext(ctx, `
+		SELECT delivery_id, attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY delivery_id, attempt`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var a entity.WebhookAttempt
+		var durationMs int64
+		if err := attemptRows.Scan(&a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &durationMs, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		a.Duration = time.Duration(durationMs) * time.Millisecond
+		d := &deliveries[index[a.DeliveryID]]
+		d.Log = append(d.Log, a)
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return deliveries, nil
+}
+
+// FanOutEvents turns up to limit undispatched outbox events into one
+// delivery per matching active subscription and marks the events dispatched.
+// It returns the number of events handled.
+func (r *WebhookStore) FanOutEvents(ctx context.Context, limit int) (int, error) {
+	const op = "Store.FanOutEvents"
+
+	res, err := r.db.ExecContext(ctx, `
+		WITH events AS (
+			SELECT id, event_type
+			FROM outbox_events
+			WHERE dispatched_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (subscription_id, event_id)
+			SELECT s.id, e.id
+			FROM events e
+			JOIN webhook_subscriptions s ON s.active AND e.event_type = ANY(s.event_types)
+			ON CONFLICT (subscription_id, event_id) DO NOTHING
+		)
+		UPDATE outbox_events
+		SET dispatched_at = NOW()
+		WHERE id IN (SELECT id FROM events)`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return int(n), nil
+}
+
+// ClaimDeliveries picks up to limit due deliveries and pushes their next
+// attempt lease into the future, so that a dispatcher that dies mid-send
+// does not leave them stuck and concurrent dispatchers never share one.
+func (r *WebhookStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entity.WebhookDispatch, error) {
+	const op = "Store.ClaimDeliveries"
+
+	rows, err := r.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1,
+			next_attempt_at = NOW() + make_interval(secs => $2),
+			updated_at = NOW()
+		FROM due, webhook_subscriptions s, outbox_events e
+		WHERE d.id = due.id AND s.id = d.subscription_id AND e.id = d.event_id
+		RETURNING d.id, d.attempts, s.url, s.secret, e.id, e.event_type, e.payload, e.created_at`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var dispatches []entity.WebhookDispatch
+	for rows.Next() {
+		var d entity.WebhookDispatch
+		err := rows.Scan(&d.DeliveryID, &d.Attempt, &d.URL, &d.Secret, &d.EventID, &d.EventType, &d.Payload, &d.EventCreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		dispatches = append(dispatches, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return dispatches, nil
+}
+
+// RecordAttempt appends to the delivery log and moves the delivery to
+// status. nextAttemptAt only matters while the delivery stays pending.
+func (r *WebhookStore) RecordAttempt(ctx context.Context, attempt entity.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	const op = "Store.RecordAttempt"
+
+	var statusCode sql.NullInt64
+	if attempt.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(attempt.StatusCode), Valid: true}
+	}
+	var attemptErr sql.NullString
+	if attempt.Error != "" {
+		attemptErr = sql.NullString{String: attempt.Error, Valid: true}
+	}
+
+	err := runInTx(r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			attempt.DeliveryID, attempt.Attempt, statusCode, attemptErr, attempt.Duration.Milliseconds(), attempt.AttemptedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $2,
+				next_attempt_at = $3,
+				last_status_code = $4,
+				last_error = $5,
+				delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END,
+				updated_at = NOW()
+			WHERE id = $1`,
+			attempt.DeliveryID, status, nextAttemptAt, statusCode, attemptErr)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
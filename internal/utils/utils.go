@@ -2,17 +2,71 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrUnsupportedMediaType is returned by ParseJSON for a body that is not
+// declared as JSON.
+var ErrUnsupportedMediaType = errors.New("Content-Type must be application/json")
+
+// ParseJSON decodes a JSON request body into v. The body must be declared as
+// application/json or another +json type, hold exactly one JSON value and
+// use only properties v knows about, so a misspelt property is an error
+// rather than silently dropped. A body over the server's size limit fails
+// with *http.MaxBytesError.
 func ParseJSON(r *http.Request, v any) error {
-	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+	if r.Body == nil || r.Body == http.NoBody {
+		return errors.New("missing request body")
+	}
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return ErrUnsupportedMediaType
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
 	}
-	// decoder := json.NewDecoder(r.Body)
-	// decoder.DisallowUnknownFields()
-	return json.NewDecoder(r.Body).Decode(v)
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errors.New("request body must hold a single JSON value")
+	}
+	return nil
+}
+
+// decodeError rewords encoding/json errors for API clients.
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("missing request body")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("malformed JSON: unexpected end of body")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Errorf("%s must be of type %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("request body must be of type %s", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		return fmt.Errorf("unknown property %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
@@ -28,3 +82,20 @@ func WriteMessage(w http.ResponseWriter, status int, msg string) {
 func WriteError(w http.ResponseWriter, status int, err error) {
 	WriteJSON(w, status, map[string]string{"error": err.Error()})
 }
+
+// ETag builds a strong entity tag from a row's identity and last modification time.
+func ETag(id int64, updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d-%s"`, id, strconv.FormatInt(updatedAt.UnixMicro(), 36))
+}
+
+// MatchesETag reports whether an If-Match header value matches etag using the
+// strong comparison required for If-Match: "*" matches anything, weak tags never match.
+func MatchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
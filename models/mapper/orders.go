@@ -10,6 +10,7 @@ func ToOrderModel(e entity.Order) models.Order {
 	return models.Order{
 		ID:                  int(e.ID),
 		CustomerName:        e.CustomerName,
+		StaffID:             e.StaffID,
 		TotalAmount:         e.TotalAmount,
 		Status:              e.Status.String(),
 		PaymentMethod:       e.PaymentMethod.String(),
@@ -23,6 +24,7 @@ func ToOrderEntity(m models.Order, items []entity.OrderItem) entity.Order {
 	return entity.Order{
 		ID:                  int64(m.ID),
 		CustomerName:        m.CustomerName,
+		StaffID:             m.StaffID,
 		TotalAmount:         m.TotalAmount,
 		Status:              entity.ParseStatus(m.Status),
 		PaymentMethod:       entity.ParsePaymentMethod(m.PaymentMethod),
@@ -7,6 +7,7 @@ import (
 type Order struct {
 	ID                  int       `json:"id"`
 	CustomerName        string    `json:"customer_name"`
+	StaffID             *int64    `json:"staff_id,omitempty"`
 	TotalAmount         float64   `json:"total_amount"`
 	Status              string    `json:"status"`
 	PaymentMethod       string    `json:"payment_method"`
@@ -93,10 +93,10 @@ func (h *PrettyHandler) WithGroup(name string) slog.Handler {
 	}
 }
 
-func SetupPrettySlog(output io.Writer) *slog.Logger {
+func SetupPrettySlog(output io.Writer, level slog.Level) *slog.Logger {
 	opts := PrettyHandlerOptions{
 		SlogOpts: &slog.HandlerOptions{
-			Level: slog.LevelDebug,
+			Level: level,
 		},
 	}
 
@@ -0,0 +1,220 @@
+// Package xlsx writes single-sheet Office Open XML spreadsheets row by row.
+// Only the parts a spreadsheet needs are written and strings are stored
+// inline, so rows go straight to the output instead of being collected
+// first.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRows is the row limit of a spreadsheet.
+const maxRows = 1048576
+
+var ErrTooManyRows = errors.New("xlsx: sheet is full")
+
+// Writer streams one worksheet. Create it with NewWriter, call WriteRow for
+// every row and Close at the end; the file is invalid without Close.
+type Writer struct {
+	zw   *zip.Writer
+	bw   *bufio.Writer
+	rows int
+	err  error
+}
+
+// NewWriter writes the workbook parts and opens the sheet called name.
+func NewWriter(w io.Writer, name string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName(name)))},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, xml.Header+part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// the sheet is the last part, so it can be written while rows arrive
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(f)
+	bw.WriteString(xml.Header + sheetStart)
+	return &Writer{zw: zw, bw: bw}, nil
+}
+
+// WriteRow appends a row. Numbers and times become numeric cells, nil an
+// empty cell and anything else a string.
+func (w *Writer) WriteRow(values []any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.rows == maxRows {
+		w.err = ErrTooManyRows
+		return w.err
+	}
+	w.rows++
+
+	fmt.Fprintf(w.bw, `<row r="%d">`, w.rows)
+	for i, v := range values {
+		ref := column(i) + strconv.Itoa(w.rows)
+		switch v := v.(type) {
+		case nil:
+		case int:
+			writeNumber(w.bw, ref, float64(v), "")
+		case int64:
+			writeNumber(w.bw, ref, float64(v), "")
+		case float64:
+			writeNumber(w.bw, ref, v, "")
+		case time.Time:
+			if v.IsZero() {
+				continue
+			}
+			writeNumber(w.bw, ref, serialDate(v), ` s="1"`)
+		case string:
+			writeString(w.bw, ref, v)
+		default:
+			writeString(w.bw, ref, fmt.Sprint(v))
+		}
+	}
+	_, w.err = w.bw.WriteString("</row>")
+	return w.err
+}
+
+// Close ends the sheet and the archive. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if w.err != nil && !errors.Is(w.err, ErrTooManyRows) {
+		return w.err
+	}
+	w.bw.WriteString(sheetEnd)
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func writeNumber(w *bufio.Writer, ref string, v float64, style string) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func writeString(w *bufio.Writer, ref, v string) {
+	if v == "" {
+		return
+	}
+	fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(v))
+}
+
+// column turns a zero-based index into a column name: 0 is A, 26 is AA.
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// serialDate converts t to a spreadsheet date: days since 1899-12-30 in UTC.
+func serialDate(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return t.UTC().Sub(epoch).Hours() / 24
+}
+
+// sheetName trims name to what spreadsheet applications accept.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		// characters XML 1.0 cannot carry are dropped
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			continue
+		}
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '"':
+			b.WriteString("&quot;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+const contentTypes = `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const rootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbook = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// styles defines cell format 1 as a date and time.
+const styles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
+
+const (
+	sheetStart = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetEnd   = `</sheetData></worksheet>`
+)
@@ -0,0 +1,158 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string `xml:"r,attr"`
+			T  string `xml:"t,attr"`
+			S  string `xml:"s,attr"`
+			V  string `xml:"v"`
+			IS struct {
+				Space string `xml:"space,attr"`
+				Text  string `xml:",chardata"`
+			} `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// cell is what a test expects of a cell: its reference, type and content.
+type cell struct{ ref, typ, style, value string }
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Sales: Q1/Q2 & more")
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	when := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rows := [][]any{
+		{"name", "quantity", "price", "sold at"},
+		{`<Latte> & "Mocha"`, 3, 4.5, when},
+		{nil, int64(-2), "", time.Time{}, "bell\a rings\tonce"},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(body)
+	}
+
+	for name, want := range map[string][]string{
+		"[Content_Types].xml": {
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`,
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`,
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`,
+			`<Override PartName="/xl/styles.xml"`,
+		},
+		"_rels/.rels":                {`Target="xl/workbook.xml"`},
+		"xl/workbook.xml":            {`<sheet name="Sales_ Q1_Q2 &amp; more" sheetId="1" r:id="rId1"/>`},
+		"xl/_rels/workbook.xml.rels": {`Id="rId1"`, `Target="worksheets/sheet1.xml"`, `Target="styles.xml"`},
+		"xl/styles.xml":              {`formatCode="yyyy-mm-dd hh:mm"`},
+		"xl/worksheets/sheet1.xml":   {`<sheetData>`},
+	} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("part %s is missing", name)
+			continue
+		}
+		if !strings.HasPrefix(body, xml.Header) {
+			t.Errorf("part %s has no XML declaration", name)
+		}
+		if err := xml.Unmarshal([]byte(body), new(struct{})); err != nil {
+			t.Errorf("part %s is not well-formed: %v", name, err)
+		}
+		for _, s := range want {
+			if !strings.Contains(body, s) {
+				t.Errorf("part %s does not contain %s", name, s)
+			}
+		}
+	}
+	if len(parts) != 6 {
+		t.Errorf("archive has %d parts, want 6", len(parts))
+	}
+
+	var sheet sheetXML
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatalf("sheet does not parse: %v", err)
+	}
+	want := [][]cell{
+		{{"A1", "inlineStr", "", "name"}, {"B1", "inlineStr", "", "quantity"}, {"C1", "inlineStr", "", "price"}, {"D1", "inlineStr", "", "sold at"}},
+		{{"A2", "inlineStr", "", `<Latte> & "Mocha"`}, {"B2", "", "", "3"}, {"C2", "", "", "4.5"}, {"D2", "", "1", "45352.5"}},
+		{{"B3", "", "", "-2"}, {"E3", "inlineStr", "", "bell rings\tonce"}},
+	}
+	if len(sheet.Rows) != len(want) {
+		t.Fatalf("sheet has %d rows, want %d", len(sheet.Rows), len(want))
+	}
+	for i, row := range sheet.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %d is numbered %d", i+1, row.R)
+		}
+		var got []cell
+		for _, c := range row.Cells {
+			value := c.V
+			if c.T == "inlineStr" {
+				value = c.IS.Text
+				if c.IS.Space != "preserve" {
+					t.Errorf("cell %s does not preserve spaces", c.R)
+				}
+			}
+			got = append(got, cell{c.R, c.T, c.S, value})
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d = %q, want %q", i+1, got, want[i])
+		}
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := map[string]string{
+		"":                                     "Sheet1",
+		"orders [2024]":                        "orders _2024_",
+		"a very long report name that runs on": "a very long report name that ru",
+	}
+	for name, want := range tests {
+		if got := sheetName(name); got != want {
+			t.Errorf("sheetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}